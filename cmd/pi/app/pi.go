@@ -19,7 +19,12 @@ package app
 import (
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/golang/glog"
+	"github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi/cmd"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
 	"github.com/hyperhq/pi/pkg/pi/util/logs"
@@ -42,6 +47,34 @@ func Run() error {
 	//fix: logging before flag.Parse
 	flag.CommandLine.Parse([]string{})
 
+	// cancel the requests in flight on Ctrl-C, leaving the command a few
+	// seconds to restore the terminal, clean up and report
+	handleInterrupts(3 * time.Second)
+
 	cmd := cmd.NewPiCommand(cmdutil.NewFactory(nil), os.Stdin, os.Stdout, os.Stderr)
 	return cmd.Execute()
 }
+
+// handleInterrupts interrupts the command when pi receives an interrupt or a
+// termination signal, so that it returns once its requests are cancelled,
+// running its deferred cleanups. Pi exits if the command has not returned
+// after grace and the cleanups of hyper.OnInterrupt, or on a second signal.
+func handleInterrupts(grace time.Duration) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		glog.V(4).Infof("received %v, cancelling the requests in flight", sig)
+		hyper.Interrupt()
+		select {
+		case <-signals:
+		case <-time.After(grace):
+			select {
+			case <-signals:
+			case <-hyper.RunCleanups():
+			}
+		}
+		logs.FlushLogs()
+		os.Exit(130)
+	}()
+}
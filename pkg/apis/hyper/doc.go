@@ -0,0 +1,7 @@
+// Package hyper contains the Hyper.sh specific resources served by the Pi
+// platform next to the kubernetes core API: volumes and floating IPs.
+// The same Go types are used for the internal and the v1 version.
+
+// +k8s:deepcopy-gen=package
+// +groupName=hyper.sh
+package hyper // import "github.com/hyperhq/pi/pkg/apis/hyper"
@@ -0,0 +1,37 @@
+// Package install installs the hyper.sh API group, making it available as an
+// option to all of the API encoding/decoding machinery.
+package install
+
+import (
+	"github.com/hyperhq/pi/pkg/apis/hyper"
+
+	"k8s.io/apimachinery/pkg/apimachinery/announced"
+	"k8s.io/apimachinery/pkg/apimachinery/registered"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+)
+
+func init() {
+	Install(legacyscheme.GroupFactoryRegistry, legacyscheme.Registry, legacyscheme.Scheme)
+}
+
+// Install registers the API group and adds types to a scheme
+func Install(groupFactoryRegistry announced.APIGroupFactoryRegistry, registry *registered.APIRegistrationManager, scheme *runtime.Scheme) {
+	if err := announced.NewGroupMetaFactory(
+		&announced.GroupMetaFactoryArgs{
+			GroupName:                  hyper.GroupName,
+			VersionPreferenceOrder:     []string{hyper.SchemeGroupVersionV1.Version},
+			AddInternalObjectsToScheme: hyper.AddToScheme,
+			RootScopedKinds: sets.NewString(
+				"Volume",
+				"Fip",
+			),
+		},
+		announced.VersionToSchemeFunc{
+			hyper.SchemeGroupVersionV1.Version: hyper.AddToSchemeV1,
+		},
+	).Announce(groupFactoryRegistry).RegisterAndEnable(registry, scheme); err != nil {
+		panic(err)
+	}
+}
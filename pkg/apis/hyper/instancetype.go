@@ -0,0 +1,93 @@
+package hyper
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+// InstanceTypeAnnotation is the pod annotation the server sets to the
+// instance type the pod is billed as.
+const InstanceTypeAnnotation = "sh_hyper_instancetype"
+
+// DefaultInstanceType is the instance type of the pods without memory limits.
+const DefaultInstanceType = "s4"
+
+// InstanceType is a size a pod runs as. The type of a pod is the smallest
+// one with at least the sum of the memory limits of its containers.
+type InstanceType struct {
+	Name   string
+	Memory resource.Quantity
+}
+
+// InstanceTypes are the instance types of the platform, from the smallest.
+var InstanceTypes = []InstanceType{
+	{"s1", resource.MustParse("64Mi")},
+	{"s2", resource.MustParse("128Mi")},
+	{"s3", resource.MustParse("256Mi")},
+	{"s4", resource.MustParse("512Mi")},
+	{"m1", resource.MustParse("1Gi")},
+	{"m2", resource.MustParse("2Gi")},
+	{"m3", resource.MustParse("4Gi")},
+	{"l1", resource.MustParse("8Gi")},
+	{"l2", resource.MustParse("16Gi")},
+	{"l3", resource.MustParse("32Gi")},
+}
+
+// InstanceTypeNames returns the names of InstanceTypes.
+func InstanceTypeNames() []string {
+	names := make([]string, 0, len(InstanceTypes))
+	for _, t := range InstanceTypes {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// InstanceTypeByName returns the instance type called name.
+func InstanceTypeByName(name string) (InstanceType, bool) {
+	for _, t := range InstanceTypes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return InstanceType{}, false
+}
+
+// InstanceTypeForMemory returns the smallest instance type with at least
+// memory, false if memory is larger than all of them.
+func InstanceTypeForMemory(memory resource.Quantity) (InstanceType, bool) {
+	for _, t := range InstanceTypes {
+		if memory.Cmp(t.Memory) <= 0 {
+			return t, true
+		}
+	}
+	return InstanceType{}, false
+}
+
+// DeriveInstanceType returns the instance type the sum of the memory limits
+// of the containers of pod rounds up to, and that sum. Pods without memory
+// limits are of the default type, found is false when the sum is larger than
+// all the types.
+func DeriveInstanceType(pod *api.Pod) (instanceType InstanceType, memory resource.Quantity, found bool) {
+	for _, container := range pod.Spec.Containers {
+		if limit, ok := container.Resources.Limits[api.ResourceMemory]; ok {
+			memory.Add(limit)
+		}
+	}
+	if memory.IsZero() {
+		instanceType, found = InstanceTypeByName(DefaultInstanceType)
+		return instanceType, memory, found
+	}
+	instanceType, found = InstanceTypeForMemory(memory)
+	return instanceType, memory, found
+}
+
+// InstanceTypeOfPod returns the instance type of pod, the one set by the
+// server if any, or else the one derived from the memory limits of its
+// containers. found is false when that type is unknown.
+func InstanceTypeOfPod(pod *api.Pod) (InstanceType, bool) {
+	if name := pod.Annotations[InstanceTypeAnnotation]; len(name) > 0 {
+		return InstanceTypeByName(name)
+	}
+	instanceType, _, found := DeriveInstanceType(pod)
+	return instanceType, found
+}
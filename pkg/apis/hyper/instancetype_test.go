@@ -0,0 +1,96 @@
+package hyper_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	_ "k8s.io/kubernetes/pkg/apis/core/install"
+
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+)
+
+func readPod(t *testing.T, path string) *api.Pod {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := runtime.Decode(legacyscheme.Codecs.UniversalDecoder(), data)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return obj.(*api.Pod)
+}
+
+func TestInstanceTypeOfPodExamples(t *testing.T) {
+	tests := []struct {
+		file     string
+		memory   string
+		expected string
+	}{
+		{"pod-instance-type-32m.yaml", "32Mi", "s1"},
+		{"pod-instance-type-64m.yaml", "64Mi", "s1"},
+		{"pod-instance-type-2g.yaml", "2Gi", "m2"},
+		{"pod-instance-type-4g.yaml", "4Gi", "m3"},
+		{"pod-instance-type-8g.yaml", "8Gi", "l1"},
+		{"pod-instance-type-16g.yaml", "16Gi", "l2"},
+		// 17Gi overflows l2 and is rounded up to the 32Gi of l3
+		{"pod-instance-type-17g.yaml", "17Gi", "l3"},
+	}
+	for _, test := range tests {
+		pod := readPod(t, "../../../examples/pod/"+test.file)
+		derived, memory, found := hyperapi.DeriveInstanceType(pod)
+		if !found || derived.Name != test.expected {
+			t.Errorf("%s: expected type %s, got %s (found %v)", test.file, test.expected, derived.Name, found)
+		}
+		if expected := resource.MustParse(test.memory); memory.Cmp(expected) != 0 {
+			t.Errorf("%s: expected memory limits of %s, got %s", test.file, test.memory, memory.String())
+		}
+		if instanceType, found := hyperapi.InstanceTypeOfPod(pod); !found || instanceType.Name != test.expected {
+			t.Errorf("%s: expected type %s of the pod, got %s (found %v)", test.file, test.expected, instanceType.Name, found)
+		}
+	}
+}
+
+func TestInstanceTypeOfPod(t *testing.T) {
+	withLimits := func(limits ...string) *api.Pod {
+		pod := &api.Pod{}
+		for _, limit := range limits {
+			pod.Spec.Containers = append(pod.Spec.Containers, api.Container{
+				Resources: api.ResourceRequirements{
+					Limits: api.ResourceList{api.ResourceMemory: resource.MustParse(limit)},
+				},
+			})
+		}
+		return pod
+	}
+	annotated := withLimits("1Gi")
+	annotated.Annotations = map[string]string{hyperapi.InstanceTypeAnnotation: "l1"}
+	unknown := withLimits()
+	unknown.Annotations = map[string]string{hyperapi.InstanceTypeAnnotation: "x9"}
+
+	tests := []struct {
+		name     string
+		pod      *api.Pod
+		expected string
+		found    bool
+	}{
+		{"no limits", withLimits(), hyperapi.DefaultInstanceType, true},
+		{"no containers", &api.Pod{}, hyperapi.DefaultInstanceType, true},
+		{"exact size", withLimits("256Mi", "256Mi"), "s4", true},
+		{"one byte more", withLimits("512Mi", "1"), "m1", true},
+		{"largest", withLimits("32Gi"), "l3", true},
+		{"overflow", withLimits("16Gi", "17Gi"), "", false},
+		{"set by the server", annotated, "l1", true},
+		{"unknown to pi", unknown, "", false},
+	}
+	for _, test := range tests {
+		instanceType, found := hyperapi.InstanceTypeOfPod(test.pod)
+		if found != test.found || instanceType.Name != test.expected {
+			t.Errorf("%s: expected %q (found %v), got %q (found %v)", test.name, test.expected, test.found, instanceType.Name, found)
+		}
+	}
+}
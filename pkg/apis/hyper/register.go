@@ -0,0 +1,57 @@
+package hyper
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	AddToScheme   = SchemeBuilder.AddToScheme
+
+	SchemeBuilderV1 = runtime.NewSchemeBuilder(addKnownTypesV1)
+	AddToSchemeV1   = SchemeBuilderV1.AddToScheme
+)
+
+// GroupName is the group name use in this package
+const GroupName = "hyper.sh"
+
+// SchemeGroupVersion is group version used to register these objects
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: runtime.APIVersionInternal}
+
+// SchemeGroupVersionV1 is the external version of the group
+var SchemeGroupVersionV1 = schema.GroupVersion{Group: GroupName, Version: "v1"}
+
+// Kind takes an unqualified kind and returns a Group qualified GroupKind
+func Kind(kind string) schema.GroupKind {
+	return SchemeGroupVersion.WithKind(kind).GroupKind()
+}
+
+// Resource takes an unqualified resource and returns a Group qualified GroupResource
+func Resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
+
+// Adds the list of known types to the given scheme.
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&Volume{},
+		&VolumeList{},
+		&Fip{},
+		&FipList{},
+	)
+	return nil
+}
+
+// Adds the list of known types for the v1 version to the given scheme.
+func addKnownTypesV1(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersionV1,
+		&Volume{},
+		&VolumeList{},
+		&Fip{},
+		&FipList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersionV1)
+	return nil
+}
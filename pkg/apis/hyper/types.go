@@ -0,0 +1,98 @@
+package hyper
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Volume is a Hyper.sh block storage volume, which lives in a zone and
+// can be attached to one pod at a time.
+type Volume struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata. The name is the volume name.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec defines the size and placement of the volume.
+	// +optional
+	Spec VolumeSpec `json:"spec,omitempty"`
+
+	// Status describes what the volume is used by.
+	// +optional
+	Status VolumeStatus `json:"status,omitempty"`
+}
+
+// VolumeSpec is the size and placement of a volume.
+type VolumeSpec struct {
+	// Size of the volume in GB.
+	Size int `json:"size,omitempty"`
+	// Zone the volume is created in, e.g. gcp-us-central1-a.
+	// +optional
+	Zone string `json:"zone,omitempty"`
+}
+
+// VolumeStatus is the observed state of a volume.
+type VolumeStatus struct {
+	// Pod is the name of the pod the volume is attached to, empty if detached.
+	// +optional
+	Pod string `json:"pod,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VolumeList is a list of Volumes.
+type VolumeList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Volume `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Fip is a Hyper.sh floating IP, which can be bound to LoadBalancer services.
+type Fip struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata. The name is the IP address.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the user settable fields of the fip.
+	// +optional
+	Spec FipSpec `json:"spec,omitempty"`
+
+	// Status describes what the fip is used by.
+	// +optional
+	Status FipStatus `json:"status,omitempty"`
+}
+
+// FipSpec holds the user settable fields of a fip.
+type FipSpec struct {
+	// Name is the alias given to the fip with `pi name fip`.
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// FipStatus is the observed state of a fip.
+type FipStatus struct {
+	// Services lists the services the fip is bound to.
+	// +optional
+	Services []string `json:"services,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FipList is a list of Fips.
+type FipList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Fip `json:"items"`
+}
@@ -0,0 +1,232 @@
+package hyper
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperhq/client-go/rest"
+	hyperClient "github.com/hyperhq/hyper-api/client"
+	"github.com/hyperhq/hyper-api/signature"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// MaxClockSkew is the difference between the local clock and the one of the
+// server past which a rejected signature is put down to the clock. The server
+// only accepts the signatures made within a few minutes of its time.
+const MaxClockSkew = time.Minute
+
+// SignTimeOffsetAuto is the --sign-time-offset measuring the offset against
+// the clock of the server.
+const SignTimeOffsetAuto = "auto"
+
+var (
+	// signTimeOffset is the time.Duration added to the local time to sign
+	// the requests.
+	signTimeOffset int64
+	// autoSignTimeOffset is 1 when the requests rejected because of the
+	// clock skew are signed again at the time of the server.
+	autoSignTimeOffset int32
+	skewWarning        sync.Once
+)
+
+// signedHeaders are the headers set by the signature of a request.
+var signedHeaders = []string{"Authorization", "X-Hyper-Date", "X-Hyper-Content-Sha256"}
+
+// SignTimeOffset returns the offset added to the local time to sign the
+// requests.
+func SignTimeOffset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&signTimeOffset))
+}
+
+func setSignTimeOffset(offset time.Duration) {
+	atomic.StoreInt64(&signTimeOffset, int64(offset))
+}
+
+// Sign4 signs req like signature.Sign4, at the local time plus the sign time
+// offset. A former signature of req is replaced.
+func Sign4(accessKey, secretKey string, req *http.Request, region string) *http.Request {
+	for _, name := range signedHeaders {
+		req.Header.Del(name)
+	}
+	// the signature keeps the date of the request
+	req.Header.Set("X-Hyper-Date", time.Now().Add(SignTimeOffset()).UTC().Format("20060102T150405Z"))
+	return signature.Sign4(accessKey, secretKey, req, region)
+}
+
+// SetSignTimeOffset sets the offset added to the local time to sign the
+// requests, a duration like -5m30s, or SignTimeOffsetAuto to measure it on the
+// first request rejected because of the clock skew.
+func SetSignTimeOffset(value string) error {
+	if value == SignTimeOffsetAuto {
+		atomic.StoreInt32(&autoSignTimeOffset, 1)
+		return nil
+	}
+	offset, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid sign time offset %q, must be %s or a duration like -5m30s", value, SignTimeOffsetAuto)
+	}
+	setSignTimeOffset(offset)
+	return nil
+}
+
+// ClockSkew returns how far the clock of the server sending resp is ahead of
+// the local clock at received, false when resp has no Date. The Date of the
+// responses is to the second.
+func ClockSkew(resp *http.Response, received time.Time) (time.Duration, bool) {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+	return date.Sub(received.Truncate(time.Second)), true
+}
+
+// ServerClockSkew measures how far the clock of the Hyper API server is ahead
+// of the local clock, from the Date of the response to an unsigned request.
+// The skew is accurate to about a second.
+func (cli *HyperCli) ServerClockSkew(ctx context.Context) (time.Duration, error) {
+	_, addr, _, err := hyperClient.ParseHost(cli.host)
+	if err != nil {
+		return 0, err
+	}
+	if i := strings.Index(addr, "/"); i >= 0 {
+		addr = addr[:i]
+	}
+	req, err := http.NewRequest("GET", "https://"+addr+"/", nil)
+	if err != nil {
+		return 0, err
+	}
+	sent := time.Now()
+	resp, err := cli.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	// the server dated the response about halfway through the round trip
+	skew, found := ClockSkew(resp, sent.Add(received.Sub(sent)/2))
+	if !found {
+		return 0, fmt.Errorf("the Hyper API server at %s sent no Date", addr)
+	}
+	return skew, nil
+}
+
+// DescribeClockSkew tells how far the local clock is from the one of the server.
+func DescribeClockSkew(skew time.Duration) string {
+	switch {
+	case skew > 0:
+		return fmt.Sprintf("the clock of this machine is %v behind the Hyper API server", skew)
+	case skew < 0:
+		return fmt.Sprintf("the clock of this machine is %v ahead of the Hyper API server", -skew)
+	}
+	return "the clock of this machine is on time with the Hyper API server"
+}
+
+// ClockSkewHint is how to fix the clock skew.
+const ClockSkewHint = "set the clock right, with `sudo timedatectl set-ntp true` or `sudo ntpdate pool.ntp.org`, or sign the requests at the time of the server with --sign-time-offset=auto"
+
+// ClockSkewTransport signs the requests again at the sign time offset, the
+// clients of client-go and hyper-api sign them at the local time. It tells
+// when the signed requests are rejected because of the skew of the local
+// clock. With --sign-time-offset=auto, the signatures are then made at the
+// time of the server and the request is sent again, once.
+type ClockSkewTransport struct {
+	delegate  http.RoundTripper
+	accessKey string
+	secretKey string
+	region    string
+}
+
+// WrapClockSkewTransport returns a rest.Config.WrapTransport signing the
+// requests sent again with the credentials of config.
+func WrapClockSkewTransport(config *rest.Config) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &ClockSkewTransport{
+			delegate:  rt,
+			accessKey: config.AccessKey,
+			secretKey: config.SecretKey,
+			region:    config.Region,
+		}
+	}
+}
+
+func (t *ClockSkewTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if SignTimeOffset() != 0 && len(req.Header.Get("Authorization")) > 0 {
+		signed, err := t.resign(req)
+		if err != nil {
+			return nil, err
+		}
+		req = signed
+	}
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil || len(req.Header.Get("Authorization")) == 0 ||
+		resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		return resp, err
+	}
+	skew, found := ClockSkew(resp, time.Now())
+	// the signature is off by the skew left after the current offset
+	if !found || abs(skew-SignTimeOffset()) < MaxClockSkew {
+		return resp, err
+	}
+	if atomic.LoadInt32(&autoSignTimeOffset) == 0 || (req.Body != nil && req.GetBody == nil) {
+		skewWarning.Do(func() {
+			fmt.Fprintf(os.Stderr, "warning: the request was rejected and %s, the signatures of the requests are only valid for a few minutes: %s\n", DescribeClockSkew(skew), ClockSkewHint)
+		})
+		return resp, err
+	}
+
+	glog.V(2).Infof("%s, signing the requests %v ahead", DescribeClockSkew(skew), skew)
+	setSignTimeOffset(skew)
+	retry, resignErr := t.resign(req)
+	if resignErr != nil {
+		glog.V(4).Infof("can't sign %s %s again: %v", req.Method, req.URL, resignErr)
+		return resp, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return t.delegate.RoundTrip(retry)
+}
+
+// resign returns a copy of req signed again, at the current time offset. The
+// body is read again when it can be, Sign4 buffers it otherwise.
+func (t *ClockSkewTransport) resign(req *http.Request) (*http.Request, error) {
+	retry := req.WithContext(req.Context())
+	retry.Header = http.Header{}
+	for name, values := range req.Header {
+		retry.Header[name] = append([]string(nil), values...)
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return Sign4(t.accessKey, t.secretKey, retry, t.region), nil
+}
+
+func (t *ClockSkewTransport) CancelRequest(req *http.Request) {
+	if canceler, ok := t.delegate.(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
+func (t *ClockSkewTransport) WrappedRoundTripper() http.RoundTripper { return t.delegate }
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
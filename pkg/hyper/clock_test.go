@@ -0,0 +1,112 @@
+package hyper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/client-go/rest"
+	"github.com/hyperhq/hyper-api/signature"
+)
+
+func TestClockSkewTransportSignsAtServerTime(t *testing.T) {
+	const ahead = 10 * time.Minute
+	var requests int32
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		now := time.Now().Add(ahead)
+		w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+		signed, err := time.Parse("20060102T150405Z", r.Header.Get("X-Hyper-Date"))
+		if err != nil || abs(now.Sub(signed)) > MaxClockSkew {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+	defer setSignTimeOffset(0)
+	defer atomic.StoreInt32(&autoSignTimeOffset, 0)
+
+	config := &rest.Config{AccessKey: "ak", SecretKey: "sk", Region: "gcp-us-central1"}
+	client := &http.Client{Transport: WrapClockSkewTransport(config)(http.DefaultTransport)}
+	post := func() *http.Response {
+		req, err := http.NewRequest("POST", server.URL+"/api/v1/containers/create", strings.NewReader(`{"Image":"nginx"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(signature.Sign4("ak", "sk", req, "gcp-us-central1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := post(); resp.StatusCode != http.StatusForbidden || requests != 1 {
+		t.Fatalf("expected a single rejected request without auto, got %s after %d requests", resp.Status, requests)
+	}
+
+	if err := SetSignTimeOffset(SignTimeOffsetAuto); err != nil {
+		t.Fatal(err)
+	}
+	if resp := post(); resp.StatusCode != http.StatusOK || requests != 3 {
+		t.Fatalf("expected the request signed again to pass, got %s after %d requests", resp.Status, requests)
+	}
+	if len(bodies) != 1 || bodies[0] != `{"Image":"nginx"}` {
+		t.Errorf("expected the body sent again, got %q", bodies)
+	}
+	if offset := SignTimeOffset(); abs(offset-ahead) > 2*time.Second {
+		t.Errorf("expected a sign time offset of about %v, got %v", ahead, offset)
+	}
+	// the next requests are signed at the time of the server at once
+	if resp := post(); resp.StatusCode != http.StatusOK || requests != 4 {
+		t.Errorf("expected the next request to pass at once, got %s after %d requests", resp.Status, requests)
+	}
+}
+
+func TestSetSignTimeOffset(t *testing.T) {
+	defer setSignTimeOffset(0)
+	if err := SetSignTimeOffset("-5m30s"); err != nil {
+		t.Fatal(err)
+	}
+	if offset := SignTimeOffset(); offset != -5*time.Minute-30*time.Second {
+		t.Errorf("unexpected offset %v", offset)
+	}
+	if err := SetSignTimeOffset("later"); err == nil {
+		t.Error("expected an invalid sign time offset error")
+	}
+}
+
+func TestClockSkewTransportSignsAtOffset(t *testing.T) {
+	var signed time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signed, _ = time.Parse("20060102T150405Z", r.Header.Get("X-Hyper-Date"))
+	}))
+	defer server.Close()
+	defer setSignTimeOffset(0)
+
+	if err := SetSignTimeOffset("-1h"); err != nil {
+		t.Fatal(err)
+	}
+	config := &rest.Config{AccessKey: "ak", SecretKey: "sk", Region: "gcp-us-central1"}
+	client := &http.Client{Transport: WrapClockSkewTransport(config)(http.DefaultTransport)}
+	req, err := http.NewRequest("GET", server.URL+"/api/v1/info", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// signed at the local time, like by the vendored clients
+	resp, err := client.Do(signature.Sign4("ak", "sk", req, "gcp-us-central1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if expected := time.Now().Add(-time.Hour); abs(signed.Sub(expected)) > 2*time.Second {
+		t.Errorf("expected the request signed at %v, got %v", expected, signed)
+	}
+}
@@ -0,0 +1,172 @@
+package hyper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hyperhq/client-go/rest"
+	api "github.com/hyperhq/client-go/tools/clientcmd/api/hyper"
+)
+
+// Conn sends signed requests to the Hyper endpoints of a cluster, like
+// /api/v1/hyper/volumes, which are not served by the kubernetes API. The
+// certificate of the server is verified as for the other clients, see
+// TLSConfig.
+type Conn struct {
+	host      string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	// err is returned by the requests when the client could not be created
+	err error
+	// uninterruptible requests are not cancelled when pi is interrupted
+	uninterruptible bool
+}
+
+// NewConn returns a Conn to the cluster of config. The requests go through
+// the WrapTransport of config, within its Timeout.
+func NewConn(config *rest.Config) *Conn {
+	conn := &Conn{
+		host:      RegionHost(config.Host, config),
+		region:    config.Region,
+		accessKey: config.AccessKey,
+		secretKey: config.SecretKey,
+	}
+	tlsConfig, err := TLSConfig(config)
+	if err != nil {
+		conn.err = fmt.Errorf("create TLS configuration error: %v", err)
+		return conn
+	}
+	var rt http.RoundTripper = &http.Transport{
+		Proxy:               Proxy,
+		Dial:                (&net.Dialer{Timeout: 10 * time.Second}).Dial,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if config.WrapTransport != nil {
+		rt = config.WrapTransport(rt)
+	}
+	conn.client = &http.Client{Transport: rt, Timeout: config.Timeout}
+	return conn
+}
+
+// Uninterruptible returns a copy of c whose requests are not cancelled when pi
+// is interrupted, for the cleanups of OnInterrupt.
+func (c *Conn) Uninterruptible() *Conn {
+	uninterruptible := *c
+	uninterruptible.uninterruptible = true
+	return &uninterruptible
+}
+
+// SockRequest sends a request to endpoint with the body data of contentType,
+// if any, and returns the body and the status code of the response.
+func (c *Conn) SockRequest(method, endpoint string, data io.Reader, contentType string) (string, int, error) {
+	if c.err != nil {
+		return "", 0, c.err
+	}
+	hostURL, err := url.Parse(c.host)
+	if err != nil {
+		return "", 0, fmt.Errorf("host url format error: %v", err)
+	}
+	req, err := http.NewRequest(method, "https://"+hostURL.Host+endpoint, data)
+	if err != nil {
+		return "", 0, fmt.Errorf("could not create new request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.uninterruptible {
+		req = req.WithContext(Uninterruptible(req.Context()))
+	}
+	req = Sign4(c.accessKey, c.secretKey, req, c.region)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("http request error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", resp.StatusCode, fmt.Errorf("read response body error: %v", err)
+	}
+	return string(body), resp.StatusCode, nil
+}
+
+// GetInfo returns the info of the account and of its region.
+func (c *Conn) GetInfo() (int, map[string]string, error) {
+	info := map[string]string{}
+	status, err := c.call(http.MethodGet, "/info", nil, http.StatusOK, &info)
+	return status, info, err
+}
+
+// ListVolumes returns the volumes in zone, or in the default zone when empty.
+func (c *Conn) ListVolumes(zone string) ([]api.VolumeResponse, error) {
+	volumes := []api.VolumeResponse{}
+	_, err := c.call(http.MethodGet, "/api/v1/hyper/volumes?zone="+url.QueryEscape(zone), nil, http.StatusOK, &volumes)
+	return volumes, err
+}
+
+// CreateVolume creates a volume of size GB in zone.
+func (c *Conn) CreateVolume(name, zone string, size int) (*api.VolumeResponse, error) {
+	volume := &api.VolumeResponse{}
+	_, err := c.call(http.MethodPost, "/api/v1/hyper/volumes", &api.VolumeCreateRequest{Name: name, Zone: zone, Size: size}, http.StatusCreated, volume)
+	return volume, err
+}
+
+// AllocateFips allocates count fips.
+func (c *Conn) AllocateFips(count int) ([]api.FipResponse, error) {
+	fips := []api.FipResponse{}
+	_, err := c.call(http.MethodPost, fmt.Sprintf("/api/v1/hyper/fips?count=%d", count), nil, http.StatusCreated, &fips)
+	return fips, err
+}
+
+// NameFip gives name to the fip ip.
+func (c *Conn) NameFip(ip, name string) error {
+	_, err := c.call(http.MethodPost, fipEndpoint(ip), &api.FipRenameRequest{Name: name}, http.StatusNoContent, nil)
+	return err
+}
+
+// ReleaseFip releases the fip ip.
+func (c *Conn) ReleaseFip(ip string) error {
+	_, err := c.call(http.MethodDelete, fipEndpoint(ip), nil, http.StatusNoContent, nil)
+	return err
+}
+
+// call sends in as the JSON body of a request to endpoint, and decodes the
+// response into out. The responses with another status than expected are
+// errors.
+func (c *Conn) call(method, endpoint string, in interface{}, expected int, out interface{}) (int, error) {
+	var (
+		data        io.Reader
+		contentType string
+	)
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		data, contentType = bytes.NewReader(body), "application/json"
+	}
+	result, status, err := c.SockRequest(method, endpoint, data, contentType)
+	if err != nil {
+		return status, fmt.Errorf("send request error: %v", err)
+	}
+	if status != expected {
+		return status, fmt.Errorf("response error: %v - %v", status, errorMessage(result))
+	}
+	if out == nil {
+		return status, nil
+	}
+	if err := json.Unmarshal([]byte(result), out); err != nil {
+		return status, fmt.Errorf("failed to parse the response of %s %s: %v", method, endpoint, err)
+	}
+	return status, nil
+}
@@ -0,0 +1,80 @@
+package hyper
+
+import (
+	api "github.com/hyperhq/client-go/tools/clientcmd/api/hyper"
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VolumeFromResponse converts a volume returned by the Hyper API to a Volume object.
+func VolumeFromResponse(vol *api.VolumeResponse) *hyperapi.Volume {
+	return &hyperapi.Volume{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: hyperapi.SchemeGroupVersionV1.String(),
+			Kind:       "Volume",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              vol.Name,
+			CreationTimestamp: metav1.NewTime(vol.CreatedAt),
+		},
+		Spec: hyperapi.VolumeSpec{
+			Size: vol.Size,
+			Zone: vol.Zone,
+		},
+		Status: hyperapi.VolumeStatus{
+			Pod: vol.Pod,
+		},
+	}
+}
+
+// VolumeListFromResponse converts the volumes returned by the Hyper API to a VolumeList.
+func VolumeListFromResponse(vols []api.VolumeResponse) *hyperapi.VolumeList {
+	list := &hyperapi.VolumeList{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: hyperapi.SchemeGroupVersionV1.String(),
+			Kind:       "VolumeList",
+		},
+		Items: make([]hyperapi.Volume, 0, len(vols)),
+	}
+	for i := range vols {
+		list.Items = append(list.Items, *VolumeFromResponse(&vols[i]))
+	}
+	return list
+}
+
+// FipFromResponse converts a fip returned by the Hyper API to a Fip object.
+// The IP address is used as the object name.
+func FipFromResponse(fip *api.FipResponse) *hyperapi.Fip {
+	return &hyperapi.Fip{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: hyperapi.SchemeGroupVersionV1.String(),
+			Kind:       "Fip",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              fip.Fip,
+			CreationTimestamp: metav1.NewTime(fip.CreatedAt),
+		},
+		Spec: hyperapi.FipSpec{
+			Name: fip.Name,
+		},
+		Status: hyperapi.FipStatus{
+			Services: fip.Services,
+		},
+	}
+}
+
+// FipListFromResponse converts the fips returned by the Hyper API to a FipList.
+func FipListFromResponse(fips []api.FipResponse) *hyperapi.FipList {
+	list := &hyperapi.FipList{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: hyperapi.SchemeGroupVersionV1.String(),
+			Kind:       "FipList",
+		},
+		Items: make([]hyperapi.Fip, 0, len(fips)),
+	}
+	for i := range fips {
+		list.Items = append(list.Items, *FipFromResponse(&fips[i]))
+	}
+	return list
+}
@@ -0,0 +1,49 @@
+package hyper
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/hyperhq/hyper-api/types"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// ExecStream runs cmd in container of pod without a tty, streaming stdin to it and
+// its output to stdout and stderr, and returns the exit code of cmd. Any of the
+// streams may be nil.
+func (cli *HyperCli) ExecStream(ctx context.Context, pod, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	execConfig := types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdin:  stdin != nil,
+		AttachStdout: stdout != nil,
+		AttachStderr: stderr != nil,
+	}
+	response, err := cli.Client.PodExecCreate(ctx, pod, container, execConfig)
+	if err != nil {
+		return 0, err
+	}
+	if response.ID == "" {
+		return 0, fmt.Errorf("exec ID empty")
+	}
+
+	glog.V(7).Infof("PodExecAttach: execID:%v cmd:%v", response.ID, cmd)
+	resp, err := cli.PodExecAttach(ctx, response.ID, execConfig)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Close()
+
+	var in io.ReadCloser
+	if stdin != nil {
+		in = ioutil.NopCloser(stdin)
+	}
+	if err := cli.HoldHijackedConnection(false, in, stdout, stderr, resp); err != nil {
+		return 0, err
+	}
+
+	_, status, err := cli.GetExecExitCode(ctx, response.ID)
+	return status, err
+}
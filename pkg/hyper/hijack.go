@@ -1,14 +1,178 @@
 package hyper
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
 
+	hyperClient "github.com/hyperhq/hyper-api/client"
 	"github.com/hyperhq/hyper-api/types"
 	"github.com/hyperhq/hypercli/pkg/stdcopy"
 
+	"github.com/docker/go-connections/sockets"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 )
 
+// PodExecAttach starts the exec execID, created with PodExecCreate, and
+// hijacks the connection of its streams.
+func (cli *HyperCli) PodExecAttach(ctx context.Context, execID string, config types.ExecConfig) (types.HijackedResponse, error) {
+	headers := http.Header{"Content-Type": {"application/json"}}
+	return cli.postHijacked(ctx, fmt.Sprintf("/exec/%s/start", execID), nil, config, headers)
+}
+
+// ContainerAttach hijacks the connection of the streams of container.
+func (cli *HyperCli) ContainerAttach(ctx context.Context, container string, options types.ContainerAttachOptions) (types.HijackedResponse, error) {
+	query := url.Values{}
+	if options.Stream {
+		query.Set("stream", "1")
+	}
+	if options.Stdin {
+		query.Set("stdin", "1")
+	}
+	if options.Stdout {
+		query.Set("stdout", "1")
+	}
+	if options.Stderr {
+		query.Set("stderr", "1")
+	}
+	if options.DetachKeys != "" {
+		query.Set("detachKeys", options.DetachKeys)
+	}
+	headers := http.Header{"Content-Type": {"text/plain"}}
+	return cli.postHijacked(ctx, "/containers/"+container+"/attach", query, nil, headers)
+}
+
+// postHijacked sends an upgrade request to path and hijacks its connection.
+// Unlike the one of Client, the connection goes through the proxy of the
+// cluster, and the request is traced and signed again on clock skew like the
+// others.
+func (cli *HyperCli) postHijacked(ctx context.Context, path string, query url.Values, body interface{}, headers http.Header) (types.HijackedResponse, error) {
+	_, addr, basePath, err := hyperClient.ParseHost(cli.host)
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	data := []byte{}
+	if body != nil {
+		if data, err = json.Marshal(body); err != nil {
+			return types.HijackedResponse{}, err
+		}
+	}
+	u := &url.URL{Scheme: "https", Host: addr, Path: basePath + "/" + apiVersion + path, RawQuery: query.Encode()}
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(data))
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	req = req.WithContext(ctx)
+	for name, value := range cli.headers {
+		req.Header.Set(name, value)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	req = Sign4(cli.accessKey, cli.secretKey, req, cli.region)
+
+	var clientconn *httputil.ClientConn
+	var rt http.RoundTripper = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		conn, err := dialTLS(addr, cli.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		if clientconn != nil {
+			// the request is sent again, on a new connection
+			clientconn.Close()
+		}
+		clientconn = httputil.NewClientConn(conn, nil)
+		// the server hijacks the connection, a 'connection closed' error
+		// is expected
+		return clientconn.Do(req)
+	})
+	if cli.wrapHijack != nil {
+		rt = cli.wrapHijack(rt)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if clientconn == nil {
+		return types.HijackedResponse{}, err
+	}
+	defer clientconn.Close()
+	rwc, br := clientconn.Hijack()
+	return types.HijackedResponse{Conn: rwc, Reader: br, Resp: resp}, err
+}
+
+// roundTripperFunc sends a request with a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// tlsClientConn is a TLS connection which can close its write end, for the
+// end of the input of exec and attach.
+type tlsClientConn struct {
+	*tls.Conn
+	rawConn net.Conn
+}
+
+func (c *tlsClientConn) CloseWrite() error {
+	if conn, ok := c.rawConn.(types.CloseWriter); ok {
+		return conn.CloseWrite()
+	}
+	return nil
+}
+
+// dialTLS opens a TLS connection to addr, through the proxy of the cluster,
+// or the proxy of the environment. There may be long periods without traffic
+// on the hijacked connections, like a command without output, the TCP keep
+// alive tells them from broken connections.
+func dialTLS(addr string, tlsConfig *tls.Config) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	proxyURL, err := ProxyFor("https", addr)
+	if err != nil {
+		return nil, err
+	}
+	var rawConn net.Conn
+	if proxyURL != nil {
+		rawConn, err = DialProxy(dialer, proxyURL, addr)
+	} else {
+		// the SOCKS proxy of ALL_PROXY, if any
+		proxyDialer, dialerErr := sockets.DialerFromEnvironment(dialer)
+		if dialerErr != nil {
+			return nil, dialerErr
+		}
+		rawConn, err = proxyDialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := tlsConfig.Clone()
+	if config.ServerName == "" {
+		host, _, splitErr := net.SplitHostPort(addr)
+		if splitErr != nil {
+			host = addr
+		}
+		config.ServerName = host
+	}
+	conn := tls.Client(rawConn, config)
+	rawConn.SetDeadline(time.Now().Add(dialer.Timeout))
+	if err := conn.Handshake(); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	rawConn.SetDeadline(time.Time{})
+	return &tlsClientConn{conn, rawConn}, nil
+}
+
 func (cli *HyperCli) HoldHijackedConnection(tty bool, inputStream io.ReadCloser, outputStream, errorStream io.Writer, resp types.HijackedResponse) error {
 	var err error
 	receiveStdout := make(chan error, 1)
@@ -1,11 +1,16 @@
 package hyper
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hyperhq/client-go/rest"
 	hyperClient "github.com/hyperhq/hyper-api/client"
@@ -13,8 +18,8 @@ import (
 	"github.com/hyperhq/pi"
 
 	"github.com/docker/go-connections/sockets"
-	"github.com/docker/go-connections/tlsconfig"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 )
 
 type HyperCli struct {
@@ -44,8 +49,22 @@ type HyperCli struct {
 
 	region string
 	host   string
+	// httpClient sends the requests of Client
+	httpClient *http.Client
+	// tlsConfig, headers and the credentials are those of the requests of
+	// Client, for the hijacked connections
+	tlsConfig *tls.Config
+	headers   map[string]string
+	accessKey string
+	secretKey string
+	// wrapHijack wraps the sending of the upgrade requests of the hijacked
+	// connections, which do not go through httpClient
+	wrapHijack func(http.RoundTripper) http.RoundTripper
 }
 
+// apiVersion is the version of the Hyper API of the requests.
+const apiVersion = "api/v1"
+
 // An StatusError reports an unsuccessful exit by a command.
 type StatusError struct {
 	Status     string
@@ -57,36 +76,51 @@ func (e StatusError) Error() string {
 }
 
 func NewHyperCli(host string, config *rest.Config, stdin io.ReadCloser, stdout io.Writer, stderr io.Writer) (*HyperCli, error) {
-	//replace default domain
-	if strings.Contains(host, rest.DefaultDomain) {
-		host = strings.Replace(host, "*", config.Region, 1)
-		glog.V(4).Infof("NewHyperCli: replace default domain to %v", host)
-	}
+	host = RegionHost(host, config)
 
-	tlsOptions := &tlsconfig.Options{
-		InsecureSkipVerify: true,
+	tlsConfig, err := TLSConfig(config)
+	if err != nil {
+		return nil, err
 	}
-	httpClient, err := newHTTPClient(host, tlsOptions)
+	httpClient, err := newHTTPClient(host, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
+	// the proxy of the cluster replaces the one of the environment
+	httpClient.Transport.(*http.Transport).Proxy = Proxy
 	customHeaders := map[string]string{}
 	customHeaders["User-Agent"] = "Pi/" + pi.Version + " (" + runtime.GOOS + ")"
-	apiVersion := "api/v1"
 
 	client, err := hyperClient.NewClient(host, apiVersion, httpClient, customHeaders, config.AccessKey, config.SecretKey, config.Region)
 	if err != nil {
 		return nil, err
 	}
+	// NewClient takes the TLS configuration from the *http.Transport of the
+	// client, it is wrapped afterwards
+	skew := WrapClockSkewTransport(config)
+	// the bodies of the requests of Client can't be read again
+	httpClient.Transport = &bufferedBodyTransport{delegate: &timeoutTransport{
+		delegate: skew(WrapRetryTransport(WrapTraceTransport(WrapLogTransport(httpClient.Transport)))),
+		timeout:  config.Timeout,
+	}}
 
 	glog.V(7).Infof("host:%v\n ver:%v\n customHeaders:%v\n", host, apiVersion, customHeaders)
 
 	cli := &HyperCli{
-		Client: client,
-		host:   host,
-		In:     stdin,
-		Out:    stdout,
-		Err:    stderr,
+		Client:     client,
+		region:     config.Region,
+		host:       host,
+		httpClient: httpClient,
+		tlsConfig:  tlsConfig,
+		headers:    customHeaders,
+		accessKey:  config.AccessKey,
+		secretKey:  config.SecretKey,
+		In:         stdin,
+		Out:        stdout,
+		Err:        stderr,
+		wrapHijack: func(rt http.RoundTripper) http.RoundTripper {
+			return skew(WrapTraceTransport(WrapLogTransport(rt)))
+		},
 	}
 	if stdin != nil {
 		cli.inFd, cli.IsTerminalIn = term.GetFdInfo(stdin)
@@ -97,18 +131,50 @@ func NewHyperCli(host string, config *rest.Config, stdin io.ReadCloser, stdout i
 	return cli, nil
 }
 
-func newHTTPClient(host string, tlsOptions *tlsconfig.Options) (*http.Client, error) {
-	if tlsOptions == nil {
-		// let the api client configure the default transport.
-		return nil, nil
+// RegionHost returns host with the region of config in place of the * of the
+// default domain, the Hyper API server of the region.
+func RegionHost(host string, config *rest.Config) string {
+	//replace default domain
+	if strings.Contains(host, rest.DefaultDomain) {
+		host = strings.Replace(host, "*", config.Region, 1)
+		glog.V(4).Infof("RegionHost: replace default domain to %v", host)
 	}
+	return host
+}
 
-	config, err := tlsconfig.Client(*tlsOptions)
+// TLSConfig returns the TLS configuration to talk to the Hyper API of config.
+// The server certificate is verified against the certificate authority of the
+// cluster, or the system roots when there is none, unless the cluster is
+// explicitly marked insecure.
+func TLSConfig(config *rest.Config) (*tls.Config, error) {
+	WarnInsecure(config)
+	tlsConfig, err := rest.TLSConfigFor(config)
 	if err != nil {
 		return nil, err
 	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return tlsConfig, nil
+}
+
+var insecureWarning sync.Once
+
+// WarnInsecure warns once when config skips the verification of the
+// certificate of a hyper.sh server, the credentials of the requests are
+// readable by anyone on the way to the server.
+func WarnInsecure(config *rest.Config) {
+	if !config.Insecure || !strings.Contains(config.Host, strings.TrimPrefix(rest.DefaultDomain, "*")) {
+		return
+	}
+	insecureWarning.Do(func() {
+		fmt.Fprintf(os.Stderr, "warning: the cluster has insecure-skip-tls-verify set, the certificate of %s is not verified: unset it with `pi config set-cluster NAME --insecure-skip-tls-verify=false`\n", config.Host)
+	})
+}
+
+func newHTTPClient(host string, tlsConfig *tls.Config) (*http.Client, error) {
 	tr := &http.Transport{
-		TLSClientConfig: config,
+		TLSClientConfig: tlsConfig,
 	}
 	proto, addr, _, err := hyperClient.ParseHost(host)
 	if err != nil {
@@ -121,3 +187,51 @@ func newHTTPClient(host string, tlsOptions *tlsconfig.Options) (*http.Client, er
 		Transport: tr,
 	}, nil
 }
+
+// timeoutTransport gives up on the requests without a response within timeout,
+// retries included, like http.Client.Timeout. The requests streaming their
+// response, like the logs followed, last as long as the stream does; the
+// hijacked connections of attach and exec do not go through it.
+type timeoutTransport struct {
+	delegate http.RoundTripper
+	timeout  time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout == 0 || isStreaming(req) {
+		return t.delegate.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.delegate.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *timeoutTransport) CancelRequest(req *http.Request) {
+	if canceler, ok := t.delegate.(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
+func (t *timeoutTransport) WrappedRoundTripper() http.RoundTripper { return t.delegate }
+
+// isStreaming tells whether the response of req is a stream, which ends with
+// the container or when the client stops reading it.
+func isStreaming(req *http.Request) bool {
+	query := req.URL.Query()
+	switch path.Base(req.URL.Path) {
+	case "attach", "events", "wait", "archive", "export":
+		return true
+	case "logs":
+		return query.Get("follow") == "1"
+	case "stats":
+		return query.Get("stream") != "0"
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package hyper
+
+import (
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/client-go/rest"
+
+	"golang.org/x/net/context"
+)
+
+func TestWarnInsecure(t *testing.T) {
+	defer func() { insecureWarning = sync.Once{} }()
+
+	tests := []struct {
+		name     string
+		host     string
+		insecure bool
+		expected int
+	}{
+		{"verified", "https://us-west-1.hyper.sh", false, 0},
+		{"other server", "https://127.0.0.1:8443", true, 0},
+		{"hyper.sh", "https://us-west-1.hyper.sh", true, 1},
+	}
+	for _, test := range tests {
+		insecureWarning = sync.Once{}
+		config := &rest.Config{Host: test.host, TLSClientConfig: rest.TLSClientConfig{Insecure: test.insecure}}
+		out := captureStderr(t, func() {
+			// the warning is printed once whatever the number of clients
+			for i := 0; i < 3; i++ {
+				if _, err := TLSConfig(config); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+		if count := strings.Count(out, "insecure-skip-tls-verify set"); count != test.expected {
+			t.Errorf("%s: expected %d warnings, got %q", test.name, test.expected, out)
+		}
+	}
+}
+
+func TestTLSConfigRejectsUntrustedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{}`)
+	}))
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	u, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		name    string
+		config  rest.TLSClientConfig
+		trusted bool
+	}{
+		{"self-signed", rest.TLSClientConfig{}, false},
+		{"certificate authority", rest.TLSClientConfig{CAData: ca}, true},
+		{"insecure", rest.TLSClientConfig{Insecure: true}, true},
+	}
+	for _, test := range tests {
+		config := &rest.Config{Host: server.URL, TLSClientConfig: test.config}
+
+		_, _, err := NewConn(config).GetInfo()
+		if test.trusted && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.trusted && (err == nil || !strings.Contains(err.Error(), "x509:")) {
+			t.Errorf("%s: expected a certificate error, got %v", test.name, err)
+		}
+
+		// the hijacked connections of exec and attach are verified too
+		tlsConfig, err := TLSConfig(config)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		conn, err := dialTLS(u.Host, tlsConfig)
+		if err == nil {
+			conn.Close()
+		}
+		if test.trusted && err != nil {
+			t.Errorf("%s: unexpected dial error: %v", test.name, err)
+		}
+		if !test.trusted && (err == nil || !strings.Contains(err.Error(), "x509:")) {
+			t.Errorf("%s: expected a certificate error on dial, got %v", test.name, err)
+		}
+	}
+}
+
+func TestHyperCliTimeout(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/stats") {
+			// the stream outlives the timeout
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(200 * time.Millisecond)
+			io.WriteString(w, `{"read":"2018-03-01T10:00:00Z"}`)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, `{"Id":"id","State":{"Running":true}}`)
+	}))
+	defer server.Close()
+	config := &rest.Config{
+		Host:            server.URL,
+		Timeout:         50 * time.Millisecond,
+		TLSClientConfig: rest.TLSClientConfig{CAData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})},
+	}
+	cli, err := NewHyperCli(config.Host, config, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := cli.Client.ContainerInspect(context.Background(), "id"); err == nil || time.Since(start) >= 200*time.Millisecond {
+		t.Errorf("expected the inspection to time out, got %v after %v", err, time.Since(start))
+	}
+	stream, err := cli.Client.ContainerStats(context.Background(), "id", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stream.Close()
+	if data, err := ioutil.ReadAll(stream); err != nil || !strings.Contains(string(data), "2018-03-01") {
+		t.Errorf("expected the stream to be read, got %q, %v", data, err)
+	}
+}
+
+func TestIsStreaming(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"/api/v1/containers/id/json", false},
+		{"/api/v1/containers/id/stats?stream=0", false},
+		{"/api/v1/containers/id/stats?stream=1", true},
+		{"/api/v1/containers/id/logs?stdout=1", false},
+		{"/api/v1/containers/id/logs?follow=1&stdout=1", true},
+		{"/api/v1/containers/id/attach?stream=1", true},
+		{"/api/v1/containers/id/archive?path=/tmp", true},
+		{"/api/v1/exec/id/json", false},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "https://us-west-1.hyper.sh"+test.url, nil)
+		if streaming := isStreaming(req); streaming != test.expected {
+			t.Errorf("%s: expected streaming %t, got %t", test.url, test.expected, streaming)
+		}
+	}
+}
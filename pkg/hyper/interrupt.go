@@ -0,0 +1,98 @@
+package hyper
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	interruptLock sync.Mutex
+	// commandContext is cancelled by Interrupt
+	commandContext, cancelCommand = context.WithCancel(context.Background())
+	// cleanups are run on interrupt, by key
+	cleanups    = map[int]func(){}
+	nextCleanup int
+)
+
+// Context returns the context of the command, cancelled once it is
+// interrupted. The RetryTransports of WrapRetryTransport cancel their requests
+// in flight along with it.
+func Context() context.Context {
+	interruptLock.Lock()
+	defer interruptLock.Unlock()
+	return commandContext
+}
+
+// Interrupt cancels Context, and so the requests in flight of the
+// RetryTransports and the contexts of InterruptibleContext, along with the
+// ones started afterwards. The command decides when it is interrupted, e.g.
+// on a signal, and when it exits.
+func Interrupt() {
+	interruptLock.Lock()
+	defer interruptLock.Unlock()
+	cancelCommand()
+}
+
+// InterruptibleContext returns a context cancelled when pi is interrupted.
+func InterruptibleContext() (context.Context, context.CancelFunc) {
+	return context.WithCancel(Context())
+}
+
+type uninterruptibleKey struct{}
+
+// Uninterruptible returns a context whose requests are not cancelled when pi
+// is interrupted, for the cleanups of OnInterrupt.
+func Uninterruptible(ctx context.Context) context.Context {
+	return context.WithValue(ctx, uninterruptibleKey{}, true)
+}
+
+func isUninterruptible(ctx context.Context) bool {
+	uninterruptible, _ := ctx.Value(uninterruptibleKey{}).(bool)
+	return uninterruptible
+}
+
+// OnInterrupt registers cleanup to be run by RunCleanups when the command
+// does not return once interrupted, e.g. to give back what was allocated for a
+// request being cancelled. Its requests should be Uninterruptible. The
+// returned func unregisters it, once the command cleaned up by itself.
+func OnInterrupt(cleanup func()) (remove func()) {
+	interruptLock.Lock()
+	defer interruptLock.Unlock()
+	key := nextCleanup
+	nextCleanup++
+	cleanups[key] = cleanup
+	return func() {
+		interruptLock.Lock()
+		defer interruptLock.Unlock()
+		delete(cleanups, key)
+	}
+}
+
+// RunCleanups runs the cleanups registered with OnInterrupt, for a command
+// interrupted which does not return, the returned channel is closed once they
+// are done.
+func RunCleanups() <-chan struct{} {
+	interruptLock.Lock()
+	pending := []func(){}
+	for key, cleanup := range cleanups {
+		pending = append(pending, cleanup)
+		delete(cleanups, key)
+	}
+	interruptLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for _, cleanup := range pending {
+			wg.Add(1)
+			go func(cleanup func()) {
+				defer wg.Done()
+				cleanup()
+			}(cleanup)
+		}
+		wg.Wait()
+	}()
+	return done
+}
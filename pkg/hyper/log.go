@@ -0,0 +1,125 @@
+package hyper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// LogTransport logs the requests it sends as curl commands at -v=7, and the
+// status and headers of their responses at -v=8, without the credentials
+// and secrets.
+type LogTransport struct {
+	delegate http.RoundTripper
+}
+
+// WrapLogTransport can be used as rest.Config.WrapTransport.
+func WrapLogTransport(delegate http.RoundTripper) http.RoundTripper {
+	return &LogTransport{delegate: delegate}
+}
+
+func (t *LogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if glog.V(7) {
+		glog.Infof("========================================\n%s\n----------------------------------------\n", curlCommand(req))
+	}
+	started := time.Now()
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil || !glog.V(8) {
+		return resp, err
+	}
+	glog.Infof("Response Status: %s in %v", resp.Status, time.Since(started))
+	glog.Infof("Response Headers:")
+	for name, values := range RedactHeader(resp.Header) {
+		for _, value := range values {
+			glog.Infof("    %s: %s", name, value)
+		}
+	}
+	return resp, err
+}
+
+func (t *LogTransport) CancelRequest(req *http.Request) {
+	if canceler, ok := t.delegate.(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
+func (t *LogTransport) WrappedRoundTripper() http.RoundTripper { return t.delegate }
+
+// curlCommand returns req as a curl command, without its credentials and
+// secrets. The body is left out when it can't be read again.
+func curlCommand(req *http.Request) string {
+	curl := []string{"[REQUEST]: \ncurl -v -k ", fmt.Sprintf("  -X %v ", req.Method)}
+	for name, values := range RedactHeader(req.Header) {
+		curl = append(curl, fmt.Sprintf("  -H \"%v: %v\" ", name, values[0]))
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := ioutil.ReadAll(io.LimitReader(body, TraceBodyLimit))
+			body.Close()
+			if len(data) > 0 {
+				curl = append(curl, fmt.Sprintf("  -d '%s' ", RedactBody(data)))
+			}
+		}
+	}
+	host := req.URL.Host
+	if len(host) == 0 {
+		host = req.Host
+	}
+	curl = append(curl, fmt.Sprintf("  https://%v%v", host, req.URL.RequestURI()))
+	return strings.Join(curl, "\\\n")
+}
+
+// maxBufferedBody is the size up to which bufferedBodyTransport reads the
+// request bodies in memory.
+const maxBufferedBody = 1 << 20
+
+// bufferedBodyTransport reads the request bodies which can't be read again,
+// like the ones of the Hyper API client, so that the requests can be retried,
+// traced, logged and signed again. Larger bodies are streamed.
+type bufferedBodyTransport struct {
+	delegate http.RoundTripper
+}
+
+func (t *bufferedBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return t.delegate.RoundTrip(req)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBufferedBody+1))
+	if err != nil {
+		req.Body.Close()
+		return nil, err
+	}
+	buffered := *req
+	if len(data) > maxBufferedBody {
+		buffered.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), req.Body), req.Body}
+		return t.delegate.RoundTrip(&buffered)
+	}
+	req.Body.Close()
+	buffered.Body = ioutil.NopCloser(bytes.NewReader(data))
+	buffered.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	buffered.ContentLength = int64(len(data))
+	return t.delegate.RoundTrip(&buffered)
+}
+
+func (t *bufferedBodyTransport) CancelRequest(req *http.Request) {
+	if canceler, ok := t.delegate.(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
+func (t *bufferedBodyTransport) WrappedRoundTripper() http.RoundTripper { return t.delegate }
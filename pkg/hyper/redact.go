@@ -0,0 +1,116 @@
+package hyper
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the credentials and secrets of the requests and responses
+// of the Hyper API in the logs and traces.
+const Redacted = "REDACTED"
+
+// secretHeaders are the headers carrying credentials.
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"X-Registry-Auth":     true,
+	"X-Registry-Config":   true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// secretFields are the JSON fields holding secrets, in lower case without
+// dashes and underscores.
+var secretFields = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"secretkey":     true,
+	"token":         true,
+	"auth":          true,
+	"identitytoken": true,
+	"registrytoken": true,
+	"privatekey":    true,
+}
+
+var (
+	sign4Credential = regexp.MustCompile(`Credential=[^/,]*`)
+	sign4Signature  = regexp.MustCompile(`Signature=[0-9a-fA-F]*`)
+	// secretField matches the string values of the secret fields in JSON
+	// that can't be parsed, like truncated bodies.
+	secretField = regexp.MustCompile(`(?i)("(?:password|secret|secret_?key|token|auth|identity_?token|registry_?token|private_?key)"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+)
+
+// RedactHeaderValue returns value without the credentials when name is a header
+// carrying them. The Sign4 Authorization header keeps its algorithm, scope
+// and signed headers, only the access key and signature are removed.
+func RedactHeaderValue(name, value string) string {
+	if !secretHeaders[http.CanonicalHeaderKey(name)] {
+		return value
+	}
+	if strings.HasPrefix(value, "HYPER-HMAC-SHA256 ") {
+		value = sign4Credential.ReplaceAllString(value, "Credential="+Redacted)
+		return sign4Signature.ReplaceAllString(value, "Signature="+Redacted)
+	}
+	if i := strings.Index(value, " "); i > 0 && strings.HasSuffix(http.CanonicalHeaderKey(name), "Authorization") {
+		// keep the scheme, Basic or Bearer
+		return value[:i+1] + Redacted
+	}
+	return Redacted
+}
+
+// RedactHeader returns a copy of header without the credentials.
+func RedactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		for _, value := range values {
+			redacted.Add(name, RedactHeaderValue(name, value))
+		}
+	}
+	return redacted
+}
+
+// RedactBody returns body with the values of the secret fields replaced, along with
+// the data of the kubernetes secrets. Bodies which are not JSON objects or
+// arrays are returned as they are.
+func RedactBody(body []byte) []byte {
+	trimmed := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return body
+	}
+	var obj interface{}
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil || decoder.More() {
+		return secretField.ReplaceAll(body, []byte(`$1"`+Redacted+`"`))
+	}
+	redacted, err := json.Marshal(redactValue(obj))
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		secret := v["kind"] == "Secret"
+		for key, field := range v {
+			name := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+			switch {
+			case secretFields[name] || secret && (key == "data" || key == "stringData"):
+				if field != nil && field != "" {
+					v[key] = Redacted
+				}
+			default:
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return value
+}
@@ -0,0 +1,203 @@
+package hyper
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperhq/client-go/rest"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// RetryBackoff is the backoff between the attempts of an idempotent request,
+// Steps being the number of attempts.
+var RetryBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.5,
+	Steps:    4,
+}
+
+// retriedMethods are the idempotent methods, sending them twice has the same
+// effect as sending them once.
+var retriedMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodHead:   true,
+	http.MethodDelete: true,
+}
+
+// retriedStatusCodes are the transient server errors.
+var retriedStatusCodes = map[int]bool{
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// RetryTransport retries the idempotent requests failing to connect or with a
+// transient server error, waiting a jittered exponential backoff between the
+// attempts. The requests in flight are cancelled along with its context,
+// unless they are Uninterruptible.
+type RetryTransport struct {
+	ctx      context.Context
+	delegate http.RoundTripper
+	backoff  wait.Backoff
+
+	lock sync.Mutex
+	// inFlight cancels the requests being sent, for CancelRequest
+	inFlight map[*http.Request]context.CancelFunc
+}
+
+// NewRetryTransport returns a RetryTransport sending the requests with
+// delegate, until ctx is cancelled.
+func NewRetryTransport(ctx context.Context, delegate http.RoundTripper, backoff wait.Backoff) *RetryTransport {
+	return &RetryTransport{
+		ctx:      ctx,
+		delegate: delegate,
+		backoff:  backoff,
+		inFlight: map[*http.Request]context.CancelFunc{},
+	}
+}
+
+// WrapRetryTransport can be used as rest.Config.WrapTransport, it retries with
+// RetryBackoff until the command is interrupted.
+func WrapRetryTransport(delegate http.RoundTripper) http.RoundTripper {
+	return NewRetryTransport(Context(), delegate, RetryBackoff)
+}
+
+// ChainWrapTransport returns a rest.Config.WrapTransport for config sending
+// the requests through the proxy of the cluster, if any, and applying
+// WrapLogTransport, WrapTraceTransport, the WrapTransport of config if any, WrapRetryTransport
+// then WrapClockSkewTransport. Every attempt of a retried request is traced.
+func ChainWrapTransport(config *rest.Config) func(http.RoundTripper) http.RoundTripper {
+	wrap := config.WrapTransport
+	skew := WrapClockSkewTransport(config)
+	return func(rt http.RoundTripper) http.RoundTripper {
+		rt = WrapTraceTransport(WrapLogTransport(clusterProxyTransport(rt, config)))
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return skew(WrapRetryTransport(rt))
+	}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	if !isUninterruptible(ctx) {
+		go func() {
+			select {
+			case <-t.ctx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	t.lock.Lock()
+	t.inFlight[req] = cancel
+	t.lock.Unlock()
+	resp, err := t.roundTrip(req.WithContext(ctx))
+	t.lock.Lock()
+	delete(t.inFlight, req)
+	t.lock.Unlock()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// the connection belongs to the caller now, it is only closed
+		// on interrupt
+		return resp, nil
+	}
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// CancelRequest cancels req while it is being sent, see RoundTrip for the
+// cancellation of its response.
+func (t *RetryTransport) CancelRequest(req *http.Request) {
+	t.lock.Lock()
+	cancel, found := t.inFlight[req]
+	t.lock.Unlock()
+	if found {
+		cancel()
+	}
+}
+
+func (t *RetryTransport) WrappedRoundTripper() http.RoundTripper { return t.delegate }
+
+func (t *RetryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if !retriedMethods[req.Method] || (req.Body != nil && req.GetBody == nil) {
+		return t.delegate.RoundTrip(req)
+	}
+	backoff := t.backoff
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			retry := *req
+			retry.Body = body
+			req = &retry
+		}
+		resp, err := t.delegate.RoundTrip(req)
+		if attempt >= backoff.Steps || !retriable(req, resp, err) {
+			return resp, err
+		}
+
+		delay := backoff.Duration
+		if backoff.Jitter > 0 {
+			delay = wait.Jitter(delay, backoff.Jitter)
+		}
+		backoff.Duration = time.Duration(float64(backoff.Duration) * backoff.Factor)
+		if err != nil {
+			glog.V(4).Infof("%s %s failed, retrying in %v: %v", req.Method, req.URL.Path, delay, err)
+		} else {
+			glog.V(4).Infof("%s %s returned %s, retrying in %v", req.Method, req.URL.Path, resp.Status, delay)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-req.Cancel:
+			timer.Stop()
+			return nil, context.Canceled
+		}
+	}
+}
+
+// retriable tells whether the outcome of req may be different when sent again.
+func retriable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err == nil {
+		return retriedStatusCodes[resp.StatusCode]
+	}
+	// neither the certificate of the server nor the credentials of the
+	// proxy are going to change
+	return !strings.Contains(err.Error(), "x509:") && !strings.Contains(err.Error(), "Proxy Authentication Required")
+}
+
+// cancelingBody releases the context of the request once its response is read.
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,208 @@
+package hyper
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var testRetryBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+
+// failingServer fails the first failures requests with status, or by closing
+// the connection when status is 0, and records the bodies it receives.
+type failingServer struct {
+	*httptest.Server
+	status   int
+	failures int32
+
+	requests int32
+	lock     sync.Mutex
+	bodies   []string
+}
+
+func newFailingServer(status int, failures int32) *failingServer {
+	s := &failingServer{status: status, failures: failures}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.lock.Lock()
+		s.bodies = append(s.bodies, string(body))
+		s.lock.Unlock()
+		if atomic.AddInt32(&s.requests, 1) > s.failures {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	return s
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		status   int
+		failures int32
+		// the attempts expected, and the status of the last one
+		expectedRequests int32
+		expectedStatus   int
+	}{
+		{"post is not retried", http.MethodPost, http.StatusServiceUnavailable, 1, 1, http.StatusServiceUnavailable},
+		{"get on 502", http.MethodGet, http.StatusBadGateway, 2, 3, http.StatusOK},
+		{"get on 503", http.MethodGet, http.StatusServiceUnavailable, 1, 2, http.StatusOK},
+		{"delete on 503", http.MethodDelete, http.StatusServiceUnavailable, 2, 3, http.StatusOK},
+		{"get up to steps", http.MethodGet, http.StatusServiceUnavailable, 5, 3, http.StatusServiceUnavailable},
+		{"get on a client error", http.MethodGet, http.StatusNotFound, 1, 1, http.StatusNotFound},
+		{"get on a connection reset", http.MethodGet, 0, 2, 3, http.StatusOK},
+		{"delete on a connection reset", http.MethodDelete, 0, 1, 2, http.StatusOK},
+	}
+	for _, test := range tests {
+		server := newFailingServer(test.status, test.failures)
+		transport := NewRetryTransport(context.Background(), &http.Transport{DisableKeepAlives: true}, testRetryBackoff)
+		req, _ := http.NewRequest(test.method, server.URL, nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode != test.expectedStatus {
+				t.Errorf("%s: expected status %d, got %d", test.name, test.expectedStatus, resp.StatusCode)
+			}
+		}
+		if requests := atomic.LoadInt32(&server.requests); requests != test.expectedRequests {
+			t.Errorf("%s: expected %d requests, got %d", test.name, test.expectedRequests, requests)
+		}
+		server.Close()
+	}
+}
+
+func TestRetryTransportReplaysBody(t *testing.T) {
+	server := newFailingServer(http.StatusServiceUnavailable, 2)
+	defer server.Close()
+
+	transport := NewRetryTransport(context.Background(), http.DefaultTransport, testRetryBackoff)
+	// http.NewRequest sets GetBody for a bytes.Buffer
+	req, _ := http.NewRequest(http.MethodDelete, server.URL, bytes.NewBufferString(`{"gracePeriodSeconds":0}`))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	expected := []string{`{"gracePeriodSeconds":0}`, `{"gracePeriodSeconds":0}`, `{"gracePeriodSeconds":0}`}
+	if strings.Join(server.bodies, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected the body to be sent with every attempt, got %q", server.bodies)
+	}
+}
+
+func TestRetryTransportDoesNotRetryCertificateErrors(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// the failed handshakes are expected
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	handshakes := int32(0)
+	transport := NewRetryTransport(context.Background(), roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&handshakes, 1)
+		return (&http.Transport{DisableKeepAlives: true}).RoundTrip(req)
+	}), testRetryBackoff)
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := transport.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "x509:") {
+		t.Fatalf("expected a certificate error, got %v", err)
+	}
+	if handshakes != 1 {
+		t.Errorf("expected a single attempt, got %d", handshakes)
+	}
+}
+
+func TestRetryTransportInterrupt(t *testing.T) {
+	ctx, interrupt := context.WithCancel(context.Background())
+
+	received := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	transport := NewRetryTransport(ctx, http.DefaultTransport, testRetryBackoff)
+	go func() {
+		<-received
+		interrupt()
+	}()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	done := make(chan error)
+	go func() {
+		_, err := transport.RoundTrip(req)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected the interrupted request to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the request in flight was not cancelled")
+	}
+}
+
+func TestRetryTransportUninterruptible(t *testing.T) {
+	ctx, interrupt := context.WithCancel(context.Background())
+	interrupt()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	transport := NewRetryTransport(ctx, http.DefaultTransport, testRetryBackoff)
+	req, _ := http.NewRequest(http.MethodDelete, server.URL, nil)
+	resp, err := transport.RoundTrip(req.WithContext(Uninterruptible(req.Context())))
+	if err != nil {
+		t.Fatalf("expected the uninterruptible request to be sent after the interrupt, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestOnInterrupt(t *testing.T) {
+	ran := make(chan string, 2)
+	OnInterrupt(func() { ran <- "kept" })
+	remove := OnInterrupt(func() { ran <- "removed" })
+	remove()
+
+	select {
+	case <-RunCleanups():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the cleanups did not finish")
+	}
+	close(ran)
+	cleanups := []string{}
+	for cleanup := range ran {
+		cleanups = append(cleanups, cleanup)
+	}
+	if len(cleanups) != 1 || cleanups[0] != "kept" {
+		t.Errorf("expected only the kept cleanup to run, got %v", cleanups)
+	}
+	// they run once
+	<-RunCleanups()
+}
@@ -0,0 +1,25 @@
+package hyper
+
+import (
+	"encoding/json"
+
+	"github.com/hyperhq/hyper-api/types"
+
+	"golang.org/x/net/context"
+)
+
+// ContainerStats returns one sample of the resource usage of the container
+// with id. The CPU usage of the previous sample is in PreCPUStats.
+func (cli *HyperCli) ContainerStats(ctx context.Context, id string) (*types.StatsJSON, error) {
+	body, err := cli.Client.ContainerStats(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	stats := &types.StatsJSON{}
+	if err := json.NewDecoder(body).Decode(stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,388 @@
+package hyper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperhq/pi"
+)
+
+// TraceBodyLimit is the number of bytes of the request and response bodies
+// recorded in the traces, the rest is left out.
+const TraceBodyLimit = 64 << 10
+
+// Tracer records the exchanges with the Hyper API as HAR entries, with the
+// credentials and secrets redacted. Traces to a .har file are HAR logs
+// rewritten after every entry, so that they are complete when pi exits
+// early, the other files get one entry per line.
+type Tracer struct {
+	lock    sync.Mutex
+	path    string
+	file    *os.File
+	har     bool
+	entries []*harEntry
+}
+
+var (
+	tracerLock sync.Mutex
+	tracer     *Tracer
+)
+
+// StartTrace records the requests of the transports wrapped by
+// WrapTraceTransport to path from now on. Starting the trace to the same path
+// again does nothing.
+func StartTrace(path string) error {
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+	if tracer != nil {
+		if tracer.path == path {
+			return nil
+		}
+		return fmt.Errorf("already tracing the requests to %s", tracer.path)
+	}
+	// the bodies may hold data of the user, the trace is private
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("open trace file: %v", err)
+	}
+	tracer = &Tracer{
+		path: path,
+		file: file,
+		har:  strings.EqualFold(filepath.Ext(path), ".har"),
+	}
+	return nil
+}
+
+func currentTracer() *Tracer {
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+	return tracer
+}
+
+// WrapTraceTransport records the requests of delegate with the current
+// trace, it returns delegate when there is none.
+func WrapTraceTransport(delegate http.RoundTripper) http.RoundTripper {
+	t := currentTracer()
+	if t == nil {
+		return delegate
+	}
+	return &TraceTransport{delegate: delegate, tracer: t}
+}
+
+// TraceTransport records the requests it sends and their responses, the
+// entry of a response with a body is written once the body is read or closed.
+type TraceTransport struct {
+	delegate http.RoundTripper
+	tracer   *Tracer
+}
+
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := newHAREntry(req)
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil {
+		entry.Comment = err.Error()
+	}
+	if resp == nil {
+		t.tracer.record(entry)
+		return resp, err
+	}
+	entry.setResponse(resp)
+	// the bodies of upgraded connections are streams in both directions
+	if resp.StatusCode == http.StatusSwitchingProtocols || resp.Body == nil || resp.Body == http.NoBody {
+		t.tracer.record(entry)
+		return resp, err
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, entry: entry, tracer: t.tracer}
+	return resp, err
+}
+
+func (t *TraceTransport) CancelRequest(req *http.Request) {
+	if canceler, ok := t.delegate.(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
+func (t *TraceTransport) WrappedRoundTripper() http.RoundTripper { return t.delegate }
+
+// tracedBody captures the first TraceBodyLimit bytes of a response body,
+// and records its entry at the end of the body.
+type tracedBody struct {
+	io.ReadCloser
+	entry  *harEntry
+	tracer *Tracer
+	body   bytes.Buffer
+	size   int
+	once   sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	if room := TraceBodyLimit - b.body.Len(); room > 0 {
+		if room > n {
+			room = n
+		}
+		b.body.Write(p[:room])
+	}
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+func (b *tracedBody) done() {
+	b.once.Do(func() {
+		b.entry.Response.Content.setBody(b.body.Bytes(), b.size)
+		b.entry.Response.BodySize = b.size
+		b.tracer.record(b.entry)
+	})
+}
+
+func (t *Tracer) record(entry *harEntry) {
+	entry.Time = float64(time.Since(entry.started)) / float64(time.Millisecond)
+	entry.Timings.Wait = entry.Time
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.har {
+		data, err := json.Marshal(entry)
+		if err == nil {
+			_, err = t.file.Write(append(data, '\n'))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing the trace of %s %s: %v\n", entry.Request.Method, entry.Request.URL, err)
+		}
+		return
+	}
+	t.entries = append(t.entries, entry)
+	log := harLog{Log: harLogContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "pi", Version: pi.Version},
+		Entries: t.entries,
+	}}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err == nil {
+		if _, err = t.file.Seek(0, io.SeekStart); err == nil {
+			if err = t.file.Truncate(0); err == nil {
+				_, err = t.file.Write(data)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing the trace of %s %s: %v\n", entry.Request.Method, entry.Request.URL, err)
+	}
+}
+
+// The HAR 1.2 log, see http://www.softwareishard.com/blog/har-12-spec/.
+type harLog struct {
+	Log harLogContent `json:"log"`
+}
+
+type harLogContent struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	started time.Time
+
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// newHAREntry records req, signed, with its body when it can be read again.
+func newHAREntry(req *http.Request) *harEntry {
+	started := time.Now()
+	u := *req.URL
+	if len(u.Scheme) == 0 {
+		u.Scheme = "https"
+	}
+	if len(u.Host) == 0 {
+		u.Host = req.Host
+	}
+	u.User = nil
+	entry := &harEntry{
+		started:         started,
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         u.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}
+	if len(entry.Request.HTTPVersion) == 0 {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+	for name, values := range u.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: name, Value: value})
+		}
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		entry.Request.BodySize = 0
+		return entry
+	}
+	postData := &harPostData{MimeType: req.Header.Get("Content-Type")}
+	entry.Request.PostData = postData
+	if req.GetBody == nil {
+		postData.Comment = "the body is a stream, it is not recorded"
+		return entry
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		postData.Comment = fmt.Sprintf("the body could not be read: %v", err)
+		return entry
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(body, TraceBodyLimit+1))
+	if err != nil {
+		postData.Comment = fmt.Sprintf("the body could not be read: %v", err)
+		return entry
+	}
+	if req.ContentLength > 0 {
+		entry.Request.BodySize = int(req.ContentLength)
+	} else if len(data) <= TraceBodyLimit {
+		entry.Request.BodySize = len(data)
+	}
+	if len(data) > TraceBodyLimit {
+		data = data[:TraceBodyLimit]
+		postData.Comment = fmt.Sprintf("truncated to the first %d bytes", TraceBodyLimit)
+	}
+	if utf8.Valid(data) {
+		postData.Text = string(RedactBody(data))
+	} else {
+		postData.Text = base64.StdEncoding.EncodeToString(data)
+		postData.Comment = strings.TrimPrefix(postData.Comment+", base64 encoded", ", ")
+	}
+	return entry
+}
+
+func (e *harEntry) setResponse(resp *http.Response) {
+	e.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(resp.Header),
+		Content:     harContent{MimeType: resp.Header.Get("Content-Type")},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		e.Response.Content.Comment = "the connection is hijacked, the streams are not recorded"
+	}
+}
+
+// setBody records the first bytes of a body of size bytes.
+func (c *harContent) setBody(data []byte, size int) {
+	c.Size = size
+	if size > len(data) {
+		c.Comment = fmt.Sprintf("truncated to the first %d bytes", len(data))
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.MimeType)
+	switch {
+	case len(data) == 0:
+	case utf8.Valid(data) && mediaType != "application/octet-stream":
+		c.Text = string(RedactBody(data))
+	default:
+		c.Text = base64.StdEncoding.EncodeToString(data)
+		c.Encoding = "base64"
+	}
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range RedactHeader(header) {
+		for _, value := range values {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
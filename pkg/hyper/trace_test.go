@@ -0,0 +1,236 @@
+package hyper
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/golang/glog"
+	"github.com/hyperhq/hyper-api/signature"
+)
+
+// startTestTrace starts a trace to a file called name in a temporary
+// directory, the returned function stops it and returns the trace.
+func startTestTrace(t *testing.T, name string) func() []byte {
+	dir, err := ioutil.TempDir("", "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := StartTrace(path); err != nil {
+		t.Fatal(err)
+	}
+	return func() []byte {
+		tracerLock.Lock()
+		tracer.file.Close()
+		tracer = nil
+		tracerLock.Unlock()
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.RemoveAll(dir)
+		return data
+	}
+}
+
+func TestTraceRedactsSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"kind":"Secret","metadata":{"name":"s1"},"data":{"password":"aHVudGVyMg=="}}`)
+	}))
+	defer server.Close()
+
+	stop := startTestTrace(t, "trace.har")
+	client := &http.Client{Transport: WrapTraceTransport(http.DefaultTransport)}
+
+	body := `{"kind":"Secret","metadata":{"name":"s1"},"stringData":{"password":"hunter2"}}`
+	req, err := http.NewRequest("POST", server.URL+"/api/v1/namespaces/default/secrets", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Registry-Auth", "eyJwYXNzd29yZCI6Imh1bnRlcjIifQ==")
+	req = signature.Sign4("ak-1234", "sk-5678", req, "gcp-us-central1")
+	authorization := req.Header.Get("Authorization")
+	sign := authorization[strings.LastIndex(authorization, "Signature=")+len("Signature="):]
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	login := `{"username":"me","password":"hunter2","auth":"bWU6aHVudGVyMg==","email":"me@example.com"}`
+	req, err = http.NewRequest("POST", server.URL+"/api/v1/auth", bytes.NewReader([]byte(login)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Basic bWU6aHVudGVyMg==")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	trace := stop()
+	for _, secret := range []string{"hunter2", "aHVudGVyMg==", "bWU6aHVudGVyMg==", "eyJwYXNzd29yZCI6", "ak-1234", sign} {
+		if bytes.Contains(trace, []byte(secret)) {
+			t.Errorf("the trace leaks %q:\n%s", secret, trace)
+		}
+	}
+
+	har := harLog{}
+	if err := json.Unmarshal(trace, &har); err != nil {
+		t.Fatalf("the trace is not a HAR log: %v\n%s", err, trace)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(har.Log.Entries))
+	}
+	entry := har.Log.Entries[0]
+	if entry.Response.Status != http.StatusOK || !strings.Contains(entry.Response.Content.Text, `"name":"s1"`) {
+		t.Errorf("unexpected response %+v", entry.Response)
+	}
+	if entry.Request.PostData == nil || !strings.Contains(entry.Request.PostData.Text, `"stringData":"REDACTED"`) {
+		t.Errorf("expected the redacted request body, got %+v", entry.Request.PostData)
+	}
+	for _, header := range entry.Request.Headers {
+		if header.Name == "Authorization" && !strings.Contains(header.Value, "/gcp-us-central1/hyper/hyper_request") {
+			t.Errorf("expected the scope of the signature in %q", header.Value)
+		}
+	}
+	if text := har.Log.Entries[1].Request.PostData.Text; !strings.Contains(text, "me@example.com") {
+		t.Errorf("expected the fields which are not secrets in the body, got %q", text)
+	}
+}
+
+func TestTraceTruncatesBodies(t *testing.T) {
+	large := `{"password":"hunter2","data":"` + strings.Repeat("x", TraceBodyLimit) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, large)
+	}))
+	defer server.Close()
+
+	stop := startTestTrace(t, "trace.jsonl")
+	client := &http.Client{Transport: WrapTraceTransport(http.DefaultTransport)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != large {
+		t.Errorf("the traced body was altered")
+	}
+
+	trace := stop()
+	if bytes.Contains(trace, []byte("hunter2")) {
+		t.Errorf("the truncated body leaks the password")
+	}
+	lines := strings.Split(strings.TrimSpace(string(trace)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a line per entry, got %d", len(lines))
+	}
+	entry := harEntry{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	content := entry.Response.Content
+	if content.Size != len(large) || len(content.Text) >= len(large) || !strings.Contains(content.Comment, "truncated") {
+		t.Errorf("expected the body truncated to %d bytes, got size %d, %d bytes, comment %q", TraceBodyLimit, content.Size, len(content.Text), content.Comment)
+	}
+}
+
+// captureStderr returns what glog wrote to stderr while fn ran.
+func captureStderr(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		done <- data
+	}()
+	fn()
+	glog.Flush()
+	os.Stderr = stderr
+	w.Close()
+	return string(<-done)
+}
+
+func TestLogTransportRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	defer flag.Set("logtostderr", flag.Lookup("logtostderr").Value.String())
+	defer flag.Set("v", flag.Lookup("v").Value.String())
+	flag.Set("logtostderr", "true")
+
+	for _, level := range []string{"7", "8", "9"} {
+		flag.Set("v", level)
+		output := captureStderr(t, func() {
+			req, err := http.NewRequest("GET", server.URL+"/api/v1/info", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Registry-Auth", "registry-secret")
+			req = signature.Sign4("ak-1234", "sk-5678", req, "gcp-us-central1")
+			resp, err := WrapLogTransport(http.DefaultTransport).RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		})
+		if !strings.Contains(output, "Authorization") {
+			t.Errorf("v=%s: expected the request headers or curl command in the log, got:\n%s", level, output)
+		}
+		for _, secret := range []string{"ak-1234", "registry-secret"} {
+			if strings.Contains(output, secret) {
+				t.Errorf("v=%s: the log leaks %q:\n%s", level, secret, output)
+			}
+		}
+		if signature := regexp.MustCompile(`Signature=[0-9a-f]+`).FindString(output); len(signature) > 0 {
+			t.Errorf("v=%s: the log leaks the signature %q:\n%s", level, signature, output)
+		}
+	}
+}
+
+func TestTraceStreamedRequestBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	stop := startTestTrace(t, "trace.json")
+	// like the bodies of the Hyper API client, the body can't be read again
+	req, err := http.NewRequest("POST", server.URL+"/api/v1/containers/create", ioutil.NopCloser(strings.NewReader(`{"Image":"nginx"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&bufferedBodyTransport{delegate: WrapTraceTransport(http.DefaultTransport)}).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	trace := stop()
+
+	entry := harEntry{}
+	if err := json.Unmarshal(trace, &entry); err != nil {
+		t.Fatalf("invalid trace entry %s: %v", trace, err)
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"Image":"nginx"}` {
+		t.Errorf("expected the request body in the trace, got %+v", entry.Request.PostData)
+	}
+}
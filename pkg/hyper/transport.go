@@ -0,0 +1,400 @@
+package hyper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hyperhq/client-go/rest"
+	api "github.com/hyperhq/client-go/tools/clientcmd/api/hyper"
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+
+	"github.com/golang/glog"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// ResourceTransport serves the hyper.sh API group for the generic REST clients.
+// Volumes and fips are not kubernetes resources on the server side, so requests
+// to /apis/hyper.sh/v1/{volumes,fips} are translated to the Hyper endpoints
+// and the results are returned as kubernetes style objects and statuses.
+type ResourceTransport struct {
+	conn *Conn
+}
+
+// NewResourceTransport returns a ResourceTransport talking to the Hyper endpoints of config.
+func NewResourceTransport(config *rest.Config) *ResourceTransport {
+	return &ResourceTransport{conn: NewConn(config)}
+}
+
+// WrapResourceTransport can be used as rest.Config.WrapTransport for the hyper.sh API group.
+// The Hyper endpoints are reached with the WrapTransport config has when called,
+// before it is replaced.
+func WrapResourceTransport(config *rest.Config) func(http.RoundTripper) http.RoundTripper {
+	conn := *config
+	return func(http.RoundTripper) http.RoundTripper {
+		return NewResourceTransport(&conn)
+	}
+}
+
+// CancelRequest does nothing, the requests to the Hyper endpoints time out
+// with the timeout of the config, and are cancelled on interrupt.
+func (t *ResourceTransport) CancelRequest(req *http.Request) {}
+
+var resourcePathPrefix = "/apis/" + hyperapi.SchemeGroupVersionV1.String() + "/"
+
+func (t *ResourceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.Path, resourcePathPrefix) {
+		return errorResponse(req, apierrors.NewNotFound(hyperapi.Resource(""), req.URL.Path))
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, resourcePathPrefix), "/"), "/")
+	resource, name := parts[0], ""
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 2 || resource == "watch" || req.URL.Query().Get("watch") == "true" {
+		return errorResponse(req, apierrors.NewMethodNotSupported(hyperapi.Resource(resource), "watch"))
+	}
+	glog.V(4).Infof("hyper resource request: %s %s %q", req.Method, resource, name)
+
+	var (
+		obj  runtime.Object
+		code int
+		err  error
+	)
+	switch resource {
+	case "volumes":
+		obj, code, err = t.volumes(req, name)
+	case "fips":
+		obj, code, err = t.fips(req, name)
+	default:
+		err = apierrors.NewNotFound(hyperapi.Resource(resource), name)
+	}
+	if err != nil {
+		return errorResponse(req, err)
+	}
+	return objectResponse(req, code, obj)
+}
+
+func (t *ResourceTransport) volumes(req *http.Request, name string) (runtime.Object, int, error) {
+	gr := hyperapi.Resource("volumes")
+	zone, err := volumeZone(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case req.Method == http.MethodGet && name == "":
+		if err := rejectLabelSelector(req, gr); err != nil {
+			return nil, 0, err
+		}
+		var vols []api.VolumeResponse
+		if err := t.do(req.Method, "/api/v1/hyper/volumes?zone="+url.QueryEscape(zone), nil, http.StatusOK, &vols, gr, name); err != nil {
+			return nil, 0, err
+		}
+		return VolumeListFromResponse(vols), http.StatusOK, nil
+	case req.Method == http.MethodGet:
+		vol := &api.VolumeResponse{}
+		if err := t.do(req.Method, volumeEndpoint(name, zone), nil, http.StatusOK, vol, gr, name); err != nil {
+			return nil, 0, err
+		}
+		return VolumeFromResponse(vol), http.StatusOK, nil
+	case req.Method == http.MethodDelete && name != "":
+		if err := t.do(req.Method, volumeEndpoint(name, zone), nil, http.StatusNoContent, nil, gr, name); err != nil {
+			return nil, 0, err
+		}
+		return successStatus(), http.StatusOK, nil
+	case req.Method == http.MethodPost && name == "":
+		volume := &hyperapi.Volume{}
+		if err := decodeBody(req, volume); err != nil {
+			return nil, 0, err
+		}
+		if volume.Spec.Size < 1 {
+			return nil, 0, apierrors.NewBadRequest("volume size should be >=1 (GB)")
+		}
+		body := &api.VolumeCreateRequest{Name: volume.Name, Zone: volume.Spec.Zone, Size: volume.Spec.Size}
+		created := &api.VolumeResponse{}
+		if err := t.do(req.Method, "/api/v1/hyper/volumes", body, http.StatusCreated, created, gr, volume.Name); err != nil {
+			return nil, 0, err
+		}
+		return VolumeFromResponse(created), http.StatusCreated, nil
+	}
+	return nil, 0, apierrors.NewMethodNotSupported(gr, strings.ToLower(req.Method))
+}
+
+// volumeZone returns the zone a volume request is scoped to, taken either from
+// the zone parameter or from a spec.zone field selector.
+func volumeZone(req *http.Request) (string, error) {
+	query := req.URL.Query()
+	if zone := query.Get("zone"); len(zone) > 0 {
+		return zone, nil
+	}
+	selector, err := fields.ParseSelector(query.Get("fieldSelector"))
+	if err != nil {
+		return "", apierrors.NewBadRequest(err.Error())
+	}
+	for _, r := range selector.Requirements() {
+		if r.Field != "spec.zone" || r.Operator == selection.NotEquals {
+			return "", apierrors.NewBadRequest(fmt.Sprintf("field selector %q is not supported for volumes", r.Field+string(r.Operator)+r.Value))
+		}
+	}
+	zone, _ := selector.RequiresExactMatch("spec.zone")
+	return zone, nil
+}
+
+func volumeEndpoint(name, zone string) string {
+	return fmt.Sprintf("/api/v1/hyper/volumes/%s?zone=%s", url.PathEscape(name), url.QueryEscape(zone))
+}
+
+func (t *ResourceTransport) fips(req *http.Request, name string) (runtime.Object, int, error) {
+	gr := hyperapi.Resource("fips")
+	switch {
+	case req.Method == http.MethodGet && name == "":
+		if err := rejectLabelSelector(req, gr); err != nil {
+			return nil, 0, err
+		}
+		list, err := t.listFips()
+		if err != nil {
+			return nil, 0, err
+		}
+		return list, http.StatusOK, nil
+	case req.Method == http.MethodGet:
+		fip, err := t.getFip(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		return fip, http.StatusOK, nil
+	case req.Method == http.MethodDelete && name != "":
+		fip, err := t.getFip(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := t.do(req.Method, fipEndpoint(fip.Name), nil, http.StatusNoContent, nil, gr, name); err != nil {
+			return nil, 0, err
+		}
+		return successStatus(), http.StatusOK, nil
+	case req.Method == http.MethodPost && name == "":
+		fip := &hyperapi.Fip{}
+		if err := decodeBody(req, fip); err != nil {
+			return nil, 0, err
+		}
+		alias, err := fipAlias(fip)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(alias) > 0 {
+			if _, err := t.getFip(alias); err == nil {
+				return nil, 0, apierrors.NewAlreadyExists(gr, alias)
+			} else if !apierrors.IsNotFound(err) {
+				return nil, 0, err
+			}
+		}
+		var allocated []api.FipResponse
+		if err := t.do(req.Method, "/api/v1/hyper/fips?count=1", nil, http.StatusCreated, &allocated, gr, alias); err != nil {
+			return nil, 0, err
+		}
+		if len(allocated) != 1 {
+			return nil, 0, apierrors.NewInternalError(fmt.Errorf("expected 1 allocated fip, got %d", len(allocated)))
+		}
+		created := FipFromResponse(&allocated[0])
+		if len(alias) > 0 {
+			if err := t.nameFip(created.Name, alias); err != nil {
+				// don't leak the fip if it can't be named
+				if releaseErr := t.do(http.MethodDelete, fipEndpoint(created.Name), nil, http.StatusNoContent, nil, gr, created.Name); releaseErr != nil {
+					glog.Warningf("failed to release fip %s: %v", created.Name, releaseErr)
+				}
+				return nil, 0, err
+			}
+			created.Spec.Name = alias
+		}
+		return created, http.StatusCreated, nil
+	case req.Method == http.MethodPut && name != "":
+		fip := &hyperapi.Fip{}
+		if err := decodeBody(req, fip); err != nil {
+			return nil, 0, err
+		}
+		current, err := t.getFip(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		if fip.Spec.Name != current.Spec.Name {
+			if err := t.nameFip(current.Name, fip.Spec.Name); err != nil {
+				return nil, 0, err
+			}
+			current.Spec.Name = fip.Spec.Name
+		}
+		return current, http.StatusOK, nil
+	}
+	return nil, 0, apierrors.NewMethodNotSupported(gr, strings.ToLower(req.Method))
+}
+
+// fipAlias returns the name a new fip should be given. The IP of a fip is only known
+// once it is allocated, so a manifest names it by metadata.name instead, which is
+// then kept as the alias.
+func fipAlias(fip *hyperapi.Fip) (string, error) {
+	if len(fip.Name) == 0 || fip.Name == fip.Spec.Name {
+		return fip.Spec.Name, nil
+	}
+	if net.ParseIP(fip.Name) != nil {
+		return "", apierrors.NewBadRequest(fmt.Sprintf("fip %s can not be requested by address, use metadata.name as its name instead", fip.Name))
+	}
+	if len(fip.Spec.Name) > 0 {
+		return "", apierrors.NewBadRequest(fmt.Sprintf("metadata.name %q and spec.name %q of fip differ", fip.Name, fip.Spec.Name))
+	}
+	return fip.Name, nil
+}
+
+func fipEndpoint(ip string) string {
+	return "/api/v1/hyper/fips/" + url.QueryEscape(ip)
+}
+
+func (t *ResourceTransport) listFips() (*hyperapi.FipList, error) {
+	var fips []api.FipResponse
+	if err := t.do(http.MethodGet, "/api/v1/hyper/fips", nil, http.StatusOK, &fips, hyperapi.Resource("fips"), ""); err != nil {
+		return nil, err
+	}
+	return FipListFromResponse(fips), nil
+}
+
+// getFip returns the fip with the given IP address. Names given with
+// `pi name fip` are accepted as well.
+func (t *ResourceTransport) getFip(name string) (*hyperapi.Fip, error) {
+	if net.ParseIP(name) == nil {
+		list, err := t.listFips()
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			if list.Items[i].Spec.Name == name {
+				return &list.Items[i], nil
+			}
+		}
+		return nil, apierrors.NewNotFound(hyperapi.Resource("fips"), name)
+	}
+	fip := &api.FipResponse{}
+	if err := t.do(http.MethodGet, fipEndpoint(name), nil, http.StatusOK, fip, hyperapi.Resource("fips"), name); err != nil {
+		return nil, err
+	}
+	return FipFromResponse(fip), nil
+}
+
+func (t *ResourceTransport) nameFip(ip, name string) error {
+	return t.do(http.MethodPost, fipEndpoint(ip), &api.FipRenameRequest{Name: name}, http.StatusNoContent, nil, hyperapi.Resource("fips"), ip)
+}
+
+// do sends a request to a Hyper endpoint, and decodes the response into out.
+// Unexpected status codes are turned into kubernetes API errors.
+func (t *ResourceTransport) do(method, endpoint string, in interface{}, expected int, out interface{}, gr schema.GroupResource, name string) error {
+	var (
+		result string
+		code   int
+		err    error
+	)
+	if in != nil {
+		data, merr := json.Marshal(in)
+		if merr != nil {
+			return merr
+		}
+		result, code, err = t.conn.SockRequest(method, endpoint, bytes.NewReader(data), "application/json")
+	} else {
+		result, code, err = t.conn.SockRequest(method, endpoint, nil, "")
+	}
+	if err != nil {
+		return err
+	}
+	if code == http.StatusNotFound && len(name) > 0 {
+		return apierrors.NewNotFound(gr, name)
+	}
+	if code != expected {
+		return apierrors.NewGenericServerResponse(code, strings.ToLower(method), gr, name, errorMessage(result), 0, false)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal([]byte(result), out)
+}
+
+// errorMessage extracts the message of an error returned by the Hyper API.
+func errorMessage(body string) string {
+	msg := struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal([]byte(body), &msg); err == nil && len(msg.Message) > 0 {
+		return msg.Message
+	}
+	return strings.TrimSpace(body)
+}
+
+// rejectLabelSelector fails the requests selecting the resources of gr by
+// label, the volumes and fips have no labels so that nothing would match.
+func rejectLabelSelector(req *http.Request, gr schema.GroupResource) error {
+	selector, err := labels.Parse(req.URL.Query().Get("labelSelector"))
+	if err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+	if selector.Empty() {
+		return nil
+	}
+	return apierrors.NewBadRequest(fmt.Sprintf("%s have no labels, they can't be selected with the label selector %q", gr.Resource, selector.String()))
+}
+
+func decodeBody(req *http.Request, into runtime.Object) error {
+	if req.Body == nil {
+		return apierrors.NewBadRequest("request body is required")
+	}
+	defer req.Body.Close()
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, into); err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+	return nil
+}
+
+func successStatus() *metav1.Status {
+	return &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusSuccess,
+	}
+}
+
+func errorResponse(req *http.Request, err error) (*http.Response, error) {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok {
+		return nil, err
+	}
+	s := status.Status()
+	s.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+	return objectResponse(req, int(s.Code), &s)
+}
+
+func objectResponse(req *http.Request, code int, obj runtime.Object) (*http.Response, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
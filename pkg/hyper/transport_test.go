@@ -0,0 +1,407 @@
+package hyper
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hyperhq/client-go/rest"
+	api "github.com/hyperhq/client-go/tools/clientcmd/api/hyper"
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fakeHyper is a Hyper API stand-in serving the volume and fip endpoints, it
+// records the requests it gets.
+type fakeHyper struct {
+	lock     sync.Mutex
+	volumes  []api.VolumeResponse
+	fips     []api.FipResponse
+	requests []string
+}
+
+func (f *fakeHyper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+
+	reply := func(code int, obj interface{}) {
+		w.WriteHeader(code)
+		if obj != nil {
+			json.NewEncoder(w).Encode(obj)
+		}
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/hyper/")
+	switch {
+	case path == "volumes" && r.Method == http.MethodGet:
+		volumes := []api.VolumeResponse{}
+		for _, volume := range f.volumes {
+			if zone := r.URL.Query().Get("zone"); len(zone) == 0 || volume.Zone == zone {
+				volumes = append(volumes, volume)
+			}
+		}
+		reply(http.StatusOK, volumes)
+	case path == "volumes" && r.Method == http.MethodPost:
+		create := api.VolumeCreateRequest{}
+		json.NewDecoder(r.Body).Decode(&create)
+		volume := api.VolumeResponse{Name: create.Name, Size: create.Size, Zone: create.Zone}
+		f.volumes = append(f.volumes, volume)
+		reply(http.StatusCreated, volume)
+	case strings.HasPrefix(path, "volumes/"):
+		name := strings.TrimPrefix(path, "volumes/")
+		for i, volume := range f.volumes {
+			if volume.Name != name {
+				continue
+			}
+			if r.Method == http.MethodDelete {
+				f.volumes = append(f.volumes[:i], f.volumes[i+1:]...)
+				reply(http.StatusNoContent, nil)
+			} else {
+				reply(http.StatusOK, volume)
+			}
+			return
+		}
+		reply(http.StatusNotFound, map[string]string{"message": "No such volume: " + name})
+	case path == "fips" && r.Method == http.MethodGet:
+		reply(http.StatusOK, f.fips)
+	case path == "fips" && r.Method == http.MethodPost:
+		fip := api.FipResponse{Fip: "10.0.0.9"}
+		f.fips = append(f.fips, fip)
+		reply(http.StatusCreated, []api.FipResponse{fip})
+	case strings.HasPrefix(path, "fips/"):
+		ip := strings.TrimPrefix(path, "fips/")
+		for i := range f.fips {
+			if f.fips[i].Fip != ip {
+				continue
+			}
+			switch r.Method {
+			case http.MethodGet:
+				reply(http.StatusOK, f.fips[i])
+			case http.MethodPost:
+				rename := api.FipRenameRequest{}
+				json.NewDecoder(r.Body).Decode(&rename)
+				f.fips[i].Name = rename.Name
+				reply(http.StatusNoContent, nil)
+			case http.MethodDelete:
+				f.fips = append(f.fips[:i], f.fips[i+1:]...)
+				reply(http.StatusNoContent, nil)
+			}
+			return
+		}
+		reply(http.StatusNotFound, map[string]string{"message": "No such fip: " + ip})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeHyper) Requests() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+// newResourceClient returns a client of the hyper.sh API group of the Hyper
+// API stand-in f.
+func newResourceClient(f *fakeHyper) (*http.Client, func()) {
+	server := httptest.NewTLSServer(f)
+	config := &rest.Config{
+		Host:      server.URL,
+		Region:    "gcp-us-central1",
+		AccessKey: "ak",
+		SecretKey: "sk",
+	}
+	config.CAData = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return &http.Client{Transport: NewResourceTransport(config)}, server.Close
+}
+
+func resourceRequest(t *testing.T, client *http.Client, method, path string, body runtime.Object, into interface{}) int {
+	var data io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, "https://hyper/apis/hyper.sh/v1/"+path, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if into != nil {
+		if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestResourceTransportVolumes(t *testing.T) {
+	f := &fakeHyper{volumes: []api.VolumeResponse{
+		{Name: "vol1", Size: 10, Zone: "gcp-us-central1-a", Pod: "web"},
+		{Name: "vol2", Size: 20, Zone: "gcp-us-central1-b"},
+	}}
+	client, stop := newResourceClient(f)
+	defer stop()
+
+	list := &hyperapi.VolumeList{}
+	if code := resourceRequest(t, client, "GET", "volumes", nil, list); code != http.StatusOK || len(list.Items) != 2 {
+		t.Errorf("expected the 2 volumes, got %d %+v", code, list.Items)
+	}
+	if requests := f.Requests(); len(requests) != 1 || requests[0] != "GET /api/v1/hyper/volumes?zone=" {
+		t.Errorf("unexpected requests %v", requests)
+	}
+
+	volume := &hyperapi.Volume{}
+	if code := resourceRequest(t, client, "GET", "volumes/vol1", nil, volume); code != http.StatusOK || volume.Spec.Size != 10 || volume.Status.Pod != "web" {
+		t.Errorf("expected vol1, got %d %+v", code, volume)
+	}
+
+	create := &hyperapi.Volume{ObjectMeta: metav1.ObjectMeta{Name: "vol3"}, Spec: hyperapi.VolumeSpec{Size: 30, Zone: "gcp-us-central1-a"}}
+	created := &hyperapi.Volume{}
+	if code := resourceRequest(t, client, "POST", "volumes", create, created); code != http.StatusCreated || created.Name != "vol3" || created.Spec.Size != 30 {
+		t.Errorf("expected vol3 created, got %d %+v", code, created)
+	}
+	status := &metav1.Status{}
+	if code := resourceRequest(t, client, "POST", "volumes", &hyperapi.Volume{ObjectMeta: metav1.ObjectMeta{Name: "empty"}}, status); code != http.StatusBadRequest {
+		t.Errorf("expected a bad request for a volume without size, got %d %+v", code, status)
+	}
+
+	if code := resourceRequest(t, client, "DELETE", "volumes/vol3", nil, status); code != http.StatusOK || status.Status != metav1.StatusSuccess {
+		t.Errorf("expected vol3 deleted, got %d %+v", code, status)
+	}
+	if len(f.volumes) != 2 {
+		t.Errorf("expected vol3 to be deleted, got %+v", f.volumes)
+	}
+}
+
+func TestResourceTransportVolumeZone(t *testing.T) {
+	f := &fakeHyper{volumes: []api.VolumeResponse{
+		{Name: "vol1", Size: 10, Zone: "gcp-us-central1-a"},
+		{Name: "vol2", Size: 20, Zone: "gcp-us-central1-b"},
+	}}
+	client, stop := newResourceClient(f)
+	defer stop()
+
+	tests := []struct {
+		path    string
+		code    int
+		request string
+		volumes []string
+	}{
+		{
+			path:    "volumes?zone=gcp-us-central1-b",
+			code:    http.StatusOK,
+			request: "GET /api/v1/hyper/volumes?zone=gcp-us-central1-b",
+			volumes: []string{"vol2"},
+		},
+		{
+			// pi get volumes --zone
+			path:    "volumes?fieldSelector=spec.zone%3Dgcp-us-central1-a",
+			code:    http.StatusOK,
+			request: "GET /api/v1/hyper/volumes?zone=gcp-us-central1-a",
+			volumes: []string{"vol1"},
+		},
+		{
+			path:    "volumes/vol1?fieldSelector=spec.zone%3Dgcp-us-central1-a",
+			code:    http.StatusOK,
+			request: "GET /api/v1/hyper/volumes/vol1?zone=gcp-us-central1-a",
+		},
+		{
+			path: "volumes?fieldSelector=spec.zone%21%3Dgcp-us-central1-a",
+			code: http.StatusBadRequest,
+		},
+		{
+			path: "volumes?fieldSelector=spec.size%3D10",
+			code: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		list := &hyperapi.VolumeList{}
+		code := resourceRequest(t, client, "GET", test.path, nil, list)
+		if code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.path, test.code, code)
+		}
+		requests := f.Requests()
+		if len(test.request) == 0 {
+			if len(requests) > 0 {
+				t.Errorf("%s: expected no request to the Hyper API, got %v", test.path, requests)
+			}
+			continue
+		}
+		if len(requests) != 1 || requests[0] != test.request {
+			t.Errorf("%s: expected %s, got %v", test.path, test.request, requests)
+		}
+		if test.volumes == nil {
+			continue
+		}
+		names := []string{}
+		for _, volume := range list.Items {
+			names = append(names, volume.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(test.volumes, ",") {
+			t.Errorf("%s: expected the volumes %v, got %v", test.path, test.volumes, names)
+		}
+	}
+}
+
+func TestResourceTransportFips(t *testing.T) {
+	f := &fakeHyper{fips: []api.FipResponse{
+		{Fip: "10.0.0.1", Name: "web", Services: []string{"nginx"}},
+		{Fip: "10.0.0.2"},
+	}}
+	client, stop := newResourceClient(f)
+	defer stop()
+
+	list := &hyperapi.FipList{}
+	if code := resourceRequest(t, client, "GET", "fips", nil, list); code != http.StatusOK || len(list.Items) != 2 {
+		t.Errorf("expected the 2 fips, got %d %+v", code, list.Items)
+	}
+	f.Requests()
+
+	// by IP, with a request for the fip, and by name, looked up in the list
+	for path, request := range map[string]string{
+		"fips/10.0.0.1": "GET /api/v1/hyper/fips/10.0.0.1",
+		"fips/web":      "GET /api/v1/hyper/fips",
+	} {
+		fip := &hyperapi.Fip{}
+		if code := resourceRequest(t, client, "GET", path, nil, fip); code != http.StatusOK || fip.Name != "10.0.0.1" || fip.Spec.Name != "web" {
+			t.Errorf("%s: expected the fip 10.0.0.1, got %d %+v", path, code, fip)
+		}
+		if requests := f.Requests(); len(requests) != 1 || requests[0] != request {
+			t.Errorf("%s: expected %s, got %v", path, request, requests)
+		}
+	}
+
+	// a manifest names the new fip by metadata.name
+	create := &hyperapi.Fip{ObjectMeta: metav1.ObjectMeta{Name: "db"}}
+	created := &hyperapi.Fip{}
+	if code := resourceRequest(t, client, "POST", "fips", create, created); code != http.StatusCreated || created.Name != "10.0.0.9" || created.Spec.Name != "db" {
+		t.Errorf("expected the fip 10.0.0.9 named db, got %d %+v", code, created)
+	}
+	expected := []string{"GET /api/v1/hyper/fips", "POST /api/v1/hyper/fips?count=1", "POST /api/v1/hyper/fips/10.0.0.9"}
+	if requests := f.Requests(); strings.Join(requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, requests)
+	}
+	status := &metav1.Status{}
+	if code := resourceRequest(t, client, "POST", "fips", create, status); code != http.StatusConflict {
+		t.Errorf("expected the name db to be taken, got %d %+v", code, status)
+	}
+
+	// renamed with PUT
+	rename := &hyperapi.Fip{ObjectMeta: metav1.ObjectMeta{Name: "10.0.0.9"}, Spec: hyperapi.FipSpec{Name: "cache"}}
+	renamed := &hyperapi.Fip{}
+	if code := resourceRequest(t, client, "PUT", "fips/10.0.0.9", rename, renamed); code != http.StatusOK || renamed.Spec.Name != "cache" {
+		t.Errorf("expected the fip renamed cache, got %d %+v", code, renamed)
+	}
+
+	// released by name
+	if code := resourceRequest(t, client, "DELETE", "fips/cache", nil, status); code != http.StatusOK {
+		t.Errorf("expected the fip released, got %d %+v", code, status)
+	}
+	f.Requests()
+	for _, fip := range f.fips {
+		if fip.Fip == "10.0.0.9" {
+			t.Errorf("expected the fip 10.0.0.9 to be released")
+		}
+	}
+}
+
+func TestResourceTransportNotFound(t *testing.T) {
+	client, stop := newResourceClient(&fakeHyper{})
+	defer stop()
+
+	for _, path := range []string{"volumes/missing", "fips/10.0.0.7", "fips/missing", "instances/i1"} {
+		status := &metav1.Status{}
+		code := resourceRequest(t, client, "GET", path, nil, status)
+		if code != http.StatusNotFound || status.Reason != metav1.StatusReasonNotFound {
+			t.Errorf("%s: expected a NotFound status, got %d %+v", path, code, status)
+		}
+	}
+	status := &metav1.Status{}
+	if code := resourceRequest(t, client, "DELETE", "volumes/missing", nil, status); code != http.StatusNotFound || status.Details == nil || status.Details.Name != "missing" {
+		t.Errorf("expected a NotFound status for the volume missing, got %d %+v", code, status)
+	}
+}
+
+func TestFipAlias(t *testing.T) {
+	tests := []struct {
+		name, specName string
+		alias          string
+		err            bool
+	}{
+		{name: "", specName: "", alias: ""},
+		{name: "", specName: "web", alias: "web"},
+		{name: "web", specName: "", alias: "web"},
+		{name: "web", specName: "web", alias: "web"},
+		{name: "10.0.0.1", err: true},
+		{name: "web", specName: "db", err: true},
+	}
+	for _, test := range tests {
+		fip := &hyperapi.Fip{ObjectMeta: metav1.ObjectMeta{Name: test.name}, Spec: hyperapi.FipSpec{Name: test.specName}}
+		alias, err := fipAlias(fip)
+		if test.err != (err != nil) {
+			t.Errorf("%q %q: unexpected error %v", test.name, test.specName, err)
+		}
+		if alias != test.alias {
+			t.Errorf("%q %q: expected the alias %q, got %q", test.name, test.specName, test.alias, alias)
+		}
+	}
+}
+
+func TestRejectLabelSelector(t *testing.T) {
+	tests := []struct {
+		resource string
+		selector string
+		err      string
+	}{
+		{resource: "volumes", selector: ""},
+		{resource: "volumes", selector: "app=web", err: `volumes have no labels, they can't be selected with the label selector "app=web"`},
+		{resource: "fips", selector: "!app", err: `fips have no labels, they can't be selected with the label selector "!app"`},
+		{resource: "fips", selector: "app in (web", err: "unable to parse requirement"},
+	}
+	for _, test := range tests {
+		query := url.Values{}
+		query.Set("labelSelector", test.selector)
+		req, err := http.NewRequest("GET", "https://hyper/apis/hyper.sh/v1/"+test.resource+"?"+query.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = rejectLabelSelector(req, hyperapi.Resource(test.resource))
+		switch {
+		case len(test.err) == 0 && err != nil:
+			t.Errorf("%s %q: unexpected error %v", test.resource, test.selector, err)
+		case len(test.err) > 0 && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s %q: expected error %q, got %v", test.resource, test.selector, test.err, err)
+		case len(test.err) > 0 && !apierrors.IsBadRequest(err):
+			t.Errorf("%s %q: expected a bad request, got %v", test.resource, test.selector, err)
+		}
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	for body, expected := range map[string]string{
+		`{"message":"No such volume: vol1"}`: "No such volume: vol1",
+		"internal error\n":                   "internal error",
+	} {
+		if message := errorMessage(body); message != expected {
+			t.Errorf("%q: expected %q, got %q", body, expected, message)
+		}
+	}
+}
@@ -68,6 +68,8 @@ func (cli *HyperCli) resizeTty(ctx context.Context, id string, isExec bool) {
 	var err error
 	if isExec {
 		err = cli.Client.PodExecResize(ctx, id, options)
+	} else {
+		err = cli.Client.ContainerResize(ctx, id, options)
 	}
 
 	if err != nil {
@@ -75,9 +77,9 @@ func (cli *HyperCli) resizeTty(ctx context.Context, id string, isExec bool) {
 	}
 }
 
-// getExitCode perform an inspect on the container. It returns
+// GetExitCode perform an inspect on the container. It returns
 // the running state and the exit code.
-func getExitCode(ctx context.Context, cli *HyperCli, containerID string) (bool, int, error) {
+func (cli *HyperCli) GetExitCode(ctx context.Context, containerID string) (bool, int, error) {
 	c, err := cli.Client.ContainerInspect(ctx, containerID)
 	if err != nil {
 		// If we can't connect, then the daemon probably died.
@@ -179,3 +179,12 @@ var LegacyCategoryExpander CategoryExpander = SimpleCategoryExpander{
 		"all": legacyUserResources,
 	},
 }
+
+// HyperUserResources are the Hyper.sh resources that are served by the client rather than
+// published through discovery. They are left out of 'all', which delete expands as well, so
+// that 'delete all' never drops the data of a volume or releases a fip; get lists them on
+// its own.
+var HyperUserResources = []schema.GroupResource{
+	{Group: "hyper.sh", Resource: "volumes"},
+	{Group: "hyper.sh", Resource: "fips"},
+}
@@ -0,0 +1,475 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+	"github.com/hyperhq/pi/pkg/pi"
+	"github.com/hyperhq/pi/pkg/pi/apply"
+	"github.com/hyperhq/pi/pkg/pi/apply/parse"
+	"github.com/hyperhq/pi/pkg/pi/apply/strategy"
+	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/pi/cmd/util/openapi"
+	"github.com/hyperhq/pi/pkg/pi/resource"
+	"github.com/hyperhq/pi/pkg/pi/util/i18n"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+)
+
+type ApplyOptions struct {
+	FilenameOptions resource.FilenameOptions
+	Selector        string
+	Force           bool
+	Overwrite       bool
+	GracePeriod     int
+	Timeout         time.Duration
+}
+
+var (
+	applyLong = templates.LongDesc(i18n.T(`
+		Apply a configuration to a resource by filename or stdin.
+		The resource name must be specified. This resource will be created if it doesn't exist yet.
+
+		The last applied configuration is recorded in the annotation
+		kubectl.kubernetes.io/last-applied-configuration, and is merged with the live object
+		and the new configuration to work out which fields to change. Fields removed from the
+		configuration are removed from the live object, while fields set by the server are kept.
+
+		Pass --force to delete and re-create a resource when it cannot be updated in place,
+		e.g. when an immutable field of a pod has changed.
+
+		Volumes and fips are applied before the pods and services using them, and a fip name
+		given as the loadBalancerIP of a service is replaced with its address. The size and zone
+		of a volume can't be changed and only the name of a fip can be, they are never
+		re-created by --force.
+
+		JSON and YAML formats are accepted(pod, service, secret).`))
+
+	applyExample = templates.Examples(i18n.T(`
+		# Apply the configuration in pod.json to a pod.
+		pi apply -f ./pod.json
+
+		# Apply all the configurations in a directory.
+		pi apply -f dir/
+
+		# Apply a pod and a service with their volume and fip.
+		pi apply -f ./nginx-all-in-one.yaml
+
+		# Apply the JSON passed into stdin to a pod.
+		cat pod.json | pi apply -f -
+
+		# Re-create the pod when the new configuration changes an immutable field.
+		pi apply -f ./pod.yaml --force
+
+		# Apply a template, setting its ${IMAGE} variable.
+		pi apply -f ./pod.tpl.yaml --set IMAGE=nginx:1.13`))
+)
+
+const (
+	// maxPatchRetry is the maximum number of conflicts retry for during an update
+	maxPatchRetry = 5
+	// triesBeforeBackOff is the number of times an update is retried before backing off
+	triesBeforeBackOff = 1
+)
+
+var (
+	// backOffPeriod is the period to back off when an update hits a conflict
+	backOffPeriod = 1 * time.Second
+	// defaultApplyDeleteTimeout is how long a force apply waits for the old object to go away
+	defaultApplyDeleteTimeout = 2 * time.Minute
+)
+
+func NewCmdApply(f cmdutil.Factory, out, errOut io.Writer) *cobra.Command {
+	var options ApplyOptions
+
+	cmd := &cobra.Command{
+		Use:     "apply -f FILENAME",
+		Short:   i18n.T("Apply a configuration to a resource by filename or stdin"),
+		Long:    applyLong,
+		Example: applyExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(validateApplyArgs(cmd, args))
+			cmdutil.CheckErr(RunApply(f, cmd, out, errOut, &options))
+		},
+	}
+
+	usage := "that contains the configuration to apply"
+	cmdutil.AddFilenameOptionFlags(cmd, &options.FilenameOptions, usage)
+	cmd.MarkFlagRequired("filename")
+	cmdutil.AddRenderFlag(cmd)
+	cmdutil.AddValidateFlags(cmd)
+	cmd.Flags().BoolVar(&options.Overwrite, "overwrite", true, "Automatically resolve conflicts between the modified and live configuration by using values from the modified configuration")
+	cmd.Flags().BoolVar(&options.Force, "force", false, "Delete and re-create the specified resource, when it cannot be updated in place.")
+	cmd.Flags().IntVar(&options.GracePeriod, "grace-period", -1, "Only relevant during a force apply. Period of time in seconds given to the old resource to terminate gracefully. Ignored if negative.")
+	cmd.Flags().DurationVar(&options.Timeout, "timeout", 0, "Only relevant during a force apply. The length of time to wait before giving up on a delete of the old resource, zero means wait for 2 minutes.")
+	cmd.Flags().StringVarP(&options.Selector, "selector", "l", "", "Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
+	return cmd
+}
+
+func validateApplyArgs(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return cmdutil.UsageErrorf(cmd, "Unexpected args: %v", args)
+	}
+	return nil
+}
+
+func RunApply(f cmdutil.Factory, cmd *cobra.Command, out, errOut io.Writer, options *ApplyOptions) error {
+	if cmdutil.GetFlagBool(cmd, "render") {
+		return options.FilenameOptions.Render(out)
+	}
+
+	schema, err := f.Validator(cmdutil.GetFlagBool(cmd, "validate"))
+	if err != nil {
+		return err
+	}
+
+	cmdNamespace, enforceNamespace, err := f.DefaultNamespace()
+	if err != nil {
+		return err
+	}
+
+	r := f.NewBuilder().
+		Unstructured().
+		Schema(schema).
+		ContinueOnError().
+		NamespaceParam(cmdNamespace).DefaultNamespace().
+		FilenameParam(enforceNamespace, &options.FilenameOptions).
+		LabelSelectorParam(options.Selector).
+		Flatten().
+		Do()
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	// The openapi schema drives the three-way merge. Servers that don't
+	// publish it fall back to a strategic merge patch.
+	resources, err := f.OpenAPISchema()
+	if err != nil {
+		glog.V(4).Infof("Unable to fetch openapi schema, falling back to strategic merge patch: %v", err)
+		resources = nil
+	}
+
+	infos := []*resource.Info{}
+	visitErr := r.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+		return nil
+	})
+	sort.Stable(byCreationOrder(infos))
+
+	errs := []error{}
+	if visitErr != nil {
+		errs = append(errs, visitErr)
+	}
+	// pods may use the volumes of any of the files, nothing is applied
+	// when they are missing
+	if err := resource.CompleteSchema(schema); err != nil {
+		return utilerrors.Flatten(utilerrors.NewAggregate(append(errs, err)))
+	}
+
+	mapper := r.Mapper().RESTMapper
+	count := 0
+	applyInfo := func(info *resource.Info) error {
+		if err := resolveLoadBalancerIP(f, mapper, info); err != nil {
+			return cmdutil.AddSourceToErr("applying", info.Source, err)
+		}
+
+		// Get the modified configuration of the object. Embed the result
+		// as an annotation in the modified configuration, so that it will appear
+		// in the patch sent to the server.
+		modified, err := pi.GetModifiedConfiguration(info, true, unstructured.UnstructuredJSONScheme)
+		if err != nil {
+			return cmdutil.AddSourceToErr(fmt.Sprintf("retrieving modified configuration from:\n%v\nfor:", info), info.Source, err)
+		}
+
+		if err := info.Get(); err != nil {
+			if !errors.IsNotFound(err) {
+				return cmdutil.AddSourceToErr(fmt.Sprintf("retrieving current configuration of:\n%v\nfrom server for:", info), info.Source, err)
+			}
+			// Create the resource if it doesn't exist
+			// First, update the annotation used by pi apply
+			if err := pi.CreateApplyAnnotation(info, unstructured.UnstructuredJSONScheme); err != nil {
+				return cmdutil.AddSourceToErr("creating", info.Source, err)
+			}
+			if err := createAndRefresh(info); err != nil {
+				return cmdutil.AddSourceToErr("creating", info.Source, err)
+			}
+			count++
+			f.PrintSuccess(mapper, false, out, info.Mapping.Resource, info.Name, false, "created")
+			return nil
+		}
+
+		patcher := &applyPatcher{
+			info:        info,
+			resources:   resources,
+			overwrite:   options.Overwrite,
+			force:       options.Force,
+			gracePeriod: options.GracePeriod,
+			timeout:     options.Timeout,
+		}
+		op, err := patcher.apply(modified)
+		if err != nil {
+			return cmdutil.AddSourceToErr(fmt.Sprintf("applying patch:\n%s\nto:\n%v\nfor:", modified, info), info.Source, err)
+		}
+		count++
+		f.PrintSuccess(mapper, false, out, info.Mapping.Resource, info.Name, false, op)
+		return nil
+	}
+	// the volumes and fips which failed to be applied, the objects using
+	// them are skipped
+	failed := failedDependencies{}
+	for _, info := range infos {
+		if dependency, found := failed.usedBy(info); found {
+			errs = append(errs, cmdutil.AddSourceToErr("applying", info.Source, fmt.Errorf("skipped %s %q, %s could not be applied", info.Mapping.Resource, info.Name, dependency)))
+			continue
+		}
+		if err := applyInfo(info); err != nil {
+			errs = append(errs, err)
+			failed.insert(info)
+		}
+	}
+	if len(errs) > 0 {
+		return utilerrors.Flatten(utilerrors.NewAggregate(errs))
+	}
+	if count == 0 {
+		return fmt.Errorf("no objects passed to apply")
+	}
+	return nil
+}
+
+// applyPatcher merges a modified configuration into the live object and
+// writes the result back, re-creating the object when asked to.
+type applyPatcher struct {
+	info      *resource.Info
+	resources openapi.Resources
+
+	overwrite   bool
+	force       bool
+	gracePeriod int
+	timeout     time.Duration
+}
+
+// apply updates the live object in info.Object with the modified
+// configuration and returns the operation performed, retrying on conflicts.
+func (p *applyPatcher) apply(modified []byte) (string, error) {
+	if dependencyKinds[p.info.Mapping.GroupVersionKind.GroupKind()] {
+		return p.updateDependency(modified)
+	}
+	op, err := p.update(modified)
+	for i := 1; i <= maxPatchRetry && errors.IsConflict(err); i++ {
+		if i > triesBeforeBackOff {
+			time.Sleep(backOffPeriod)
+		}
+		if err = p.info.Get(); err != nil {
+			return "", err
+		}
+		op, err = p.update(modified)
+	}
+	if err != nil && p.force && (errors.IsConflict(err) || errors.IsInvalid(err) || errors.IsMethodNotSupported(err)) {
+		return p.deleteAndCreate(modified)
+	}
+	return op, err
+}
+
+func (p *applyPatcher) update(modified []byte) (string, error) {
+	original, err := pi.GetOriginalConfiguration(p.info.Mapping, p.info.Object)
+	if err != nil {
+		return "", err
+	}
+	current, err := runtime.Encode(unstructured.UnstructuredJSONScheme, p.info.Object)
+	if err != nil {
+		return "", err
+	}
+
+	helper := resource.NewHelper(p.info.Client, p.info.Mapping)
+	if p.resources == nil {
+		patch, err := p.strategicPatch(original, modified, current)
+		if err != nil {
+			return "", err
+		}
+		if string(patch) == "{}" {
+			return "unchanged", nil
+		}
+		obj, err := helper.Patch(p.info.Namespace, p.info.Name, types.StrategicMergePatchType, patch)
+		if err != nil {
+			return "", err
+		}
+		p.info.Refresh(obj, true)
+		return "configured", nil
+	}
+
+	merged, err := p.merge(original, modified, current)
+	if err != nil {
+		return "", err
+	}
+	remote := map[string]interface{}{}
+	if err := json.Unmarshal(current, &remote); err != nil {
+		return "", err
+	}
+	if reflect.DeepEqual(merged, remote) {
+		return "unchanged", nil
+	}
+	obj, err := helper.Replace(p.info.Namespace, p.info.Name, true, &unstructured.Unstructured{Object: merged})
+	if err != nil {
+		return "", err
+	}
+	p.info.Refresh(obj, true)
+	return "configured", nil
+}
+
+// updateDependency applies the configuration of a volume or a fip. They are
+// not kubernetes objects: the Hyper API can't change the size or the zone of a
+// volume, only the name of a fip, and re-creating them would lose the data of
+// the volume or the address of the fip.
+func (p *applyPatcher) updateDependency(modified []byte) (string, error) {
+	obj, err := runtime.Decode(unstructured.UnstructuredJSONScheme, modified)
+	if err != nil {
+		return "", err
+	}
+	local := obj.(runtime.Unstructured).UnstructuredContent()
+	live, ok := p.info.Object.(runtime.Unstructured)
+	if !ok {
+		return "", fmt.Errorf("unexpected object %T for %s/%s", p.info.Object, p.info.Mapping.Resource, p.info.Name)
+	}
+	remote := live.UnstructuredContent()
+
+	if p.info.Mapping.GroupVersionKind.GroupKind() == hyperapi.Kind("Volume") {
+		for _, field := range []string{"size", "zone"} {
+			value, found := unstructured.NestedFieldCopy(local, "spec", field)
+			current, _ := unstructured.NestedFieldCopy(remote, "spec", field)
+			if found && !reflect.DeepEqual(value, current) {
+				return "", fmt.Errorf("the %s of volume %q can't be changed from %v to %v", field, p.info.Name, current, value)
+			}
+		}
+		return "unchanged", nil
+	}
+
+	name, found := unstructured.NestedString(local, "spec", "name")
+	current, _ := unstructured.NestedString(remote, "spec", "name")
+	if !found || name == current {
+		return "unchanged", nil
+	}
+	unstructured.SetNestedField(remote, name, "spec", "name")
+	live.SetUnstructuredContent(remote)
+	renamed, err := resource.NewHelper(p.info.Client, p.info.Mapping).Replace(p.info.Namespace, p.info.Name, true, p.info.Object)
+	if err != nil {
+		return "", err
+	}
+	p.info.Refresh(renamed, true)
+	return "configured", nil
+}
+
+// merge runs the openapi driven three-way merge of the recorded, local and
+// remote configurations and returns the merged object.
+func (p *applyPatcher) merge(original, modified, current []byte) (map[string]interface{}, error) {
+	recorded := map[string]interface{}{}
+	local := map[string]interface{}{}
+	remote := map[string]interface{}{}
+	for _, c := range []struct {
+		data []byte
+		into *map[string]interface{}
+	}{{original, &recorded}, {modified, &local}, {current, &remote}} {
+		if len(c.data) == 0 {
+			*c.into = nil
+			continue
+		}
+		if err := json.Unmarshal(c.data, c.into); err != nil {
+			return nil, err
+		}
+	}
+
+	factory := parse.Factory{Resources: p.resources}
+	element, err := factory.CreateElement(recorded, local, remote)
+	if err != nil {
+		return nil, err
+	}
+	result, err := element.Merge(strategy.Create(strategy.Options{FailOnConflict: !p.overwrite}))
+	if err != nil {
+		return nil, err
+	}
+	if result.Operation != apply.SET {
+		return nil, fmt.Errorf("unexpected merge result for %s/%s", p.info.Mapping.Resource, p.info.Name)
+	}
+	merged, ok := result.MergedResult.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected merge result type %T for %s/%s", result.MergedResult, p.info.Mapping.Resource, p.info.Name)
+	}
+	return merged, nil
+}
+
+// strategicPatch computes a strategic merge patch using the patch metadata
+// of the versioned go type.
+func (p *applyPatcher) strategicPatch(original, modified, current []byte) ([]byte, error) {
+	versionedObject, err := legacyscheme.Scheme.New(p.info.Mapping.GroupVersionKind)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build a patch for %s: %v", p.info.Mapping.GroupVersionKind, err)
+	}
+	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(versionedObject)
+	if err != nil {
+		return nil, err
+	}
+	return strategicpatch.CreateThreeWayMergePatch(original, modified, current, lookupPatchMeta, p.overwrite)
+}
+
+// deleteAndCreate removes the live object, waits until it is gone and creates
+// it again from the modified configuration.
+func (p *applyPatcher) deleteAndCreate(modified []byte) (string, error) {
+	options := &metav1.DeleteOptions{}
+	if p.gracePeriod >= 0 {
+		options = metav1.NewDeleteOptions(int64(p.gracePeriod))
+	}
+	if err := resource.NewHelper(p.info.Client, p.info.Mapping).DeleteWithOptions(p.info.Namespace, p.info.Name, options); err != nil {
+		return "", err
+	}
+	timeout := p.timeout
+	if timeout == 0 {
+		timeout = defaultApplyDeleteTimeout
+	}
+	if err := waitForObjectDeletion(p.info, timeout); err != nil {
+		return "", err
+	}
+
+	obj, err := runtime.Decode(unstructured.UnstructuredJSONScheme, modified)
+	if err != nil {
+		return "", err
+	}
+	created, err := resource.NewHelper(p.info.Client, p.info.Mapping).Create(p.info.Namespace, true, obj)
+	if err != nil {
+		return "", err
+	}
+	p.info.Refresh(created, true)
+	return "replaced", nil
+}
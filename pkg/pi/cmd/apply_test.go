@@ -0,0 +1,427 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hyperhq/client-go/rest/fake"
+	cmdtesting "github.com/hyperhq/pi/pkg/pi/cmd/testing"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/pi/cmd/util/openapi/validation"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestApplyValidatesPods(t *testing.T) {
+	var fatal string
+	cmdutil.BehaviorOnFatal(func(str string, code int) {
+		fatal = str
+	})
+	defer initTestErrorHandler(t)
+
+	f, tf, _, _ := cmdtesting.NewAPIFactory()
+	tf.Validator = validation.NewHyperValidation(nil)
+	tf.UnstructuredClient = &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			t.Fatalf("unexpected request: %#v\n%#v", req.URL, req)
+			return nil, nil
+		}),
+	}
+	tf.Namespace = "default"
+
+	cmd := NewCmdApply(f, bytes.NewBuffer([]byte{}), bytes.NewBuffer([]byte{}))
+	cmd.Flags().Set("filename", "../../../examples/pod/pod-volume-unsupported.yaml")
+	cmd.Run(cmd, []string{})
+
+	if expected := "spec.volumes[0].hostPath: Forbidden"; !strings.Contains(fatal, expected) {
+		t.Errorf("expected %q in the error, got %q", expected, fatal)
+	}
+}
+
+// fakeApplyServer keeps the objects applied to it by path like the API server,
+// the fips are given an address, and records the requests it gets.
+type fakeApplyServer struct {
+	t        *testing.T
+	objects  map[string]map[string]interface{}
+	requests []string
+	// invalid makes the updates fail, as when they change an immutable field
+	invalid bool
+	version int
+}
+
+func newFakeApplyServer(t *testing.T) *fakeApplyServer {
+	return &fakeApplyServer{t: t, objects: map[string]map[string]interface{}{}}
+}
+
+func (s *fakeApplyServer) client() *fake.RESTClient {
+	return &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: unstructuredSerializer,
+		Client:               fake.CreateHTTPClient(s.roundTrip),
+	}
+}
+
+func (s *fakeApplyServer) roundTrip(req *http.Request) (*http.Response, error) {
+	path := req.URL.Path
+	s.requests = append(s.requests, req.Method+" "+path)
+	obj := map[string]interface{}{}
+	if req.Body != nil {
+		data, _ := ioutil.ReadAll(req.Body)
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &obj); err != nil {
+				s.t.Fatalf("%s %s: %v", req.Method, path, err)
+			}
+		}
+	}
+
+	if len(obj) > 0 {
+		s.version++
+		unstructured.SetNestedField(obj, strconv.Itoa(s.version), "metadata", "resourceVersion")
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		if current, found := s.objects[path]; found {
+			return s.response(http.StatusOK, current), nil
+		}
+		return s.response(http.StatusNotFound, nil), nil
+	case http.MethodPost:
+		name, _ := unstructured.NestedString(obj, "metadata", "name")
+		if strings.HasSuffix(path, "/fips") {
+			unstructured.SetNestedField(obj, name, "spec", "name")
+			unstructured.SetNestedField(obj, "10.0.0.9", "metadata", "name")
+		} else if strings.HasSuffix(path, "/pods") {
+			unstructured.SetNestedField(obj, "node-1", "spec", "nodeName")
+		}
+		s.objects[path+"/"+name] = obj
+		return s.response(http.StatusCreated, obj), nil
+	case http.MethodPut:
+		if s.invalid {
+			return s.response(http.StatusUnprocessableEntity, map[string]interface{}{
+				"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": metav1.StatusReasonInvalid, "code": http.StatusUnprocessableEntity,
+				"details": map[string]interface{}{"kind": "pods", "name": filepath.Base(path)},
+			}), nil
+		}
+		s.objects[path] = obj
+		return s.response(http.StatusOK, obj), nil
+	case http.MethodDelete:
+		delete(s.objects, path)
+		return s.response(http.StatusOK, map[string]interface{}{"kind": "Status", "apiVersion": "v1", "status": "Success"}), nil
+	}
+	s.t.Fatalf("unexpected request: %#v\n%#v", req.URL, req)
+	return nil, nil
+}
+
+func (s *fakeApplyServer) response(code int, obj map[string]interface{}) *http.Response {
+	if obj == nil {
+		obj = map[string]interface{}{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": metav1.StatusReasonNotFound, "code": code}
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return &http.Response{StatusCode: code, Header: defaultHeader(), Body: ioutil.NopCloser(bytes.NewReader(data))}
+}
+
+// Requests returns the requests sent since the last call.
+func (s *fakeApplyServer) Requests() []string {
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func runApply(t *testing.T, server *fakeApplyServer, flags map[string]string) (string, string) {
+	var fatal string
+	cmdutil.BehaviorOnFatal(func(str string, code int) {
+		fatal = str
+	})
+	defer initTestErrorHandler(t)
+
+	f, tf, _, _ := cmdtesting.NewAPIFactory()
+	tf.UnstructuredClient = server.client()
+	tf.Client = server.client()
+	tf.Namespace = "default"
+
+	buf := bytes.NewBuffer([]byte{})
+	cmd := NewCmdApply(f, buf, bytes.NewBuffer([]byte{}))
+	for name, value := range flags {
+		cmd.Flags().Set(name, value)
+	}
+	cmd.Run(cmd, []string{})
+	return buf.String(), fatal
+}
+
+const applyPod = `apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  labels:
+    app: nginx
+    tier: web
+spec:
+  containers:
+  - name: nginx
+    image: nginx:1.13
+`
+
+func TestApplyMergesPods(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pod := filepath.Join(dir, "pod.yaml")
+	server := newFakeApplyServer(t)
+
+	tests := []struct {
+		name     string
+		manifest string
+		force    bool
+		invalid  bool
+		output   string
+		requests []string
+	}{
+		{
+			name:     "created",
+			manifest: applyPod,
+			output:   "pod \"nginx\" created\n",
+			requests: []string{"GET /namespaces/default/pods/nginx", "POST /namespaces/default/pods"},
+		},
+		{
+			name:     "unchanged",
+			manifest: applyPod,
+			output:   "pod \"nginx\" unchanged\n",
+			requests: []string{"GET /namespaces/default/pods/nginx"},
+		},
+		{
+			name:     "configured",
+			manifest: strings.Replace(strings.Replace(applyPod, "    tier: web\n", "", 1), "nginx:1.13", "nginx:1.14", 1),
+			output:   "pod \"nginx\" configured\n",
+			requests: []string{"GET /namespaces/default/pods/nginx", "PUT /namespaces/default/pods/nginx"},
+		},
+		{
+			name:     "replaced",
+			manifest: strings.Replace(applyPod, "nginx:1.13", "nginx:1.15", 1),
+			force:    true,
+			invalid:  true,
+			output:   "pod \"nginx\" replaced\n",
+			requests: []string{"GET /namespaces/default/pods/nginx", "PUT /namespaces/default/pods/nginx", "DELETE /namespaces/default/pods/nginx", "GET /namespaces/default/pods/nginx", "POST /namespaces/default/pods"},
+		},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(pod, []byte(test.manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		server.invalid = test.invalid
+		flags := map[string]string{"filename": pod}
+		if test.force {
+			flags["force"] = "true"
+		}
+		output, fatal := runApply(t, server, flags)
+		if len(fatal) > 0 {
+			t.Errorf("%s: unexpected error %s", test.name, fatal)
+		}
+		if output != test.output {
+			t.Errorf("%s: expected the output %q, got %q", test.name, test.output, output)
+		}
+		if requests := server.Requests(); !reflect.DeepEqual(requests, test.requests) {
+			t.Errorf("%s: expected the requests %v, got %v", test.name, test.requests, requests)
+		}
+	}
+
+	// the three-way merge dropped the label removed from the configuration
+	// and kept the node set by the server, until the pod was replaced
+	live := server.objects["/namespaces/default/pods/nginx"]
+	image, _ := unstructured.NestedFieldCopy(live, "spec", "containers")
+	if !strings.Contains(jsonString(t, image), "nginx:1.15") {
+		t.Errorf("expected the image nginx:1.15, got %s", jsonString(t, image))
+	}
+	if labels, _ := unstructured.NestedStringMap(live, "metadata", "labels"); labels["tier"] != "web" {
+		t.Errorf("expected the label tier of the replaced pod, got %v", labels)
+	}
+
+	server.invalid = true
+	if err := ioutil.WriteFile(pod, []byte(strings.Replace(applyPod, "nginx:1.13", "nginx:1.16", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, fatal := runApply(t, server, map[string]string{"filename": pod}); !strings.Contains(fatal, `The pods "nginx" is invalid`) {
+		t.Errorf("expected the update to fail without --force, got %q", fatal)
+	}
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, "DELETE") {
+			t.Errorf("unexpected %s without --force", request)
+		}
+	}
+}
+
+func TestApplyMergeKeepsServerFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pod := filepath.Join(dir, "pod.yaml")
+	server := newFakeApplyServer(t)
+
+	for _, manifest := range []string{applyPod, strings.Replace(applyPod, "    tier: web\n", "", 1)} {
+		if err := ioutil.WriteFile(pod, []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, fatal := runApply(t, server, map[string]string{"filename": pod}); len(fatal) > 0 {
+			t.Fatalf("unexpected error %s", fatal)
+		}
+	}
+	live := server.objects["/namespaces/default/pods/nginx"]
+	if labels, _ := unstructured.NestedStringMap(live, "metadata", "labels"); !reflect.DeepEqual(labels, map[string]string{"app": "nginx"}) {
+		t.Errorf("expected the label tier to be removed, got %v", labels)
+	}
+	if node, _ := unstructured.NestedString(live, "spec", "nodeName"); node != "node-1" {
+		t.Errorf("expected the node set by the server to be kept, got %q", node)
+	}
+}
+
+func TestApplyAllInOne(t *testing.T) {
+	server := newFakeApplyServer(t)
+	manifest := map[string]string{"filename": "../../../examples/all/nginx-all-in-one.yaml"}
+
+	output, fatal := runApply(t, server, manifest)
+	if len(fatal) > 0 {
+		t.Fatalf("unexpected error %s", fatal)
+	}
+	// the volume and the fip are created first, and the service gets the
+	// address of the fip
+	expected := "volume \"nginx-data\" created\nfip \"10.0.0.9\" created\nservice \"nginx\" created\npod \"nginx\" created\n"
+	if output != expected {
+		t.Errorf("expected the output %q, got %q", expected, output)
+	}
+	service := server.objects["/namespaces/default/services/nginx"]
+	if ip, _ := unstructured.NestedString(service, "spec", "loadBalancerIP"); ip != "10.0.0.9" {
+		t.Errorf("expected the loadBalancerIP 10.0.0.9, got %q", ip)
+	}
+	server.Requests()
+
+	output, fatal = runApply(t, server, manifest)
+	if len(fatal) > 0 {
+		t.Fatalf("unexpected error %s", fatal)
+	}
+	expected = "volume \"nginx-data\" unchanged\nfip \"nginx-ip\" unchanged\nservice \"nginx\" unchanged\npod \"nginx\" unchanged\n"
+	if output != expected {
+		t.Errorf("expected the output %q, got %q", expected, output)
+	}
+	for _, request := range server.Requests() {
+		if !strings.HasPrefix(request, "GET") {
+			t.Errorf("unexpected %s of an unchanged object", request)
+		}
+	}
+}
+
+func TestApplyVolumesAndFips(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "volume.yaml")
+	server := newFakeApplyServer(t)
+	server.objects["/volumes/data"] = map[string]interface{}{
+		"apiVersion": "hyper.sh/v1", "kind": "Volume",
+		"metadata": map[string]interface{}{"name": "data"},
+		"spec":     map[string]interface{}{"size": int64(10), "zone": "gcp-us-central1-a"},
+	}
+	server.objects["/fips/10.0.0.1"] = map[string]interface{}{
+		"apiVersion": "hyper.sh/v1", "kind": "Fip",
+		"metadata": map[string]interface{}{"name": "10.0.0.1"},
+		"spec":     map[string]interface{}{"name": "web"},
+	}
+
+	tests := []struct {
+		manifest string
+		output   string
+		err      string
+		requests []string
+	}{
+		{
+			manifest: "apiVersion: hyper.sh/v1\nkind: Volume\nmetadata:\n  name: data\nspec:\n  size: 10\n",
+			output:   "volume \"data\" unchanged\n",
+			requests: []string{"GET /volumes/data"},
+		},
+		{
+			manifest: "apiVersion: hyper.sh/v1\nkind: Volume\nmetadata:\n  name: data\nspec:\n  size: 20\n",
+			err:      `the size of volume "data" can't be changed from 10 to 20`,
+			requests: []string{"GET /volumes/data"},
+		},
+		{
+			manifest: "apiVersion: hyper.sh/v1\nkind: Volume\nmetadata:\n  name: data\nspec:\n  size: 10\n  zone: gcp-us-central1-b\n",
+			err:      `the zone of volume "data" can't be changed`,
+			requests: []string{"GET /volumes/data"},
+		},
+		{
+			manifest: "apiVersion: hyper.sh/v1\nkind: Fip\nmetadata:\n  name: 10.0.0.1\nspec:\n  name: web\n",
+			output:   "fip \"10.0.0.1\" unchanged\n",
+			requests: []string{"GET /fips/10.0.0.1"},
+		},
+		{
+			manifest: "apiVersion: hyper.sh/v1\nkind: Fip\nmetadata:\n  name: 10.0.0.1\nspec:\n  name: db\n",
+			output:   "fip \"10.0.0.1\" configured\n",
+			requests: []string{"GET /fips/10.0.0.1", "GET /fips/10.0.0.1", "PUT /fips/10.0.0.1"},
+		},
+	}
+	for _, test := range tests {
+		if err := ioutil.WriteFile(manifest, []byte(test.manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// --force never re-creates them
+		output, fatal := runApply(t, server, map[string]string{"filename": manifest, "force": "true"})
+		if len(test.err) > 0 && !strings.Contains(fatal, test.err) {
+			t.Errorf("expected %q in the error, got %q", test.err, fatal)
+		}
+		if len(test.err) == 0 && len(fatal) > 0 {
+			t.Errorf("unexpected error %s", fatal)
+		}
+		if output != test.output {
+			t.Errorf("expected the output %q, got %q", test.output, output)
+		}
+		if requests := server.Requests(); !reflect.DeepEqual(requests, test.requests) {
+			t.Errorf("expected the requests %v, got %v", test.requests, requests)
+		}
+	}
+	if name, _ := unstructured.NestedString(server.objects["/fips/10.0.0.1"], "spec", "name"); name != "db" {
+		t.Errorf("expected the fip to be renamed db, got %q", name)
+	}
+}
+
+func jsonString(t *testing.T, obj interface{}) string {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
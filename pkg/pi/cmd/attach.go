@@ -18,14 +18,19 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"strings"
 	"time"
 
 	restclient "github.com/hyperhq/client-go/rest"
 	"github.com/hyperhq/client-go/tools/remotecommand"
+	"github.com/hyperhq/hyper-api/types"
+	"github.com/hyperhq/hypercli/pkg/promise"
+	"github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
 	"github.com/hyperhq/pi/pkg/pi/util/i18n"
 
+	dockerterm "github.com/docker/docker/pkg/term"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,8 +49,8 @@ var (
 		# Switch to raw terminal mode, sends stdin to 'bash' in ruby-container from pod 123456-7890
 		# and sends stdout/stderr from 'bash' back to the client
 		pi attach 123456-7890 -c ruby-container -i -t
-		# Get output from the first pod of a ReplicaSet named nginx
-		pi attach rs/nginx
+		# Same as above, detaching with ctrl-x instead of the default ctrl-p ctrl-q
+		pi attach 123456-7890 -c ruby-container -i -t --detach-keys=ctrl-x
 		`))
 )
 
@@ -68,13 +73,14 @@ func NewCmdAttach(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer)
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(options.Complete(f, cmd, args))
 			cmdutil.CheckErr(options.Validate())
-			cmdutil.CheckErr(options.Run())
+			cmdutil.CheckErr(options.RunHyper(f))
 		},
 	}
 	cmdutil.AddPodRunningTimeoutFlag(cmd, defaultPodAttachTimeout)
 	cmd.Flags().StringVarP(&options.ContainerName, "container", "c", options.ContainerName, "Container name. If omitted, the first container in the pod will be chosen")
 	cmd.Flags().BoolVarP(&options.Stdin, "stdin", "i", options.Stdin, "Pass stdin to the container")
 	cmd.Flags().BoolVarP(&options.TTY, "tty", "t", options.TTY, "Stdin is a TTY")
+	cmd.Flags().StringVar(&options.DetachKeys, "detach-keys", options.DetachKeys, "Override the key sequence for detaching from the container, the format is a comma separated list of characters or ctrl-<value> like 'ctrl-p,ctrl-q'")
 	return cmd
 }
 
@@ -106,6 +112,9 @@ type AttachOptions struct {
 
 	CommandName       string
 	SuggestedCmdUsage string
+	// DetachKeys is the key sequence detaching from the container, the
+	// server default is used when empty
+	DetachKeys string
 
 	Pod *api.Pod
 
@@ -293,6 +302,146 @@ func (p *AttachOptions) Run() error {
 	return nil
 }
 
+// RunHyper attaches to the main process of a container through the hijacked
+// connection of the Hyper API.
+func (p *AttachOptions) RunHyper(f cmdutil.Factory) error {
+	if p.Pod == nil {
+		pod, err := p.PodClient.Pods(p.Namespace).Get(p.PodName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed {
+			return fmt.Errorf("cannot attach a container in a completed pod; current phase is %s", pod.Status.Phase)
+		}
+
+		p.Pod = pod
+	}
+	pod := p.Pod
+
+	containerToAttach, err := p.containerToAttachTo(pod)
+	if err != nil {
+		return fmt.Errorf("cannot attach to the container: %v", err)
+	}
+	containerID, err := containerIDOf(pod, containerToAttach.Name)
+	if err != nil {
+		return fmt.Errorf("cannot attach to the container: %v", err)
+	}
+	if p.TTY && !containerToAttach.TTY {
+		p.TTY = false
+		if p.Err != nil {
+			fmt.Fprintf(p.Err, "Unable to use a TTY - container %s did not allocate one\n", containerToAttach.Name)
+		}
+	}
+
+	// ensure we can recover the terminal while attached
+	t := p.setupTTY()
+
+	streams := p.overrideStreams
+	if streams == nil {
+		streams = dockerterm.StdStreams
+	}
+	stdin, stdout, stderr := streams()
+	cli, err := hyper.NewHyperCli(p.Config.Host, p.Config, stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	if err := cli.CheckTtyInput(p.Stdin, t.Raw); err != nil {
+		return err
+	}
+
+	options := types.ContainerAttachOptions{
+		Stream:     true,
+		Stdin:      p.Stdin,
+		Stdout:     true,
+		Stderr:     true,
+		DetachKeys: p.DetachKeys,
+	}
+
+	var (
+		sIn  io.ReadCloser
+		sErr = cli.Err
+	)
+	if options.Stdin {
+		sIn = cli.In
+	}
+	if t.Raw {
+		sErr = cli.Out
+	}
+
+	ctx, cancel := hyper.InterruptibleContext()
+	defer cancel()
+	glog.V(7).Infof("ContainerAttach: pod:%v container:%v id:%v options:%+v", pod.Name, containerToAttach.Name, containerID, options)
+	resp, err := cli.ContainerAttach(ctx, containerID, options)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	if !p.Quiet && cli.Err != nil && p.Stdin {
+		fmt.Fprintln(cli.Err, "If you don't see a command prompt, try pressing enter.")
+	}
+	if sIn != nil && t.Raw {
+		if err := cli.SetRawTerminal(); err != nil {
+			return err
+		}
+		defer cli.RestoreTerminal(sIn)
+	}
+	// the output of a container with a TTY is not multiplexed whether or
+	// not the local terminal is raw, it has to be read as is
+	errCh := promise.Go(func() error {
+		return cli.HoldHijackedConnection(containerToAttach.TTY, sIn, cli.Out, sErr, resp)
+	})
+
+	if t.Raw && cli.IsTerminalIn {
+		if err := cli.MonitorTtySize(ctx, containerID, false); err != nil {
+			fmt.Fprintf(cli.Err, "Error monitoring TTY size: %s\n", err)
+		}
+	}
+
+	if err := <-errCh; err != nil {
+		glog.Errorf("Error hijack: %s", err)
+		return err
+	}
+
+	// the connection also ends when the detach keys are typed, in which
+	// case the container keeps running
+	running, status, err := cli.GetExitCode(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if running {
+		if p.Stdin && t.Raw {
+			fmt.Fprintf(p.Out, "\r\nSession detached, resume using '%s %s -c %s -i -t' command\n", p.CommandName, pod.Name, containerToAttach.Name)
+		}
+		return nil
+	}
+	if status != 0 {
+		return hyper.StatusError{StatusCode: status}
+	}
+	return nil
+}
+
+// containerIDOf returns the ID of the container named name from the status of
+// pod, without the runtime prefix.
+func containerIDOf(pod *api.Pod, name string) (string, error) {
+	statuses := append([]api.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.Name != name {
+			continue
+		}
+		if len(status.ContainerID) == 0 {
+			return "", fmt.Errorf("container %s of pod %s has not been started", name, pod.Name)
+		}
+		if i := strings.Index(status.ContainerID, "://"); i >= 0 {
+			return status.ContainerID[i+3:], nil
+		}
+		return status.ContainerID, nil
+	}
+	return "", fmt.Errorf("no status reported for container %s of pod %s", name, pod.Name)
+}
+
 // containerToAttach returns a reference to the container to attach to, given
 // by name or the first container if name is empty.
 func (p *AttachOptions) containerToAttachTo(pod *api.Pod) (*api.Container, error) {
@@ -0,0 +1,246 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	restclient "github.com/hyperhq/client-go/rest"
+	"github.com/hyperhq/hypercli/pkg/stdcopy"
+	"github.com/hyperhq/pi/pkg/hyper"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+// fakeAttachServer is a Hyper API stand-in attaching to the containers of
+// attachTestPod. The hijacked connection echoes the input, multiplexed on
+// stdout and stderr unless the container has a TTY, and the containers have
+// exited with exitCode.
+type fakeAttachServer struct {
+	*httptest.Server
+	exitCode int
+
+	lock     sync.Mutex
+	requests []string
+}
+
+func newFakeAttachServer(exitCode int) *fakeAttachServer {
+	s := &fakeAttachServer{exitCode: exitCode}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		s.lock.Unlock()
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/attach"):
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			io.WriteString(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+			// the input ends with the write end of the client
+			input, _ := ioutil.ReadAll(conn)
+			if strings.Contains(r.URL.Path, "/id-app/") {
+				fmt.Fprintf(conn, "tty:%s", input)
+				return
+			}
+			fmt.Fprintf(stdcopy.NewStdWriter(conn, stdcopy.Stdout), "out:%s", input)
+			io.WriteString(stdcopy.NewStdWriter(conn, stdcopy.Stderr), "err\n")
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/json"):
+			fmt.Fprintf(w, `{"Id":"id","State":{"Running":false,"ExitCode":%d}}`, s.exitCode)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+// Requests returns the requests sent to the Hyper API.
+func (s *fakeAttachServer) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+// ClientConfig returns the config of a cluster at s.
+func (s *fakeAttachServer) ClientConfig() *restclient.Config {
+	config := defaultClientConfig()
+	config.Host = s.URL
+	config.Region = "gcp-us-central1"
+	config.AccessKey = "ak"
+	config.SecretKey = "sk"
+	config.CAData = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	return config
+}
+
+func attachTestPod() *api.Pod {
+	return &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "test"},
+		Spec: api.PodSpec{
+			InitContainers: []api.Container{{Name: "init"}},
+			Containers:     []api.Container{{Name: "app", TTY: true}, {Name: "worker"}, {Name: "pending"}},
+		},
+		Status: api.PodStatus{
+			Phase:                 api.PodRunning,
+			InitContainerStatuses: []api.ContainerStatus{{Name: "init", ContainerID: "hyper://id-init"}},
+			ContainerStatuses: []api.ContainerStatus{
+				{Name: "app", ContainerID: "hyper://id-app"},
+				{Name: "worker", ContainerID: "id-worker"},
+				{Name: "pending"},
+			},
+		},
+	}
+}
+
+func TestContainerIDOf(t *testing.T) {
+	tests := []struct {
+		container   string
+		expected    string
+		expectedErr string
+	}{
+		{container: "app", expected: "id-app"},
+		{container: "worker", expected: "id-worker"},
+		{container: "init", expected: "id-init"},
+		{container: "pending", expectedErr: "container pending of pod web has not been started"},
+		{container: "missing", expectedErr: "no status reported for container missing of pod web"},
+	}
+	for _, test := range tests {
+		id, err := containerIDOf(attachTestPod(), test.container)
+		if len(test.expectedErr) > 0 {
+			if err == nil || err.Error() != test.expectedErr {
+				t.Errorf("%s: expected error %q, got %v", test.container, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil || id != test.expected {
+			t.Errorf("%s: expected %q, got %q, %v", test.container, test.expected, id, err)
+		}
+	}
+}
+
+func TestAttachRunHyper(t *testing.T) {
+	tests := []struct {
+		name             string
+		container        string
+		stdin            bool
+		tty              bool
+		exitCode         int
+		expectedAttach   string
+		expectedOut      string
+		expectedErr      string
+		expectedErrorMsg string
+	}{
+		{
+			name:           "default container with a TTY",
+			expectedAttach: "/containers/id-app/attach?stderr=1&stdout=1&stream=1",
+			expectedOut:    "tty:",
+			expectedErr:    "Defaulting container name to app.\nUse 'pi describe pod/web' to see all of the containers in this pod.\n",
+		},
+		{
+			name:           "demuxed streams",
+			container:      "worker",
+			stdin:          true,
+			expectedAttach: "/containers/id-worker/attach?stderr=1&stdin=1&stdout=1&stream=1",
+			expectedOut:    "out:hello",
+			expectedErr:    "err\n",
+		},
+		{
+			name:           "container without TTY",
+			container:      "worker",
+			stdin:          true,
+			tty:            true,
+			expectedAttach: "/containers/id-worker/attach?stderr=1&stdin=1&stdout=1&stream=1",
+			expectedOut:    "out:hello",
+			expectedErr:    "Unable to use a TTY - container worker did not allocate one\nerr\n",
+		},
+		{
+			name:             "init container",
+			container:        "init",
+			exitCode:         3,
+			expectedAttach:   "/containers/id-init/attach?stderr=1&stdout=1&stream=1",
+			expectedOut:      "out:",
+			expectedErr:      "err\n",
+			expectedErrorMsg: hyper.StatusError{StatusCode: 3}.Error(),
+		},
+		{
+			name:             "container not found",
+			container:        "missing",
+			expectedErrorMsg: "cannot attach to the container: container not found (missing)",
+		},
+		{
+			name:             "container not started",
+			container:        "pending",
+			expectedErrorMsg: "cannot attach to the container: container pending of pod web has not been started",
+		},
+	}
+	for _, test := range tests {
+		server := newFakeAttachServer(test.exitCode)
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		p := &AttachOptions{
+			StreamOptions: StreamOptions{
+				Namespace:     "test",
+				PodName:       "web",
+				ContainerName: test.container,
+				Stdin:         test.stdin,
+				TTY:           test.tty,
+				Quiet:         true,
+				Out:           out,
+				Err:           errOut,
+				overrideStreams: func() (io.ReadCloser, io.Writer, io.Writer) {
+					return ioutil.NopCloser(strings.NewReader("hello")), out, errOut
+				},
+			},
+			CommandName:       "pi attach",
+			SuggestedCmdUsage: "Use 'pi describe pod/web' to see all of the containers in this pod.",
+			Pod:               attachTestPod(),
+			Config:            server.ClientConfig(),
+		}
+		err := p.RunHyper(nil)
+		server.Close()
+
+		if len(test.expectedErrorMsg) > 0 {
+			if err == nil || err.Error() != test.expectedErrorMsg {
+				t.Errorf("%s: expected error %q, got %v", test.name, test.expectedErrorMsg, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		requests := server.Requests()
+		switch {
+		case len(test.expectedAttach) == 0 && len(requests) > 0:
+			t.Errorf("%s: unexpected requests %v", test.name, requests)
+		case len(test.expectedAttach) > 0 && (len(requests) == 0 || !strings.HasSuffix(requests[0], test.expectedAttach)):
+			t.Errorf("%s: expected to attach with %s, got %v", test.name, test.expectedAttach, requests)
+		}
+		if out.String() != test.expectedOut {
+			t.Errorf("%s: expected output %q, got %q", test.name, test.expectedOut, out.String())
+		}
+		if errOut.String() != test.expectedErr {
+			t.Errorf("%s: expected error output %q, got %q", test.name, test.expectedErr, errOut.String())
+		}
+	}
+}
@@ -225,6 +225,7 @@ func NewPiCommand(f cmdutil.Factory, in io.Reader, out, err io.Writer) *cobra.Co
 				resource.NewCmdGet(f, out, err),
 				NewCmdDelete(f, out, err),
 				NewCmdRun(f, in, out, err),
+				NewCmdExpose(f, out),
 				NewCmdName(f, out, err),
 			},
 		},
@@ -232,8 +233,19 @@ func NewPiCommand(f cmdutil.Factory, in io.Reader, out, err io.Writer) *cobra.Co
 			Message: "Troubleshooting and Debugging Commands:",
 			Commands: []*cobra.Command{
 				NewCmdDescribe(f, out, err),
-				NewCmdLogs(f, out),
+				NewCmdLogs(f, out, err),
+				NewCmdAttach(f, in, out, err),
 				NewCmdExec(f, in, out, err),
+				NewCmdCp(f, out, err),
+				NewCmdTop(f, out, err),
+				NewCmdWait(f, out),
+				NewCmdDoctor(f, out),
+			},
+		},
+		{
+			Message: "Advanced Commands:",
+			Commands: []*cobra.Command{
+				NewCmdApply(f, out, err),
 			},
 		},
 	}
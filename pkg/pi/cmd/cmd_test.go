@@ -25,6 +25,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	goStrings "strings"
 	"testing"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"github.com/hyperhq/pi/pkg/printers"
 	printersinternal "github.com/hyperhq/pi/pkg/printers/internalversion"
 
+	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -218,8 +220,41 @@ func Example_printPodWithWideFormat() {
 		fmt.Printf("Unexpected error: %v", err)
 	}
 	// Output:
-	// NAME      READY     STATUS     RESTARTS   AGE       IP         NODE
-	// test1     1/2       podPhase   6          10y       10.1.1.3   kubernetes-node-abcd
+	// NAME      READY     STATUS     RESTARTS   AGE       IP         NODE                   INSTANCE
+	// test1     1/2       podPhase   6          10y       10.1.1.3   kubernetes-node-abcd   s4
+}
+
+func TestPrintPodInstanceTypeColumn(t *testing.T) {
+	tests := map[string]string{
+		"pod-instance-type-32m.yaml": "s1",
+		"pod-instance-type-64m.yaml": "s1",
+		"pod-instance-type-2g.yaml":  "m2",
+		"pod-instance-type-4g.yaml":  "m3",
+		"pod-instance-type-8g.yaml":  "l1",
+		"pod-instance-type-16g.yaml": "l2",
+		"pod-instance-type-17g.yaml": "l3",
+	}
+	for file, expected := range tests {
+		data, err := ioutil.ReadFile("../../../examples/pod/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pod, err := runtime.Decode(testapi.Default.Codec(), data)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		p := printers.NewHumanReadablePrinter(nil, nil, printers.PrintOptions{Wide: true})
+		printersinternal.AddHandlers(p)
+		buf := bytes.NewBuffer([]byte{})
+		if err := p.PrintObj(pod, buf); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		lines := goStrings.Split(goStrings.TrimSpace(buf.String()), "\n")
+		header, row := goStrings.Fields(lines[0]), goStrings.Fields(lines[len(lines)-1])
+		if header[len(header)-1] != "INSTANCE" || row[len(row)-1] != expected {
+			t.Errorf("%s: expected the INSTANCE column to be %s, got:\n%s", file, expected, buf.String())
+		}
+	}
 }
 
 func Example_printPodWithShowLabels() {
@@ -370,7 +405,9 @@ func Example_printPodHideTerminated() {
 		NegotiatedSerializer: ns,
 		Client:               nil,
 	}
-	cmd := NewCmdRun(f, os.Stdin, os.Stdout, os.Stderr)
+	// pi run has no printer flags, the filter reads them
+	cmd := &cobra.Command{}
+	cmdutil.AddPrinterFlags(cmd)
 	podList := newAllPhasePodList()
 	// filter pods
 	filterFuncs := f.DefaultResourceFilterFunc()
@@ -489,9 +526,9 @@ func Example_printServiceWithNamespacesAndLabels() {
 		fmt.Printf("Unexpected error: %v", err)
 	}
 	// Output:
-	// |NAMESPACE   NAME      TYPE        CLUSTER-IP   EXTERNAL-IP   PORT(S)           AGE       L1|
-	// |ns1         svc1      ClusterIP   10.1.1.1     <none>        53/UDP,53/TCP     10y       value|
-	// |ns2         svc2      ClusterIP   10.1.1.2     <none>        80/TCP,8080/TCP   10y       dolla-bill-yall|
+	// |NAMESPACE   NAME      TYPE        CLUSTER-IP   LOADBALANCER-IP   PORT(S)           AGE       L1|
+	// |ns1         svc1      ClusterIP   10.1.1.1     <none>            53/UDP,53/TCP     10y       value|
+	// |ns2         svc2      ClusterIP   10.1.1.2     <none>            80/TCP,8080/TCP   10y       dolla-bill-yall|
 	// ||
 }
 
@@ -157,8 +157,6 @@ func (o *createAuthInfoOptions) modifyDefaultCluster(existingCluster clientcmdap
 		modifiedCluster.Server = clientcmd.DefaultServer
 	}
 
-	modifiedCluster.InsecureSkipTLSVerify = true
-
 	return modifiedCluster
 }
 
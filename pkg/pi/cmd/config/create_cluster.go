@@ -27,12 +27,16 @@ import (
 
 	"github.com/hyperhq/client-go/tools/clientcmd"
 	clientcmdapi "github.com/hyperhq/client-go/tools/clientcmd/api"
+	"github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
 	"github.com/hyperhq/pi/pkg/pi/util/i18n"
 	"k8s.io/apiserver/pkg/util/flag"
 )
 
+// flagProxyURL sets the proxy of the cluster, see hyper.ProxyExtension.
+const flagProxyURL = "proxy-url"
+
 type createClusterOptions struct {
 	configAccess          clientcmd.ConfigAccess
 	name                  string
@@ -40,6 +44,7 @@ type createClusterOptions struct {
 	insecureSkipTLSVerify flag.Tristate
 	certificateAuthority  flag.StringFlag
 	embedCAData           flag.Tristate
+	proxyURL              flag.StringFlag
 }
 
 var (
@@ -56,7 +61,13 @@ var (
 		pi config set-cluster e2e --certificate-authority=~/.pi/e2e/kubernetes.ca.crt
 
 		# Disable cert checking for the dev cluster entry
-		pi config set-cluster e2e --insecure-skip-tls-verify=true`)
+		pi config set-cluster e2e --insecure-skip-tls-verify=true
+
+		# Send the requests to the e2e cluster through an HTTP proxy
+		pi config set-cluster e2e --proxy-url=http://proxy.example.com:3128
+
+		# Stop using a proxy for the e2e cluster
+		pi config set-cluster e2e --proxy-url=""`)
 )
 
 func NewCmdConfigSetCluster(out io.Writer, configAccess clientcmd.ConfigAccess) *cobra.Command {
@@ -83,6 +94,7 @@ func NewCmdConfigSetCluster(out io.Writer, configAccess clientcmd.ConfigAccess)
 	cmd.MarkFlagFilename(clientcmd.FlagCAFile)
 	f = cmd.Flags().VarPF(&options.embedCAData, clientcmd.FlagEmbedCerts, "", clientcmd.FlagEmbedCerts+" for the cluster entry in pi config")
 	f.NoOptDefVal = "true"
+	cmd.Flags().Var(&options.proxyURL, flagProxyURL, "URL of the HTTP proxy for the cluster entry in pi config, overrides the HTTPS_PROXY environment variable")
 
 	return cmd
 }
@@ -143,6 +155,9 @@ func (o *createClusterOptions) modifyCluster(existingCluster clientcmdapi.Cluste
 			}
 		}
 	}
+	if o.proxyURL.Provided() {
+		hyper.SetClusterProxyURL(&modifiedCluster, o.proxyURL.Value())
+	}
 
 	return modifiedCluster
 }
@@ -173,6 +188,11 @@ func (o createClusterOptions) validate() error {
 			return fmt.Errorf("could not read %s data from %s: %v", clientcmd.FlagCAFile, caPath, err)
 		}
 	}
+	if proxyURL := o.proxyURL.Value(); proxyURL != "" {
+		if _, err := hyper.ParseProxyURL(proxyURL); err != nil {
+			return fmt.Errorf("invalid --%s: %v", flagProxyURL, err)
+		}
+	}
 
 	return nil
 }
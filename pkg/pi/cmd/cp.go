@@ -0,0 +1,510 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperhq/pi/pkg/hyper"
+	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/pi/util/i18n"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+var (
+	cpExample = templates.Examples(i18n.T(`
+		# !!!Important Note!!!
+		# Requires that the 'tar' binary is present in your container
+		# image.  If 'tar' is not present, 'pi cp' will fail.
+
+		# Copy /tmp/foo_dir local directory to /tmp/bar_dir in a pod
+		pi cp /tmp/foo_dir some-pod:/tmp/bar_dir
+
+		# Copy /tmp/foo local file to /tmp/bar in a specific container
+		pi cp /tmp/foo some-pod:/tmp/bar -c specific-container
+
+		# Copy /tmp/foo from a pod to /tmp/bar locally
+		pi cp some-pod:/tmp/foo /tmp/bar`))
+
+	cpUsageStr = "expected 'cp <file-spec-src> <file-spec-dest> [-c container]'.\n" +
+		"<file-spec> is:\n" +
+		"\t<pod-name>:<path>\n" +
+		"\t<local-path>"
+)
+
+// CopyOptions have the data required to perform the copy operation
+type CopyOptions struct {
+	Container string
+	Namespace string
+
+	Out    io.Writer
+	ErrOut io.Writer
+}
+
+// NewCmdCp creates a new Copy command.
+func NewCmdCp(f cmdutil.Factory, cmdOut, cmdErr io.Writer) *cobra.Command {
+	o := &CopyOptions{
+		Out:    cmdOut,
+		ErrOut: cmdErr,
+	}
+	cmd := &cobra.Command{
+		Use:     "cp <file-spec-src> <file-spec-dest>",
+		Short:   i18n.T("Copy files and directories to and from containers."),
+		Long:    "Copy files and directories to and from containers.",
+		Example: cpExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Run(f, cmd, args))
+		},
+	}
+	cmd.Flags().StringVarP(&o.Container, "container", "c", "", "Container name. If omitted, the first container in the pod will be chosen")
+	return cmd
+}
+
+type fileSpec struct {
+	PodName string
+	File    string
+}
+
+var (
+	errFileSpecDoesntMatchFormat = errors.New("Filespec must match the canonical format: [pod:]file/path")
+	errFileCannotBeEmpty         = errors.New("Filepath can not be empty")
+)
+
+func extractFileSpec(arg string) (fileSpec, error) {
+	pieces := strings.Split(arg, ":")
+	if len(pieces) == 1 {
+		return fileSpec{File: arg}, nil
+	}
+	if len(pieces) != 2 {
+		// FIXME Kubernetes can't copy files that contain a ':'
+		// character.
+		return fileSpec{}, errFileSpecDoesntMatchFormat
+	}
+	file := pieces[1]
+	if len(file) == 0 {
+		return fileSpec{}, errFileCannotBeEmpty
+	}
+	return fileSpec{
+		PodName: pieces[0],
+		File:    file,
+	}, nil
+}
+
+// Run copies between the local filesystem and a container, depending on which
+// of the two arguments names a pod.
+func (o *CopyOptions) Run(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return cmdutil.UsageErrorf(cmd, cpUsageStr)
+	}
+	srcSpec, err := extractFileSpec(args[0])
+	if err != nil {
+		return err
+	}
+	destSpec, err := extractFileSpec(args[1])
+	if err != nil {
+		return err
+	}
+	if o.Namespace, _, err = f.DefaultNamespace(); err != nil {
+		return err
+	}
+
+	switch {
+	case len(srcSpec.PodName) != 0 && len(destSpec.PodName) != 0:
+		return errors.New("copying between two pods is not supported, one of the file specs must be local")
+	case len(srcSpec.PodName) != 0:
+		return o.copyFromPod(f, srcSpec, destSpec)
+	case len(destSpec.PodName) != 0:
+		return o.copyToPod(f, srcSpec, destSpec)
+	}
+	return cmdutil.UsageErrorf(cmd, "One of src or dest must be a remote file specification")
+}
+
+// execInPod runs cmd in the container of the pod named by spec, and fails
+// when it exits with a non-zero code.
+func (o *CopyOptions) execInPod(f cmdutil.Factory, spec fileSpec, cmd []string, stdin io.Reader, stdout io.Writer) error {
+	clientset, err := f.ClientSet()
+	if err != nil {
+		return err
+	}
+	pod, err := clientset.Core().Pods(o.Namespace).Get(spec.PodName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed {
+		return fmt.Errorf("cannot copy files of a container in a completed pod; current phase is %s", pod.Status.Phase)
+	}
+	containerName := o.Container
+	if len(containerName) == 0 {
+		containerName = pod.Spec.Containers[0].Name
+	}
+
+	cfg, err := f.ClientConfig()
+	if err != nil {
+		return err
+	}
+	cli, err := hyper.NewHyperCli(cfg.Host, cfg, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	stderr := &bytes.Buffer{}
+	ctx, cancel := hyper.InterruptibleContext()
+	defer cancel()
+	status, err := cli.ExecStream(ctx, pod.Name, containerName, cmd, stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("%q exited with code %d: %s", strings.Join(cmd, " "), status, strings.TrimSpace(stderr.String()))
+	}
+	if stderr.Len() > 0 {
+		o.ErrOut.Write(stderr.Bytes())
+	}
+	return nil
+}
+
+func (o *CopyOptions) copyToPod(f cmdutil.Factory, src, dest fileSpec) error {
+	if len(src.File) == 0 {
+		return errFileCannotBeEmpty
+	}
+	if _, err := os.Lstat(src.File); err != nil {
+		return err
+	}
+	reader, writer := io.Pipe()
+
+	// strip trailing slash (if any)
+	if dest.File != "/" && strings.HasSuffix(string(dest.File[len(dest.File)-1]), "/") {
+		dest.File = dest.File[:len(dest.File)-1]
+	}
+
+	go func() {
+		defer writer.Close()
+		err := makeTar(src.File, dest.File, writer)
+		writer.CloseWithError(err)
+	}()
+
+	cmdArr := []string{"tar", "xf", "-"}
+	destDir := path.Dir(dest.File)
+	if len(destDir) > 0 {
+		cmdArr = append(cmdArr, "-C", destDir)
+	}
+	return o.execInPod(f, dest, cmdArr, reader, nil)
+}
+
+func (o *CopyOptions) copyFromPod(f cmdutil.Factory, src, dest fileSpec) error {
+	if len(src.File) == 0 {
+		return errFileCannotBeEmpty
+	}
+
+	reader, outStream := io.Pipe()
+	go func() {
+		defer outStream.Close()
+		err := o.execInPod(f, src, []string{"tar", "cf", "-", src.File}, nil, outStream)
+		outStream.CloseWithError(err)
+	}()
+
+	// remove extraneous path shortcuts - these could occur if a path contained extra "../"
+	// and attempted to navigate beyond "/" in a remote filesystem
+	prefix := stripPathShortcuts(path.Clean(src.File))
+	return untarAll(reader, dest.File, prefix, o.ErrOut)
+}
+
+// stripPathShortcuts removes any leading or trailing "../" from a given path
+func stripPathShortcuts(p string) string {
+	newPath := path.Clean(p)
+	trimmed := strings.TrimPrefix(newPath, "../")
+
+	for trimmed != newPath {
+		newPath = trimmed
+		trimmed = strings.TrimPrefix(newPath, "../")
+	}
+
+	// trim leftover {".", ".."}
+	if newPath == "." || newPath == ".." {
+		newPath = ""
+	}
+
+	if len(newPath) > 0 && string(newPath[0]) == "/" {
+		return newPath[1:]
+	}
+
+	return newPath
+}
+
+func makeTar(srcPath, destPath string, writer io.Writer) error {
+	// TODO: use compression here?
+	tarWriter := tar.NewWriter(writer)
+	defer tarWriter.Close()
+
+	srcPath = path.Clean(srcPath)
+	destPath = path.Clean(destPath)
+	return recursiveTar(path.Dir(srcPath), path.Base(srcPath), path.Dir(destPath), path.Base(destPath), tarWriter)
+}
+
+func recursiveTar(srcBase, srcFile, destBase, destFile string, tw *tar.Writer) error {
+	filepath := path.Join(srcBase, srcFile)
+	stat, err := os.Lstat(filepath)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		files, err := ioutil.ReadDir(filepath)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			//case empty directory
+			hdr, _ := tar.FileInfoHeader(stat, filepath)
+			hdr.Name = destFile
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+		}
+		for _, f := range files {
+			if err := recursiveTar(srcBase, path.Join(srcFile, f.Name()), destBase, path.Join(destFile, f.Name()), tw); err != nil {
+				return err
+			}
+		}
+		return nil
+	} else if stat.Mode()&os.ModeSymlink != 0 {
+		//case soft link
+		hdr, _ := tar.FileInfoHeader(stat, filepath)
+		target, err := os.Readlink(filepath)
+		if err != nil {
+			return err
+		}
+
+		hdr.Linkname = target
+		hdr.Name = destFile
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+	} else {
+		//case regular file or other file type like pipe
+		hdr, err := tar.FileInfoHeader(stat, filepath)
+		if err != nil {
+			return err
+		}
+		hdr.Name = destFile
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		f, err := os.Open(filepath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(tw, f); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	return nil
+}
+
+// dirMode is the mode of a directory extracted by untarAll.
+type dirMode struct {
+	path string
+	mode os.FileMode
+}
+
+// untarAll extracts the archive read from reader into destFile, dropping prefix
+// from the names of its entries. Entries escaping destFile, either by their
+// name or through a symlink, are skipped with a warning.
+func untarAll(reader io.Reader, destFile, prefix string, errOut io.Writer) error {
+	// TODO: use compression here?
+	tarReader := tar.NewReader(reader)
+	symlinkWarningPrinted := false
+	// the entries are checked against the destination with its own symlinks
+	// resolved, as those resolved in their paths
+	resolvedDest, err := resolveExistingPath(destFile)
+	if err != nil {
+		return err
+	}
+	// the modes of the directories are applied once they are filled, a
+	// read-only directory would otherwise reject its own entries
+	dirModes := []dirMode{}
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+
+		// All the files will start with the prefix, which is the directory where
+		// they were located on the pod, we need to strip down that prefix, but
+		// if the prefix is missing it means the tar was tempered with.
+		// For the case where prefix is empty we need to ensure that the path
+		// is not absolute, which also indicates the tar file was tempered with.
+		if !strings.HasPrefix(header.Name, prefix) {
+			return fmt.Errorf("tar contents corrupted")
+		}
+
+		// basic file information
+		mode := header.FileInfo().Mode()
+		destFileName := filepath.Join(destFile, header.Name[len(prefix):])
+
+		if !isDestRelative(destFile, destFileName) {
+			fmt.Fprintf(errOut, "warning: file %q is outside target destination, skipping\n", destFileName)
+			continue
+		}
+
+		// a link extracted earlier may redirect the parent directory, e.g.
+		// d/up -> .. then d/up/up2 -> .. and a file d/up/up2/f
+		baseName := filepath.Dir(destFileName)
+		resolvedBaseName, err := resolveExistingPath(baseName)
+		if err != nil {
+			return err
+		}
+		if !isDestRelative(resolvedDest, resolvedBaseName) {
+			fmt.Fprintf(errOut, "warning: file %q is outside target destination through a symlink, skipping\n", destFileName)
+			continue
+		}
+		if err := os.MkdirAll(baseName, 0755); err != nil {
+			return err
+		}
+		if header.FileInfo().IsDir() {
+			if err := os.MkdirAll(destFileName, 0755); err != nil {
+				return err
+			}
+			dirModes = append(dirModes, dirMode{destFileName, mode.Perm()})
+			continue
+		}
+
+		if mode&os.ModeSymlink != 0 {
+			// only links pointing inside the destination are created, an archive
+			// could otherwise plant a link to write through later on
+			linkTarget := header.Linkname
+			if !filepath.IsAbs(linkTarget) {
+				linkTarget = filepath.Join(resolvedBaseName, linkTarget)
+			}
+			resolvedLinkTarget, err := resolveExistingPath(linkTarget)
+			if err != nil {
+				return err
+			}
+			if !isDestRelative(resolvedDest, resolvedLinkTarget) {
+				if !symlinkWarningPrinted {
+					fmt.Fprintf(errOut, "warning: skipping symlink: %q -> %q (points outside the target destination)\n", destFileName, header.Linkname)
+					symlinkWarningPrinted = true
+				}
+				continue
+			}
+			// the link may already exist from a previous copy
+			os.Remove(destFileName)
+			if err := os.Symlink(header.Linkname, destFileName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !mode.IsRegular() {
+			glog.V(4).Infof("skipping %q of mode %v", destFileName, mode)
+			continue
+		}
+		// never write through a symlink that is already in place
+		if fi, err := os.Lstat(destFileName); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			fmt.Fprintf(errOut, "warning: file %q is a symlink, skipping\n", destFileName)
+			continue
+		}
+		outFile, err := os.OpenFile(destFileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(outFile, tarReader); err != nil {
+			outFile.Close()
+			return err
+		}
+		if err := outFile.Close(); err != nil {
+			return err
+		}
+		// the umask applied by OpenFile must not drop the mode of the archive
+		if err := os.Chmod(destFileName, mode.Perm()); err != nil {
+			return err
+		}
+	}
+	// the nested directories come after their parents in the archive, their
+	// modes are applied first so that a parent can't deny access to them
+	for i := len(dirModes) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirModes[i].path, dirModes[i].mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isDestRelative returns true if dest is pointing outside the base directory,
+// false otherwise.
+func isDestRelative(base, dest string) bool {
+	relative, err := filepath.Rel(base, dest)
+	if err != nil {
+		return false
+	}
+	return relative == "." || relative == stripPathShortcuts(relative)
+}
+
+// resolveExistingPath returns the absolute path with the symlinks of its
+// longest existing prefix resolved. The rest of path is not there yet, so it
+// can't hold a symlink and is kept as it is.
+func resolveExistingPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		// a dangling link is resolved to where it would create its target
+		if target, err := os.Readlink(path); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			return resolveExistingPath(filepath.Join(target, missing))
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, missing), nil
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
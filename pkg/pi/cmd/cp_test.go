@@ -0,0 +1,168 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	linkname string
+	content  string
+	// dirMode makes the entry a directory of that mode
+	dirMode int64
+}
+
+func newTestTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	writer := tar.NewWriter(buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entry.content))}
+		if len(entry.linkname) > 0 {
+			header = &tar.Header{Name: entry.name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: entry.linkname}
+		}
+		if entry.dirMode != 0 {
+			header = &tar.Header{Name: entry.name, Mode: entry.dirMode, Typeflag: tar.TypeDir}
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestUntarAllStaysInDestination(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		// the files expected in dest, relative to it
+		expected []string
+	}{
+		{
+			name: "symlink chain",
+			entries: []tarEntry{
+				{name: "src/d/up", linkname: ".."},
+				{name: "src/d/up/up2", linkname: ".."},
+				{name: "src/d/up/up2/pwned", content: "pwned"},
+			},
+			expected: []string{"d/up"},
+		},
+		{
+			name: "file through a link to the parent",
+			entries: []tarEntry{
+				{name: "src/up", linkname: ".."},
+				{name: "src/up/pwned", content: "pwned"},
+			},
+		},
+		{
+			name: "absolute link",
+			entries: []tarEntry{
+				{name: "src/abs", linkname: "/tmp"},
+				{name: "src/abs/pwned", content: "pwned"},
+			},
+		},
+		{
+			name: "dot dot target",
+			entries: []tarEntry{
+				{name: "src/../pwned", content: "pwned"},
+				{name: "src/d/../../pwned", content: "pwned"},
+			},
+		},
+		{
+			name: "links inside",
+			entries: []tarEntry{
+				{name: "src/d/f", content: "f"},
+				{name: "src/d/link", linkname: "f"},
+				{name: "src/e/link", linkname: "../d"},
+				{name: "src/e/link/g", content: "g"},
+			},
+			expected: []string{"d/f", "d/link", "e/link", "d/g"},
+		},
+	}
+	for _, test := range tests {
+		root, err := ioutil.TempDir("", "cp-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest := filepath.Join(root, "a", "dest")
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		errOut := &bytes.Buffer{}
+		if err := untarAll(newTestTar(t, test.entries), dest, "src", errOut); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		for _, outside := range []string{filepath.Join(root, "a", "pwned"), filepath.Join(root, "pwned"), "/tmp/pwned"} {
+			if _, err := os.Lstat(outside); err == nil {
+				t.Errorf("%s: %s was written outside the destination", test.name, outside)
+			}
+		}
+		for _, file := range test.expected {
+			if _, err := os.Lstat(filepath.Join(dest, file)); err != nil {
+				t.Errorf("%s: expected %s in the destination: %v", test.name, file, err)
+			}
+		}
+		os.RemoveAll(root)
+	}
+}
+
+func TestUntarAllReadOnlyDirectory(t *testing.T) {
+	dest, err := ioutil.TempDir("", "cp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+
+	entries := []tarEntry{
+		{name: "src/ro/", dirMode: 0555},
+		{name: "src/ro/sub/", dirMode: 0500},
+		{name: "src/ro/sub/f", content: "f"},
+		{name: "src/ro/g", content: "g"},
+	}
+	if err := untarAll(newTestTar(t, entries), dest, "src", &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// let the directories be removed again
+	defer os.Chmod(filepath.Join(dest, "ro", "sub"), 0755)
+	defer os.Chmod(filepath.Join(dest, "ro"), 0755)
+
+	for file, content := range map[string]string{"ro/sub/f": "f", "ro/g": "g"} {
+		data, err := ioutil.ReadFile(filepath.Join(dest, file))
+		if err != nil || string(data) != content {
+			t.Errorf("expected %q in %s, got %q, %v", content, file, data, err)
+		}
+	}
+	for dir, mode := range map[string]os.FileMode{"ro": 0555, "ro/sub": 0500} {
+		fi, err := os.Stat(filepath.Join(dest, dir))
+		if err != nil || fi.Mode().Perm() != mode {
+			t.Errorf("expected %s to have mode %v, got %v, %v", dir, mode, fi, err)
+		}
+	}
+}
@@ -19,11 +19,15 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"net"
 	//"net/url"
 	"os"
+	"sort"
 	//"strings"
 
 	"github.com/hyperhq/client-go/tools/clientcmd/api/hyper"
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+	hyperconn "github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi"
 	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
@@ -32,7 +36,12 @@ import (
 
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	api "k8s.io/kubernetes/pkg/apis/core"
 )
 
 type CreateOptions struct {
@@ -46,7 +55,7 @@ var (
 	createLong = templates.LongDesc(i18n.T(`
 		Create a resource(pod, service, secret, volume, fip).
 
-		JSON and YAML formats are accepted(pod, service, secret).`))
+		JSON and YAML formats are accepted(pod, service, secret, volume, fip).`))
 
 	createExample = templates.Examples(i18n.T(`
 		# Create a pod using the data in yaml.
@@ -56,10 +65,19 @@ var (
 		pi create -f pod-test1.yaml -f pod-test2.yaml
 
 		# Create a service using the data in yaml.
-		pi create -f examples/service/service-nginx.yaml
+		pi create -f examples/service/template/service-nginx.yaml
 
 		# Create a secret using the data in yaml.
-		pi create -f examples/secret/secret-dockerconfigjson.yaml`))
+		pi create -f examples/secret/secret-dockerconfigjson.yaml
+
+		# Create volumes, fips, pods and services of a whole stack, volumes and fips first.
+		pi create -f examples/all/nginx-all-in-one.yaml
+
+		# Create a service from a template, setting its ${FIP} variable.
+		pi create -f wordpress-service.tpl.yaml --set FIP=35.184.x.x
+
+		# Print a template with the variables expanded from a values file and the environment.
+		pi create -f mysql-secret.tpl.yaml --values values.yaml --values-from-env --render`))
 )
 
 func NewCmdCreate(f cmdutil.Factory, out, errOut io.Writer) *cobra.Command {
@@ -84,12 +102,13 @@ func NewCmdCreate(f cmdutil.Factory, out, errOut io.Writer) *cobra.Command {
 	usage := "to use to create the resource"
 	cmdutil.AddFilenameOptionFlags(cmd, &options.FilenameOptions, usage)
 	cmd.MarkFlagRequired("filename")
-	//cmdutil.AddValidateFlags(cmd)
+	cmdutil.AddRenderFlag(cmd)
+	cmdutil.AddValidateFlags(cmd)
 	//cmdutil.AddPrinterFlags(cmd)
 	//cmd.Flags().BoolVar(&options.EditBeforeCreate, "edit", false, "Edit the API resource before creating")
 	//cmd.Flags().Bool("windows-line-endings", runtime.GOOS == "windows",
 	//	"Only relevant if --edit=true. Defaults to the line ending native to your platform.")
-	//cmdutil.AddApplyAnnotationFlags(cmd)
+	cmdutil.AddApplyAnnotationFlags(cmd)
 	//cmdutil.AddRecordFlag(cmd)
 	//cmdutil.AddDryRunFlag(cmd)
 	//cmd.Flags().StringVarP(&options.Selector, "selector", "l", "", "Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
@@ -164,10 +183,14 @@ func RunCreate(f cmdutil.Factory, cmd *cobra.Command, out, errOut io.Writer, opt
 		return nil
 	}
 
-	//schema, err := f.Validator(cmdutil.GetFlagBool(cmd, "validate"))
-	//if err != nil {
-	//	return err
-	//}
+	if cmdutil.GetFlagBool(cmd, "render") {
+		return options.FilenameOptions.Render(out)
+	}
+
+	schema, err := f.Validator(cmdutil.GetFlagBool(cmd, "validate"))
+	if err != nil {
+		return err
+	}
 
 	cmdNamespace, enforceNamespace, err := f.DefaultNamespace()
 	if err != nil {
@@ -176,7 +199,7 @@ func RunCreate(f cmdutil.Factory, cmd *cobra.Command, out, errOut io.Writer, opt
 
 	r := f.NewBuilder().
 		Unstructured().
-		//Schema(schema).
+		Schema(schema).
 		ContinueOnError().
 		NamespaceParam(cmdNamespace).DefaultNamespace().
 		FilenameParam(enforceNamespace, &options.FilenameOptions).
@@ -195,11 +218,40 @@ func RunCreate(f cmdutil.Factory, cmd *cobra.Command, out, errOut io.Writer, opt
 
 	mapper := r.Mapper().RESTMapper
 
-	count := 0
-	err = r.Visit(func(info *resource.Info, err error) error {
+	// volumes and fips are created first, so that the pods and services
+	// in the same files can reference them
+	infos := []*resource.Info{}
+	visitErr := r.Visit(func(info *resource.Info, err error) error {
 		if err != nil {
 			return err
 		}
+		infos = append(infos, info)
+		return nil
+	})
+	sort.Stable(byCreationOrder(infos))
+
+	errs := []error{}
+	if visitErr != nil {
+		errs = append(errs, visitErr)
+	}
+	// pods may use the volumes of any of the files, nothing is created
+	// when they are missing
+	if err := resource.CompleteSchema(schema); err != nil {
+		return utilerrors.Flatten(utilerrors.NewAggregate(append(errs, err)))
+	}
+	count := 0
+	// the volumes and fips which failed to be created, the objects using
+	// them are skipped
+	failed := failedDependencies{}
+	for _, info := range infos {
+		if dependency, found := failed.usedBy(info); found {
+			errs = append(errs, cmdutil.AddSourceToErr("creating", info.Source, fmt.Errorf("skipped %s %q, %s could not be created", info.Mapping.Resource, info.Name, dependency)))
+			continue
+		}
+		if err := pi.CreateOrUpdateAnnotation(cmdutil.GetFlagBool(cmd, cmdutil.ApplyAnnotationsFlag), info, unstructured.UnstructuredJSONScheme); err != nil {
+			errs = append(errs, cmdutil.AddSourceToErr("creating", info.Source, err))
+			continue
+		}
 
 		//if cmdutil.ShouldRecord(cmd, info) {
 		//	if err := cmdutil.RecordChangeCause(info.Object, f.Command(cmd, false)); err != nil {
@@ -207,9 +259,16 @@ func RunCreate(f cmdutil.Factory, cmd *cobra.Command, out, errOut io.Writer, opt
 		//	}
 		//}
 
+		if err := resolveLoadBalancerIP(f, mapper, info); err != nil {
+			errs = append(errs, cmdutil.AddSourceToErr("creating", info.Source, err))
+			continue
+		}
+
 		if !dryRun {
 			if err := createAndRefresh(info); err != nil {
-				return cmdutil.AddSourceToErr("creating", info.Source, err)
+				errs = append(errs, cmdutil.AddSourceToErr("creating", info.Source, err))
+				failed.insert(info)
+				continue
 			}
 		}
 
@@ -217,21 +276,123 @@ func RunCreate(f cmdutil.Factory, cmd *cobra.Command, out, errOut io.Writer, opt
 
 		shortOutput := output == "name"
 		if len(output) > 0 && !shortOutput {
-			return f.PrintResourceInfoForCommand(cmd, info, out)
+			if err := f.PrintResourceInfoForCommand(cmd, info, out); err != nil {
+				errs = append(errs, err)
+			}
+			continue
 		}
 		if !shortOutput {
 			f.PrintObjectSpecificMessage(info.Object, out)
 		}
 
 		f.PrintSuccess(mapper, shortOutput, out, info.Mapping.Resource, info.Name, dryRun, "created")
+	}
+	if len(errs) > 0 {
+		return utilerrors.Flatten(utilerrors.NewAggregate(errs))
+	}
+	if count == 0 {
+		return fmt.Errorf("no objects passed to create")
+	}
+	return nil
+}
+
+// dependencyKinds are the kinds the pods and services of a manifest may refer to.
+var dependencyKinds = map[schema.GroupKind]bool{
+	hyperapi.Kind("Volume"): true,
+	hyperapi.Kind("Fip"):    true,
+}
+
+// byCreationOrder sorts volumes and fips ahead of the other objects, keeping
+// the order of the files otherwise.
+type byCreationOrder []*resource.Info
+
+func (o byCreationOrder) Len() int      { return len(o) }
+func (o byCreationOrder) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o byCreationOrder) Less(i, j int) bool {
+	return dependencyKinds[o[i].Mapping.GroupVersionKind.GroupKind()] && !dependencyKinds[o[j].Mapping.GroupVersionKind.GroupKind()]
+}
+
+// failedDependencies are the names of the volumes and fips which failed to be
+// created, by kind.
+type failedDependencies map[schema.GroupKind]sets.String
+
+func (failed failedDependencies) insert(info *resource.Info) {
+	gk := info.Mapping.GroupVersionKind.GroupKind()
+	if !dependencyKinds[gk] {
+		return
+	}
+	if failed[gk] == nil {
+		failed[gk] = sets.NewString()
+	}
+	failed[gk].Insert(info.Name)
+}
+
+// usedBy returns the failed volume or fip a pod or a service uses, if any.
+func (failed failedDependencies) usedBy(info *resource.Info) (string, bool) {
+	if len(failed) == 0 {
+		return "", false
+	}
+	obj, ok := info.Object.(runtime.Unstructured)
+	if !ok {
+		return "", false
+	}
+	content := obj.UnstructuredContent()
+	switch info.Mapping.GroupVersionKind.GroupKind() {
+	case api.Kind("Pod"):
+		volumes, _ := unstructured.NestedSlice(content, "spec", "volumes")
+		for _, volume := range volumes {
+			volume, ok := volume.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := unstructured.NestedString(volume, "flexVolume", "options", "volumeID")
+			if failed[hyperapi.Kind("Volume")].Has(id) {
+				return fmt.Sprintf("volume %q", id), true
+			}
+		}
+	case api.Kind("Service"):
+		fipName, _ := unstructured.NestedString(content, "spec", "loadBalancerIP")
+		if failed[hyperapi.Kind("Fip")].Has(fipName) {
+			return fmt.Sprintf("fip %q", fipName), true
+		}
+	}
+	return "", false
+}
+
+// resolveLoadBalancerIP replaces a fip name given as spec.loadBalancerIP of a
+// service with the address of that fip.
+func resolveLoadBalancerIP(f cmdutil.Factory, mapper meta.RESTMapper, info *resource.Info) error {
+	if info.Mapping.GroupVersionKind.GroupKind() != api.Kind("Service") {
 		return nil
-	})
+	}
+	obj, ok := info.Object.(runtime.Unstructured)
+	if !ok {
+		return nil
+	}
+	content := obj.UnstructuredContent()
+	fipName, _ := unstructured.NestedString(content, "spec", "loadBalancerIP")
+	if len(fipName) == 0 || net.ParseIP(fipName) != nil {
+		return nil
+	}
+
+	mapping, err := mapper.RESTMapping(hyperapi.Kind("Fip"), hyperapi.SchemeGroupVersionV1.Version)
 	if err != nil {
 		return err
 	}
-	if count == 0 {
-		return fmt.Errorf("no objects passed to create")
+	client, err := f.ClientForMapping(mapping)
+	if err != nil {
+		return err
+	}
+	fip, err := resource.NewHelper(client, mapping).Get("", fipName, false)
+	if err != nil {
+		return fmt.Errorf("unable to resolve loadBalancerIP %q of service %q: %v", fipName, info.Name, err)
+	}
+	ip, err := mapping.MetadataAccessor.Name(fip)
+	if err != nil {
+		return err
 	}
+	unstructured.SetNestedField(content, ip, "spec", "loadBalancerIP")
+	obj.SetUnstructuredContent(content)
 	return nil
 }
 
@@ -332,9 +493,7 @@ func RunCreateVolumeSubcommand(f cmdutil.Factory, cmd *cobra.Command, out io.Wri
 	if cfg, err := f.ClientConfig(); err != nil {
 		return err
 	} else {
-		hyperConn := hyper.NewHyperConn(cfg)
-		volCli := hyper.NewVolumeCli(hyperConn)
-		if _, volCreated, err := volCli.CreateVolume(opts.Name, opts.Zone, fmt.Sprintf("%v", opts.Size)); err != nil {
+		if volCreated, err := hyperconn.NewConn(cfg).CreateVolume(opts.Name, opts.Zone, opts.Size); err != nil {
 			return err
 		} else {
 			fmt.Printf("volume/%v\n", volCreated.Name)
@@ -353,9 +512,7 @@ func RunCreateFipSubcommand(f cmdutil.Factory, cmd *cobra.Command, out io.Writer
 	if cfg, err := f.ClientConfig(); err != nil {
 		return err
 	} else {
-		hyperConn := hyper.NewHyperConn(cfg)
-		fipCli := hyper.NewFipCli(hyperConn)
-		if _, fipList, err := fipCli.AllocateFip(opts.Count); err != nil {
+		if fipList, err := hyperconn.NewConn(cfg).AllocateFips(opts.Count); err != nil {
 			return err
 		} else {
 			for _, fip := range fipList {
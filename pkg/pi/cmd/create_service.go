@@ -17,10 +17,14 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
 	"io"
+	"sync"
 
+	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 
+	"github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi"
 	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
@@ -53,18 +57,23 @@ var (
     # Create a new ClusterIP service named my-cs
     pi create service clusterip my-cs --tcp=5678:8080 --selector app=nginx
 
+    # Create a new ClusterIP service named my-dns serving both tcp and udp on port 53
+    pi create service clusterip my-dns --tcp=53 --udp=53 --selector app=dns
+
     # Create a new ClusterIP service named my-cs (in headless mode)
     pi create service clusterip my-cs --clusterip="None" --selector app=nginx`))
 )
 
 func addPortFlags(cmd *cobra.Command) {
-	cmd.Flags().StringSlice("tcp", []string{}, "Port pairs can be specified as '<port>:<targetPort>'.")
+	cmd.Flags().StringSlice("tcp", []string{}, "TCP port pairs can be specified as '<port>:<targetPort>'.")
+	cmd.Flags().StringSlice("udp", []string{}, "UDP port pairs can be specified as '<port>:<targetPort>'.")
+	cmd.Flags().StringSlice("port", []string{}, "Ports can be specified as '[name:]<port>[:<targetPort>][/tcp|udp]', the protocol defaults to tcp.")
 }
 
 // NewCmdCreateServiceClusterIP is a command to create a ClusterIP service
 func NewCmdCreateServiceClusterIP(f cmdutil.Factory, cmdOut io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "clusterip NAME [--tcp=<port>:<targetPort>]",
+		Use:     "clusterip NAME [--tcp=<port>:<targetPort>] [--udp=<port>:<targetPort>]",
 		Short:   i18n.T("Create a ClusterIP service."),
 		Long:    serviceClusterIPLong,
 		Example: serviceClusterIPExample,
@@ -99,6 +108,8 @@ func CreateServiceClusterIP(f cmdutil.Factory, cmdOut io.Writer, cmd *cobra.Comm
 		generator = &pi.ServiceCommonGeneratorV1{
 			Name:      name,
 			TCP:       cmdutil.GetFlagStringSlice(cmd, "tcp"),
+			UDP:       cmdutil.GetFlagStringSlice(cmd, "udp"),
+			Ports:     cmdutil.GetFlagStringSlice(cmd, "port"),
 			Type:      v1.ServiceTypeClusterIP,
 			ClusterIP: cmdutil.GetFlagString(cmd, "clusterip"),
 			Selector:  cmdutil.GetFlagStringSlice(cmd, "selector"),
@@ -173,13 +184,22 @@ var (
 
 	serviceLoadBalancerExample = templates.Examples(i18n.T(`
     # Create a new LoadBalancer service named my-lbs (x.x.x.x is fip)
-    pi create service loadbalancer my-lbs --tcp=5678:8080 -f=x.x.x.x -l=role=web,zone=gcp-us-central1-a`))
+    pi create service loadbalancer my-lbs --tcp=5678:8080 -f=x.x.x.x -l=role=web,zone=gcp-us-central1-a
+
+    # Create a new LoadBalancer service named my-game with named tcp and udp ports
+    pi create service loadbalancer my-game --port=http:80:8080 --port=game:27015/udp -f=x.x.x.x -l=app=game
+
+    # Create a new LoadBalancer service named my-web on a newly allocated fip named web
+    pi create service loadbalancer my-web --tcp=80:8080 --loadbalancerip=auto --fip-name=web -l=app=web`))
 )
 
+// autoLoadBalancerIP is the --loadbalancerip value requesting a new fip.
+const autoLoadBalancerIP = "auto"
+
 // NewCmdCreateServiceLoadBalancer is a macro command for creating a LoadBalancer service
 func NewCmdCreateServiceLoadBalancer(f cmdutil.Factory, cmdOut io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "loadbalancer NAME [--tcp=port:targetPort] --loadbalancerip=fip --selector=key=val",
+		Use:     "loadbalancer NAME [--tcp=port:targetPort] [--udp=port:targetPort] --loadbalancerip=fip --selector=key=val",
 		Short:   i18n.T("Create a LoadBalancer service."),
 		Long:    serviceLoadBalancerLong,
 		Example: serviceLoadBalancerExample,
@@ -193,23 +213,30 @@ func NewCmdCreateServiceLoadBalancer(f cmdutil.Factory, cmdOut io.Writer) *cobra
 	//cmdutil.AddPrinterFlags(cmd)
 	//cmdutil.AddGeneratorFlags(cmd, cmdutil.ServiceLoadBalancerGeneratorV1Name)
 	addPortFlags(cmd)
-	cmd.Flags().StringP("loadbalancerip", "f", "", "Set fip as LoadBalancerIP")
+	addLoadBalancerIPFlags(cmd)
 	cmd.Flags().StringSliceP("selector", "l", []string{}, "Labels selectors for pods")
 	return cmd
 }
 
+func addLoadBalancerIPFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("loadbalancerip", "f", "", "Set fip as LoadBalancerIP, or 'auto' to allocate a new fip")
+	cmd.Flags().String("fip-name", "", "Name of the fip allocated by --loadbalancerip=auto")
+}
+
 // CreateServiceLoadBalancer is the implementation of the create service loadbalancer command
 func CreateServiceLoadBalancer(f cmdutil.Factory, cmdOut io.Writer, cmd *cobra.Command, args []string) error {
 	name, err := NameFromCommandArgs(cmd, args)
 	if err != nil {
 		return err
 	}
-	var generator pi.StructuredGenerator
+	var generator *pi.ServiceCommonGeneratorV1
 	switch generatorName := cmdutil.ServiceLoadBalancerGeneratorV1Name; generatorName {
 	case cmdutil.ServiceLoadBalancerGeneratorV1Name:
 		generator = &pi.ServiceCommonGeneratorV1{
 			Name:           name,
 			TCP:            cmdutil.GetFlagStringSlice(cmd, "tcp"),
+			UDP:            cmdutil.GetFlagStringSlice(cmd, "udp"),
+			Ports:          cmdutil.GetFlagStringSlice(cmd, "port"),
 			Type:           v1.ServiceTypeLoadBalancer,
 			ClusterIP:      "",
 			LoadBalancerIP: cmdutil.GetFlagString(cmd, "loadbalancerip"),
@@ -218,10 +245,78 @@ func CreateServiceLoadBalancer(f cmdutil.Factory, cmdOut io.Writer, cmd *cobra.C
 	default:
 		return errUnsupportedGenerator(cmd, generatorName)
 	}
-	return RunCreateSubcommand(f, cmd, cmdOut, &CreateSubcommandOptions{
+	// fail on invalid flags before a fip is allocated
+	if _, err := generator.StructuredGenerate(); err != nil {
+		return err
+	}
+	finish, err := allocateLoadBalancerIP(f, cmd, cmdOut, &generator.LoadBalancerIP)
+	if err != nil {
+		return err
+	}
+	err = RunCreateSubcommand(f, cmd, cmdOut, &CreateSubcommandOptions{
 		Name:                name,
 		StructuredGenerator: generator,
 	})
+	finish(err)
+	return err
+}
+
+// allocateLoadBalancerIP allocates a fip when loadBalancerIP is "auto", names
+// it after --fip-name and stores its address in loadBalancerIP. The returned
+// finish func must be called with the outcome of the creation of the service,
+// the fip is given back when it failed, or when pi is interrupted before. It
+// is a no-op when no fip was allocated.
+func allocateLoadBalancerIP(f cmdutil.Factory, cmd *cobra.Command, out io.Writer, loadBalancerIP *string) (func(error), error) {
+	fipName := cmdutil.GetFlagString(cmd, "fip-name")
+	if *loadBalancerIP != autoLoadBalancerIP {
+		if len(fipName) > 0 {
+			return nil, cmdutil.UsageErrorf(cmd, "--fip-name can only be used with --loadbalancerip=%s", autoLoadBalancerIP)
+		}
+		return func(error) {}, nil
+	}
+
+	cfg, err := f.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	conn := hyper.NewConn(cfg)
+	fips, err := conn.AllocateFips(1)
+	if err != nil {
+		return nil, fmt.Errorf("unable to allocate a fip: %v", err)
+	}
+	if len(fips) != 1 {
+		return nil, fmt.Errorf("unable to allocate a fip: expected 1 fip, got %d", len(fips))
+	}
+	ip := fips[0].Fip
+	// the release is sent when the service can't be created, which is the
+	// case once interrupted, or by the cleanups of a command that does not
+	// return once interrupted
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			if err := conn.Uninterruptible().ReleaseFip(ip); err != nil {
+				glog.Warningf("failed to release fip %s, release it with `pi delete fip %s`: %v", ip, ip, err)
+				return
+			}
+			fmt.Fprintf(out, "fip/%v released\n", ip)
+		})
+	}
+	removeCleanup := hyper.OnInterrupt(release)
+	finish := func(err error) {
+		removeCleanup()
+		if err != nil {
+			release()
+		}
+	}
+	if len(fipName) > 0 {
+		if err := conn.NameFip(ip, fipName); err != nil {
+			finish(err)
+			return nil, fmt.Errorf("unable to name fip %s %q: %v", ip, fipName, err)
+		}
+	}
+	fmt.Fprintf(out, "fip/%v\n", ip)
+	*loadBalancerIP = ip
+	return finish, nil
 }
 
 var (
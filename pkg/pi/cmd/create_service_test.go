@@ -0,0 +1,181 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	restclient "github.com/hyperhq/client-go/rest"
+	"github.com/hyperhq/client-go/rest/fake"
+	cmdtesting "github.com/hyperhq/pi/pkg/pi/cmd/testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+// fakeFipServer is a Hyper API stand-in allocating the fip 10.0.0.9, it
+// records the requests it gets.
+type fakeFipServer struct {
+	*httptest.Server
+	// nameStatus is the status of the requests naming the fip
+	nameStatus int
+
+	lock     sync.Mutex
+	requests []string
+}
+
+func newFakeFipServer() *fakeFipServer {
+	s := &fakeFipServer{nameStatus: http.StatusNoContent}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		s.lock.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/hyper/fips":
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `[{"fip":"10.0.0.9"}]`)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/hyper/fips/10.0.0.9":
+			w.WriteHeader(s.nameStatus)
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/v1/hyper/fips/10.0.0.9":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+// Requests returns the requests sent to the Hyper API.
+func (s *fakeFipServer) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+// ClientConfig returns the config of a cluster at s.
+func (s *fakeFipServer) ClientConfig() *restclient.Config {
+	config := defaultClientConfig()
+	config.Host = s.URL
+	config.Region = "gcp-us-central1"
+	config.AccessKey = "ak"
+	config.SecretKey = "sk"
+	config.CAData = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	return config
+}
+
+func TestCreateServiceLoadBalancerAllocatesFip(t *testing.T) {
+	tests := []struct {
+		name          string
+		nameStatus    int
+		serviceStatus int
+		expectErr     string
+		expected      []string
+	}{
+		{
+			name:          "created",
+			nameStatus:    http.StatusNoContent,
+			serviceStatus: http.StatusCreated,
+			expected:      []string{"POST /api/v1/hyper/fips?count=1", "POST /api/v1/hyper/fips/10.0.0.9"},
+		},
+		{
+			name:          "service failed",
+			nameStatus:    http.StatusNoContent,
+			serviceStatus: http.StatusInternalServerError,
+			expectErr:     "(post services)",
+			expected:      []string{"POST /api/v1/hyper/fips?count=1", "POST /api/v1/hyper/fips/10.0.0.9", "DELETE /api/v1/hyper/fips/10.0.0.9"},
+		},
+		{
+			name:       "name taken",
+			nameStatus: http.StatusConflict,
+			expectErr:  `unable to name fip 10.0.0.9 "web"`,
+			expected:   []string{"POST /api/v1/hyper/fips?count=1", "POST /api/v1/hyper/fips/10.0.0.9", "DELETE /api/v1/hyper/fips/10.0.0.9"},
+		},
+	}
+	for _, test := range tests {
+		server := newFakeFipServer()
+		server.nameStatus = test.nameStatus
+
+		f, tf, codec, ns := cmdtesting.NewAPIFactory()
+		tf.ClientConfig = server.ClientConfig()
+		var service *api.Service
+		tf.Client = &fake.RESTClient{
+			GroupVersion:         schema.GroupVersion{Version: "v1"},
+			NegotiatedSerializer: ns,
+			Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				if p, m := req.URL.Path, req.Method; p != "/namespaces/test/services" || m != http.MethodPost {
+					t.Fatalf("%s: unexpected request: %#v\n%#v", test.name, req.URL, req)
+				}
+				data, _ := ioutil.ReadAll(req.Body)
+				service = &api.Service{}
+				if err := runtime.DecodeInto(codec, data, service); err != nil {
+					t.Fatalf("%s: %v", test.name, err)
+				}
+				if test.serviceStatus != http.StatusCreated {
+					return &http.Response{StatusCode: test.serviceStatus, Header: defaultHeader(), Body: ioutil.NopCloser(bytes.NewBufferString("{}"))}, nil
+				}
+				return &http.Response{StatusCode: test.serviceStatus, Header: defaultHeader(), Body: objBody(codec, service)}, nil
+			}),
+		}
+		tf.Namespace = "test"
+		buf := bytes.NewBuffer([]byte{})
+
+		cmd := NewCmdCreateServiceLoadBalancer(f, buf)
+		cmd.Flags().Set("tcp", "80:8080")
+		cmd.Flags().Set("selector", "app=web")
+		cmd.Flags().Set("loadbalancerip", "auto")
+		cmd.Flags().Set("fip-name", "web")
+		err := CreateServiceLoadBalancer(f, buf, cmd, []string{"web"})
+		server.Close()
+
+		switch {
+		case len(test.expectErr) == 0 && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		case len(test.expectErr) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectErr)):
+			t.Errorf("%s: expected %q in the error, got %v", test.name, test.expectErr, err)
+		}
+		if requests := server.Requests(); !reflect.DeepEqual(requests, test.expected) {
+			t.Errorf("%s: expected the requests %v, got %v", test.name, test.expected, requests)
+		}
+		if test.serviceStatus != 0 && (service == nil || service.Spec.LoadBalancerIP != "10.0.0.9") {
+			t.Errorf("%s: expected the service on the fip 10.0.0.9, got %+v", test.name, service)
+		}
+		released := strings.Contains(buf.String(), "fip/10.0.0.9 released")
+		if released != (len(test.expectErr) > 0) {
+			t.Errorf("%s: unexpected output %q", test.name, buf.String())
+		}
+	}
+}
+
+func TestAllocateLoadBalancerIPRequiresAuto(t *testing.T) {
+	f, _, _, _ := cmdtesting.NewAPIFactory()
+	cmd := NewCmdCreateServiceLoadBalancer(f, ioutil.Discard)
+	cmd.Flags().Set("fip-name", "web")
+	ip := "10.0.0.1"
+	if _, err := allocateLoadBalancerIP(f, cmd, ioutil.Discard, &ip); err == nil || !strings.Contains(err.Error(), "--fip-name can only be used with --loadbalancerip=auto") {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
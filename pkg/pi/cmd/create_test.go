@@ -18,13 +18,20 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hyperhq/client-go/dynamic"
 	"github.com/hyperhq/client-go/rest/fake"
 	cmdtesting "github.com/hyperhq/pi/pkg/pi/cmd/testing"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -105,12 +112,12 @@ func TestCreateMultipleObject(t *testing.T) {
 
 	cmd := NewCmdCreate(f, buf, errBuf)
 	cmd.Flags().Set("filename", "../../../examples/pod/pod-nginx.yaml")
-	cmd.Flags().Set("filename", "../../../examples/service/service-clusterip-default.yaml")
+	cmd.Flags().Set("filename", "../../../examples/wordpress/mysql-service.yaml")
 	cmd.Flags().Set("output", "name")
 	cmd.Run(cmd, []string{})
 
 	// Names should come from the REST response, NOT the files
-	if buf.String() != "replicationcontroller/rc1\nservice/baz\n" {
+	if buf.String() != "pod/nginx\nservice/http\n" {
 		t.Errorf("unexpected output: %s", buf.String())
 	}
 }
@@ -139,8 +146,23 @@ func TestCreateDirectory(t *testing.T) {
 	buf := bytes.NewBuffer([]byte{})
 	errBuf := bytes.NewBuffer([]byte{})
 
+	data, err := ioutil.ReadFile("../../../examples/pod/pod-nginx.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "create-directory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.yaml", "b.yaml", "c.yaml"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
 	cmd := NewCmdCreate(f, buf, errBuf)
-	cmd.Flags().Set("filename", "../../../examples/pod/pod-nginx.yaml")
+	cmd.Flags().Set("filename", dir)
 	cmd.Flags().Set("output", "name")
 	cmd.Run(cmd, []string{})
 
@@ -148,3 +170,99 @@ func TestCreateDirectory(t *testing.T) {
 		t.Errorf("unexpected output: %s", buf.String())
 	}
 }
+
+func TestCreateExpandsTemplateOnlyWhenAsked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "create-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "pod.yaml")
+	manifest := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: nginx\nspec:\n  containers:\n  - name: nginx\n    image: nginx:${TAG}\n"
+	if err := ioutil.WriteFile(file, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TAG", "from-env")
+	defer os.Unsetenv("TAG")
+
+	tests := []struct {
+		flags    map[string]string
+		expected string
+	}{
+		{map[string]string{}, "nginx:${TAG}"},
+		{map[string]string{"set": "TAG=1.13"}, "nginx:1.13"},
+		{map[string]string{"values-from-env": "true"}, "nginx:from-env"},
+		{map[string]string{"set": "TAG=1.13", "values-from-env": "true"}, "nginx:1.13"},
+	}
+	for _, test := range tests {
+		initTestErrorHandler(t)
+		pods, _ := testData()
+		f, tf, codec, _ := cmdtesting.NewAPIFactory()
+		tf.Printer = &testPrinter{}
+		image := ""
+		tf.UnstructuredClient = &fake.RESTClient{
+			GroupVersion:         schema.GroupVersion{Version: "v1"},
+			NegotiatedSerializer: unstructuredSerializer,
+			Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				body, _ := ioutil.ReadAll(req.Body)
+				pod := map[string]interface{}{}
+				if err := json.Unmarshal(body, &pod); err != nil {
+					t.Fatalf("unexpected body %q: %v", body, err)
+				}
+				containers, _ := unstructured.NestedSlice(pod, "spec", "containers")
+				if len(containers) > 0 {
+					image, _ = containers[0].(map[string]interface{})["image"].(string)
+				}
+				return &http.Response{StatusCode: http.StatusCreated, Header: defaultHeader(), Body: objBody(codec, &pods.Items[0])}, nil
+			}),
+		}
+		tf.Namespace = "default"
+
+		cmd := NewCmdCreate(f, bytes.NewBuffer([]byte{}), bytes.NewBuffer([]byte{}))
+		cmd.Flags().Set("filename", file)
+		for flag, value := range test.flags {
+			cmd.Flags().Set(flag, value)
+		}
+		cmd.Run(cmd, []string{})
+		if image != test.expected {
+			t.Errorf("%v: expected the image %q, got %q", test.flags, test.expected, image)
+		}
+	}
+}
+
+func TestCreateSkipsObjectsOfFailedDependencies(t *testing.T) {
+	var fatal string
+	cmdutil.BehaviorOnFatal(func(str string, code int) {
+		fatal = str
+	})
+	defer initTestErrorHandler(t)
+
+	f, tf, _, _ := cmdtesting.NewAPIFactory()
+	tf.Printer = &testPrinter{}
+	created := []string{}
+	tf.UnstructuredClient = &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodPost {
+				t.Fatalf("unexpected request: %#v\n%#v", req.URL, req)
+			}
+			created = append(created, req.URL.Path)
+			return &http.Response{StatusCode: http.StatusInternalServerError, Header: defaultHeader(), Body: ioutil.NopCloser(bytes.NewBufferString(`{"message":"quota exceeded"}`))}, nil
+		}),
+	}
+	tf.Namespace = "default"
+
+	cmd := NewCmdCreate(f, bytes.NewBuffer([]byte{}), bytes.NewBuffer([]byte{}))
+	cmd.Flags().Set("filename", "../../../examples/all/nginx-all-in-one.yaml")
+	cmd.Run(cmd, []string{})
+
+	if len(created) != 2 {
+		t.Errorf("expected only the volume and the fip to be created, got %v", created)
+	}
+	for _, expected := range []string{`skipped pods "nginx", volume "nginx-data"`, `skipped services "nginx", fip "nginx-ip"`} {
+		if !strings.Contains(fatal, expected) {
+			t.Errorf("expected %q in the error, got %q", expected, fatal)
+		}
+	}
+}
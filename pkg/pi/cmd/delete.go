@@ -74,6 +74,9 @@ var (
 		# Force delete a pod on a dead node
 		pi delete pod foo --grace-period=0 --force
 
+		# Delete a volume and a fip
+		pi delete volume vol1 fip/35.164.13.17
+
 		# Delete all pods
 		pi delete pods --all`))
 )
@@ -150,10 +153,6 @@ func NewCmdDelete(f cmdutil.Factory, out, errOut io.Writer) *cobra.Command {
 	cmd.Flags().DurationVar(&options.Timeout, "timeout", 0, "The length of time to wait before giving up on a delete, zero means determine a timeout from the size of the object")
 	cmdutil.AddOutputVarFlagsForMutation(cmd, &options.Output)
 	//cmdutil.AddIncludeUninitializedFlag(cmd)
-
-	// delete volume, fip
-	cmd.AddCommand(NewCmdDeleteVolume(f, out, errOut))
-	cmd.AddCommand(NewCmdDeleteFip(f, out, errOut))
 	return cmd
 }
 
@@ -0,0 +1,490 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	restclient "github.com/hyperhq/client-go/rest"
+	clientcmdapi "github.com/hyperhq/client-go/tools/clientcmd/api"
+	"github.com/hyperhq/pi"
+	"github.com/hyperhq/pi/pkg/hyper"
+	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/pi/util/i18n"
+	"github.com/hyperhq/pi/pkg/printers"
+
+	"github.com/spf13/cobra"
+)
+
+// The status of a doctor check.
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+const (
+	// doctorClockSkewLimit is the clock skew past which the signatures are
+	// rejected, smaller skews only get a warning.
+	doctorClockSkewLimit = 5 * time.Minute
+	// doctorDialTimeout bounds the connection to the Hyper API server and the
+	// TLS handshake.
+	doctorDialTimeout = 10 * time.Second
+)
+
+// DoctorCheck is the result of a check of pi doctor.
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// DoctorReport is the result of pi doctor, printed with -o json.
+type DoctorReport struct {
+	Passed bool          `json:"passed"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+// DoctorOptions contains all the options for running the doctor cli command.
+type DoctorOptions struct {
+	Output string
+
+	Context   string
+	RawConfig clientcmdapi.Config
+	Config    *restclient.Config
+	ConfigErr error
+
+	Out io.Writer
+
+	// status holds the status of the checks run so far, by name
+	status map[string]string
+	// addr is the host:port of the Hyper API server of the region, proxyURL
+	// the proxy to reach it through if any
+	addr     string
+	proxyURL *url.URL
+}
+
+var (
+	doctorLong = templates.LongDesc(i18n.T(`
+		Check the setup of pi.
+
+		The checks go from the pi config to the Hyper API server of the region:
+		the active context, the region host, the TCP connection and the TLS
+		handshake with the server, the clock, the credentials and the version
+		of pi against the latest one found by pi info.
+
+		Each check prints whether it passed, with a hint to fix it when it did not.
+		The checks which depend on a failed one are skipped. The command exits
+		with an error when a check fails.`))
+
+	doctorExample = templates.Examples(i18n.T(`
+		# Check the setup of pi
+		pi doctor
+
+		# Print the checks in JSON
+		pi doctor -o json`))
+)
+
+func NewCmdDoctor(f cmdutil.Factory, out io.Writer) *cobra.Command {
+	options := &DoctorOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "doctor",
+		Short:   i18n.T("Check the setup of pi"),
+		Long:    doctorLong,
+		Example: doctorExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(options.Complete(f, cmd, args, out))
+			cmdutil.CheckErr(options.Validate())
+			cmdutil.CheckErr(options.RunDoctor())
+		},
+	}
+	cmd.Flags().StringVarP(&options.Output, "output", "o", "", "Output format. One of: json.")
+	return cmd
+}
+
+func (o *DoctorOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return cmdutil.UsageErrorf(cmd, "%s takes no arguments", cmd.Use)
+	}
+	// a broken config is reported by the checks
+	o.Config, o.ConfigErr = f.ClientConfig()
+	o.RawConfig, _ = f.RawConfig()
+	o.Context = o.RawConfig.CurrentContext
+	o.Out = out
+	return nil
+}
+
+func (o *DoctorOptions) Validate() error {
+	if len(o.Output) > 0 && o.Output != "json" {
+		return fmt.Errorf("unsupported output format %q, only json is supported", o.Output)
+	}
+	return nil
+}
+
+func (o *DoctorOptions) RunDoctor() error {
+	o.status = map[string]string{}
+	report := DoctorReport{Passed: true}
+	for _, run := range []func() DoctorCheck{
+		o.checkConfig,
+		o.checkRegion,
+		o.checkTCP,
+		o.checkTLS,
+		o.checkClock,
+		o.checkCredentials,
+		o.checkVersion,
+	} {
+		check := run()
+		o.status[check.Name] = check.Status
+		report.Checks = append(report.Checks, check)
+		if check.Status == CheckFail {
+			report.Passed = false
+		}
+	}
+
+	var err error
+	if o.Output == "json" {
+		err = o.printJSON(report)
+	} else {
+		err = o.printChecks(report.Checks)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Passed {
+		return cmdutil.ErrExit
+	}
+	return nil
+}
+
+// requires returns a skipped check called name when one of the checks it
+// depends on did not pass.
+func (o *DoctorOptions) requires(name string, checks ...string) (DoctorCheck, bool) {
+	for _, required := range checks {
+		if status := o.status[required]; status != CheckPass && status != CheckWarn {
+			return DoctorCheck{
+				Name:    name,
+				Status:  CheckSkip,
+				Message: fmt.Sprintf("the %s check did not pass", required),
+			}, false
+		}
+	}
+	return DoctorCheck{Name: name}, true
+}
+
+// checkConfig validates the active context of the pi config, along with its
+// cluster and credentials.
+func (o *DoctorOptions) checkConfig() DoctorCheck {
+	check := DoctorCheck{Name: "config"}
+	if o.ConfigErr != nil {
+		check.Status = CheckFail
+		check.Message = o.ConfigErr.Error()
+		check.Hint = "list the contexts with `pi config get-contexts`, and set the credentials of the region with `pi config set-credentials NAME --region=REGION --access-key=KEY --secret-key=SECRET`"
+		return check
+	}
+	check.Status = CheckPass
+	if context, found := o.RawConfig.Contexts[o.Context]; found {
+		check.Message = fmt.Sprintf("context %q with the cluster %q and the user %q", o.Context, context.Cluster, context.AuthInfo)
+	} else {
+		check.Message = fmt.Sprintf("no context %q, the default server is used", o.Context)
+	}
+	if len(o.Config.AccessKey) == 0 || len(o.Config.SecretKey) == 0 {
+		check.Status = CheckFail
+		check.Message += ", without access key or secret key"
+		check.Hint = "set the credentials with `pi config set-credentials NAME --access-key=KEY --secret-key=SECRET`"
+	}
+	return check
+}
+
+// checkRegion resolves the Hyper API server of the region, the default
+// domain has the region in place of its *.
+func (o *DoctorOptions) checkRegion() DoctorCheck {
+	check, ok := o.requires("region", "config")
+	if !ok {
+		return check
+	}
+	host := hyper.RegionHost(o.Config.Host, o.Config)
+	server, err := url.Parse(host)
+	if err == nil && len(server.Host) == 0 {
+		err = fmt.Errorf("no host in %q", host)
+	}
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("invalid server: %v", err)
+		check.Hint = "set the server of the cluster with `pi config set-cluster NAME --server=https://*.hyper.sh:443`"
+		return check
+	}
+	if strings.Contains(server.Host, "*") {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("no region to replace the * of %s", server.Host)
+		check.Hint = "set the region of the credentials with `pi config set-credentials NAME --region=REGION`, gcp-us-central1 for example"
+		return check
+	}
+	o.addr = server.Host
+	if len(server.Port()) == 0 {
+		o.addr = net.JoinHostPort(server.Hostname(), "443")
+	}
+
+	if o.proxyURL, err = hyper.ProxyFor("https", o.addr); err != nil {
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("invalid proxy: %v", err)
+		check.Hint = "check the proxy-url of the cluster and the HTTPS_PROXY environment variable"
+		return check
+	}
+	if o.proxyURL != nil {
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("%s is resolved by the proxy %s", server.Hostname(), o.proxyURL.Host)
+		return check
+	}
+	ctx, cancel := hyper.InterruptibleContext()
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, server.Hostname())
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		check.Hint = "check the region of the credentials with `pi config view`, and the DNS of this machine"
+		return check
+	}
+	check.Status = CheckPass
+	check.Message = fmt.Sprintf("%s resolves to %s", server.Hostname(), strings.Join(addrs, ", "))
+	return check
+}
+
+// dial connects to the Hyper API server, through the proxy if any.
+func (o *DoctorOptions) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: doctorDialTimeout}
+	if o.proxyURL != nil {
+		return hyper.DialProxy(dialer, o.proxyURL, o.addr)
+	}
+	return dialer.Dial("tcp", o.addr)
+}
+
+func (o *DoctorOptions) checkTCP() DoctorCheck {
+	check, ok := o.requires("tcp", "region")
+	if !ok {
+		return check
+	}
+	conn, err := o.dial()
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		check.Hint = "check the network, the firewall and the proxy of this machine"
+		return check
+	}
+	defer conn.Close()
+	check.Status = CheckPass
+	if o.proxyURL != nil {
+		check.Message = fmt.Sprintf("connected to %s through the proxy %s", o.addr, o.proxyURL.Host)
+	} else {
+		check.Message = fmt.Sprintf("connected to %s", conn.RemoteAddr())
+	}
+	return check
+}
+
+func (o *DoctorOptions) checkTLS() DoctorCheck {
+	check, ok := o.requires("tls", "tcp")
+	if !ok {
+		return check
+	}
+	tlsConfig, err := hyper.TLSConfig(o.Config)
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		check.Hint = "check the certificate-authority of the cluster with `pi config view`"
+		return check
+	}
+	tlsConfig = tlsConfig.Clone()
+	if len(tlsConfig.ServerName) == 0 {
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(o.addr)
+	}
+	conn, err := o.dial()
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		return check
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(doctorDialTimeout))
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		check.Hint = "set the certificate authority of the Hyper API server with `pi config set-cluster NAME --certificate-authority=FILE`, a proxy may also be intercepting the TLS connections"
+		return check
+	}
+	state := tlsConn.ConnectionState()
+	check.Status = CheckPass
+	check.Message = tlsVersionName(state.Version)
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		check.Message += fmt.Sprintf(", certificate of %s valid until %s", cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+	}
+	if tlsConfig.InsecureSkipVerify {
+		check.Status = CheckWarn
+		check.Message += ", not verified"
+		check.Hint = "the cluster has insecure-skip-tls-verify set, anyone on the way to the server can read the requests"
+	}
+	return check
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("TLS %#04x", version)
+}
+
+// checkClock compares the local clock with the one of the Hyper API server,
+// the signatures of the requests are made at the local time.
+func (o *DoctorOptions) checkClock() DoctorCheck {
+	check, ok := o.requires("clock", "tls")
+	if !ok {
+		return check
+	}
+	cli, err := hyper.NewHyperCli(o.Config.Host, o.Config, nil, nil, nil)
+	if err != nil {
+		check.Status = CheckSkip
+		check.Message = err.Error()
+		return check
+	}
+	ctx, cancel := hyper.InterruptibleContext()
+	defer cancel()
+	skew, err := cli.ServerClockSkew(ctx)
+	if err != nil {
+		check.Status = CheckSkip
+		check.Message = fmt.Sprintf("can't read the time of the Hyper API server: %v", err)
+		return check
+	}
+	check.Message = hyper.DescribeClockSkew(skew)
+	if skew < 0 {
+		skew = -skew
+	}
+	switch {
+	case skew < hyper.MaxClockSkew:
+		check.Status = CheckPass
+	case skew < doctorClockSkewLimit:
+		check.Status = CheckWarn
+		check.Hint = hyper.ClockSkewHint
+	default:
+		check.Status = CheckFail
+		check.Hint = hyper.ClockSkewHint
+	}
+	return check
+}
+
+// checkCredentials reads the info of the account, signed with the credentials
+// of the context.
+func (o *DoctorOptions) checkCredentials() DoctorCheck {
+	check, ok := o.requires("credentials", "tls")
+	if !ok {
+		return check
+	}
+	status, info, err := hyper.NewConn(o.Config).GetInfo()
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		check.Status = CheckFail
+		check.Message = fmt.Sprintf("the Hyper API server rejected the access key %s in %s", maskAccessKey(o.Config.AccessKey), o.Config.Region)
+		check.Hint = "check the access key, the secret key and the region of the credentials with `pi config view`, the keys of a region don't work in the others"
+		if o.status["clock"] == CheckFail || o.status["clock"] == CheckWarn {
+			check.Hint = "set the clock right first, see the clock check"
+		}
+	case err != nil:
+		check.Status = CheckFail
+		check.Message = err.Error()
+	default:
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("the access key %s is valid in %s", maskAccessKey(o.Config.AccessKey), o.Config.Region)
+		if email := info["Email"]; len(email) > 0 {
+			check.Message += ", for " + email
+		}
+	}
+	return check
+}
+
+// maskAccessKey keeps the first characters of key, enough to tell the keys
+// apart.
+func maskAccessKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return key[:4] + "****"
+}
+
+// checkVersion compares the version of pi with the latest version found by
+// the last check of the releases, pi doctor does not reach GitHub itself.
+func (o *DoctorOptions) checkVersion() DoctorCheck {
+	check := DoctorCheck{Name: "version"}
+	if len(pi.Version) == 0 {
+		check.Status = CheckSkip
+		check.Message = "pi is a development build without version"
+		return check
+	}
+	latest := pi.NewCheckUpdate().ReadLatest()
+	switch {
+	case len(latest) == 0:
+		check.Status = CheckSkip
+		check.Message = fmt.Sprintf("pi %s, the latest version is not known yet", pi.Version)
+		check.Hint = "check the latest version with `pi info --check-update`"
+	case latest == pi.Version:
+		check.Status = CheckPass
+		check.Message = fmt.Sprintf("pi %s is the latest version", pi.Version)
+	default:
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("pi %s is outdated, the latest version is %s", pi.Version, latest)
+		check.Hint = "download the latest version from https://github.com/hyperhq/pi/releases"
+	}
+	return check
+}
+
+func (o *DoctorOptions) printChecks(checks []DoctorCheck) error {
+	w := printers.GetNewTabWriter(o.Out)
+	fmt.Fprintln(w, "STATUS\tCHECK\tMESSAGE")
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Status, check.Name, check.Message)
+		if len(check.Hint) > 0 {
+			fmt.Fprintf(w, "\t\thint: %s\n", check.Hint)
+		}
+	}
+	return w.Flush()
+}
+
+func (o *DoctorOptions) printJSON(report DoctorReport) error {
+	data, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(o.Out, string(data))
+	return err
+}
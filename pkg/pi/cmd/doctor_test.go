@@ -0,0 +1,87 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restclient "github.com/hyperhq/client-go/rest"
+	clientcmdapi "github.com/hyperhq/client-go/tools/clientcmd/api"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+)
+
+func TestDoctorReportsRejectedCredentials(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/info") {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"invalid access key"}`)
+		}
+	}))
+	defer server.Close()
+
+	buf := bytes.NewBuffer([]byte{})
+	options := &DoctorOptions{
+		Output:  "json",
+		Context: "default",
+		RawConfig: clientcmdapi.Config{
+			Contexts: map[string]*clientcmdapi.Context{
+				"default": {Cluster: "default", AuthInfo: "user1"},
+			},
+		},
+		Config: &restclient.Config{
+			Host:            server.URL,
+			TLSClientConfig: restclient.TLSClientConfig{Insecure: true},
+			Region:          "gcp-us-central1",
+			AccessKey:       "ak-1234",
+			SecretKey:       "sk",
+		},
+		Out: buf,
+	}
+	if err := options.RunDoctor(); err != cmdutil.ErrExit {
+		t.Errorf("expected the doctor to fail, got %v", err)
+	}
+
+	report := DoctorReport{}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("unexpected output %q: %v", buf.String(), err)
+	}
+	expected := map[string]string{
+		"config":      CheckPass,
+		"region":      CheckPass,
+		"tcp":         CheckPass,
+		"tls":         CheckWarn,
+		"clock":       CheckPass,
+		"credentials": CheckFail,
+	}
+	for _, check := range report.Checks {
+		if status, found := expected[check.Name]; found && status != check.Status {
+			t.Errorf("expected the %s check to %s, got %+v", check.Name, status, check)
+		}
+		if strings.Contains(check.Message, "ak-1234") {
+			t.Errorf("the %s check leaks the access key: %q", check.Name, check.Message)
+		}
+	}
+	if report.Passed {
+		t.Errorf("expected the report to fail")
+	}
+}
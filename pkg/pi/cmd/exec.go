@@ -36,7 +36,6 @@ import (
 	dockerterm "github.com/docker/docker/pkg/term"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
-	"golang.org/x/net/context"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/kubernetes/pkg/api/legacyscheme"
 	api "k8s.io/kubernetes/pkg/apis/core"
@@ -61,11 +60,19 @@ var (
 		# Also note, do not surround your command and its flags/arguments with quotes
 		# unless that is how you would execute it normally (i.e., do ls -t /usr, not "ls -t /usr").
 		pi exec 123456-7890 -i -t -- ls -t /usr
+
+		# Reload the configuration of nginx in every pod labeled app=web, 5 pods at a time
+		pi exec -l app=web --parallel=5 -- nginx -s reload
+
+		# Run 'date' in the first container of all the pods of the namespace
+		pi exec --all-pods -- date
 		`))
 )
 
 const (
 	execUsageStr = "expected 'exec POD_NAME COMMAND [ARG1] [ARG2] ... [ARGN]'.\nPOD_NAME and COMMAND are required arguments for the exec command"
+
+	execPodsUsageStr = "expected 'exec (-l SELECTOR | --all-pods) -- COMMAND [ARG1] [ARG2] ... [ARGN]'.\nPOD_NAME cannot be given with -l or --all-pods, COMMAND is required"
 )
 
 func NewCmdExec(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer) *cobra.Command {
@@ -79,7 +86,7 @@ func NewCmdExec(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer) *c
 		Executor: &DefaultRemoteExecutor{},
 	}
 	cmd := &cobra.Command{
-		Use:     "exec POD [-c CONTAINER] -- COMMAND [args...]",
+		Use:     "exec (POD | -l SELECTOR | --all-pods) [-c CONTAINER] -- COMMAND [args...]",
 		Short:   i18n.T("Execute a command in a container"),
 		Long:    "Execute a command in a container.",
 		Example: exec_example,
@@ -96,6 +103,9 @@ func NewCmdExec(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer) *c
 	cmd.Flags().StringVarP(&options.ContainerName, "container", "c", "", "Container name. If omitted, the first container in the pod will be chosen")
 	cmd.Flags().BoolVarP(&options.Stdin, "stdin", "i", false, "Pass stdin to the container")
 	cmd.Flags().BoolVarP(&options.TTY, "tty", "t", false, "Stdin is a TTY")
+	cmd.Flags().StringVarP(&options.Selector, "selector", "l", "", "Selector (label query) of the pods to run the command in, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
+	cmd.Flags().BoolVar(&options.AllPods, "all-pods", false, "Run the command in all the pods of the namespace.")
+	cmd.Flags().IntVar(&options.Parallel, "parallel", 10, "The number of pods the command runs in at the same time with -l or --all-pods.")
 	return cmd
 }
 
@@ -146,6 +156,12 @@ type ExecOptions struct {
 
 	Command []string
 
+	// Selector or AllPods run Command in several pods, Parallel of them
+	// at a time.
+	Selector string
+	AllPods  bool
+	Parallel int
+
 	FullCmdName       string
 	SuggestedCmdUsage string
 
@@ -156,11 +172,16 @@ type ExecOptions struct {
 
 // Complete verifies command line arguments and loads data from the command environment
 func (p *ExecOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, argsIn []string, argsLenAtDash int) error {
-	// Let pi exec follow rules for `--`, see #13004 issue
-	if len(p.PodName) == 0 && (len(argsIn) == 0 || argsLenAtDash == 0) {
+	if p.multiPod() {
+		// every argument is part of the command, there is no pod name
+		if len(p.PodName) != 0 || len(argsIn) == 0 || argsLenAtDash > 0 {
+			return cmdutil.UsageErrorf(cmd, execPodsUsageStr)
+		}
+		p.Command = argsIn
+	} else if len(p.PodName) == 0 && (len(argsIn) == 0 || argsLenAtDash == 0) {
+		// Let pi exec follow rules for `--`, see #13004 issue
 		return cmdutil.UsageErrorf(cmd, execUsageStr)
-	}
-	if len(p.PodName) != 0 {
+	} else if len(p.PodName) != 0 {
 		printDeprecationWarning("exec POD_NAME", "-p POD_NAME")
 		if len(argsIn) < 1 {
 			return cmdutil.UsageErrorf(cmd, execUsageStr)
@@ -205,7 +226,17 @@ func (p *ExecOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, argsIn []s
 
 // Validate checks that the provided exec options are specified.
 func (p *ExecOptions) Validate() error {
-	if len(p.PodName) == 0 {
+	if p.multiPod() {
+		if len(p.Selector) > 0 && p.AllPods {
+			return fmt.Errorf("only one of --selector or --all-pods can be provided")
+		}
+		if p.Stdin || p.TTY {
+			return fmt.Errorf("--stdin and --tty cannot be used with --selector or --all-pods")
+		}
+		if p.Parallel < 1 {
+			return fmt.Errorf("--parallel must be at least 1")
+		}
+	} else if len(p.PodName) == 0 {
 		return fmt.Errorf("pod name must be specified")
 	}
 	if len(p.Command) == 0 {
@@ -341,6 +372,9 @@ func (p *ExecOptions) Run() error {
 }
 
 func (p *ExecOptions) RunHyper(f util.Factory) error {
+	if p.multiPod() {
+		return p.runHyperPods(f)
+	}
 	pod, err := p.PodClient.Pods(p.Namespace).Get(p.PodName, metav1.GetOptions{})
 	if err != nil {
 		return err
@@ -385,7 +419,8 @@ func (p *ExecOptions) RunHyper(f util.Factory) error {
 			AttachStderr: true,
 		}
 
-		ctx := context.Background()
+		ctx, cancel := hyper.InterruptibleContext()
+		defer cancel()
 		response, err := cli.Client.PodExecCreate(ctx, pod.Name, containerName, *execConfig)
 		if err != nil {
 			return err
@@ -441,7 +476,7 @@ func (p *ExecOptions) RunHyper(f util.Factory) error {
 		}
 
 		glog.V(7).Infof("PodExecAttach: execID:%v\n", execID)
-		resp, err := cli.Client.PodExecAttach(ctx, execID, *execConfig)
+		resp, err := cli.PodExecAttach(ctx, execID, *execConfig)
 		if err != nil {
 			return err
 		}
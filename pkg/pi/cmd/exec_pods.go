@@ -0,0 +1,193 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/hyperhq/pi/pkg/hyper"
+	"github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/printers"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+// podExecResult is the outcome of the command in one pod, err is set when it
+// could not be run.
+type podExecResult struct {
+	pod       string
+	container string
+	status    int
+	err       error
+}
+
+func (r podExecResult) failed() bool {
+	return r.err != nil || r.status != 0
+}
+
+func (p *ExecOptions) multiPod() bool {
+	return len(p.Selector) > 0 || p.AllPods
+}
+
+// podExecFunc runs cmd in container of pod and returns its exit code, like
+// HyperCli.ExecStream.
+type podExecFunc func(ctx context.Context, pod, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+
+// runHyperPods runs the command in every pod matching the selector, see
+// execPods.
+func (p *ExecOptions) runHyperPods(f util.Factory) error {
+	list, err := p.PodClient.Pods(p.Namespace).List(metav1.ListOptions{LabelSelector: p.Selector})
+	if err != nil {
+		return err
+	}
+	pods := []api.Pod{}
+	for _, pod := range list.Items {
+		if pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed {
+			fmt.Fprintf(p.Err, "Skipping pod %s, it is completed; current phase is %s\n", pod.Name, pod.Status.Phase)
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("no pods to run the command in")
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	cfg, err := f.ClientConfig()
+	if err != nil {
+		return err
+	}
+	cli, err := hyper.NewHyperCli(cfg.Host, cfg, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return p.execPods(pods, cli.ExecStream)
+}
+
+// execPods runs the command in pods with exec, at most Parallel of them at a
+// time. The output lines of each pod are prefixed by its name, and a summary
+// of the exit codes is printed on the error output once all are done, so that
+// the output of the pods can be piped on its own.
+func (p *ExecOptions) execPods(pods []api.Pod, exec podExecFunc) error {
+	lock := &sync.Mutex{}
+	results := make([]podExecResult, len(pods))
+	slots := make(chan struct{}, p.Parallel)
+	wg := sync.WaitGroup{}
+	for i := range pods {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = p.execInPod(exec, &pods[i], lock)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	w := printers.GetNewTabWriter(p.Err)
+	fmt.Fprintln(w, "POD\tCONTAINER\tEXIT CODE\tERROR")
+	for _, result := range results {
+		status, message := fmt.Sprint(result.status), ""
+		if result.err != nil {
+			status, message = "-", result.err.Error()
+		}
+		if result.failed() {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.pod, result.container, status, message)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("the command failed in %d of %d pods", failed, len(results))
+	}
+	return nil
+}
+
+// execInPod runs the command in the container of pod, the first one unless a
+// container name is given.
+func (p *ExecOptions) execInPod(exec podExecFunc, pod *api.Pod, lock sync.Locker) podExecResult {
+	result := podExecResult{pod: pod.Name, container: p.ContainerName}
+	if len(result.container) == 0 {
+		if len(pod.Spec.Containers) == 0 {
+			result.err = fmt.Errorf("pod has no containers")
+			return result
+		}
+		result.container = pod.Spec.Containers[0].Name
+	}
+
+	prefix := "[" + pod.Name + "] "
+	stdout := &prefixWriter{out: p.Out, prefix: prefix, lock: lock}
+	stderr := &prefixWriter{out: p.Err, prefix: prefix, lock: lock}
+	glog.V(4).Infof("running %v in container %s of pod %s", p.Command, result.container, pod.Name)
+	ctx, cancel := hyper.InterruptibleContext()
+	defer cancel()
+	result.status, result.err = exec(ctx, pod.Name, result.container, p.Command, nil, stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+	return result
+}
+
+// prefixWriter writes the lines written to it to out, each one prefixed.
+// The writers sharing lock do not interleave their lines.
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	lock   sync.Locker
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(data), nil
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush writes the last line when it does not end with a newline.
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+	return err
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	lock := &sync.Mutex{}
+	web1 := &prefixWriter{out: out, prefix: "[web-1] ", lock: lock}
+	web2 := &prefixWriter{out: out, prefix: "[web-2] ", lock: lock}
+
+	io.WriteString(web1, "first\nsec")
+	io.WriteString(web2, "other ")
+	io.WriteString(web1, "ond\n\nthird")
+	io.WriteString(web2, "line\n")
+	web1.Flush()
+	web2.Flush()
+
+	// the lines are written once complete, the last one on Flush
+	expected := "[web-1] first\n[web-1] second\n[web-1] \n[web-2] other line\n[web-1] third\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func execTestPods(names ...string) []api.Pod {
+	pods := []api.Pod{}
+	for _, name := range names {
+		pods = append(pods, api.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx"}, {Name: "sidecar"}}},
+		})
+	}
+	return pods
+}
+
+func TestExecPodsParallel(t *testing.T) {
+	lock := sync.Mutex{}
+	running, maxRunning := 0, 0
+	exec := func(ctx context.Context, pod, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprintf(stdout, "%s %s\n", container, strings.Join(cmd, " "))
+		lock.Lock()
+		running--
+		lock.Unlock()
+		return 0, nil
+	}
+
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	options := &ExecOptions{
+		StreamOptions: StreamOptions{Out: out, Err: errOut},
+		Command:       []string{"nginx", "-s", "reload"},
+		Parallel:      2,
+	}
+	if err := options.execPods(execTestPods("web-1", "web-2", "web-3", "web-4", "web-5"), exec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if maxRunning != 2 {
+		t.Errorf("expected the command to run in 2 pods at a time, got %d", maxRunning)
+	}
+	for i := 1; i <= 5; i++ {
+		if line := fmt.Sprintf("[web-%d] nginx nginx -s reload\n", i); !strings.Contains(out.String(), line) {
+			t.Errorf("expected %q in the output, got %q", line, out.String())
+		}
+	}
+	// the summary is on the error output only
+	if strings.Contains(out.String(), "EXIT CODE") || !strings.Contains(errOut.String(), "EXIT CODE") {
+		t.Errorf("expected the summary on the error output, got %q and %q", out.String(), errOut.String())
+	}
+}
+
+func TestExecPodsFails(t *testing.T) {
+	exec := func(ctx context.Context, pod, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+		switch pod {
+		case "web-2":
+			io.WriteString(stderr, "reload failed")
+			return 1, nil
+		case "web-3":
+			return 0, fmt.Errorf("container %s not running", container)
+		}
+		return 0, nil
+	}
+
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	options := &ExecOptions{
+		StreamOptions: StreamOptions{Out: out, Err: errOut, ContainerName: "sidecar"},
+		Command:       []string{"reload"},
+		Parallel:      10,
+	}
+	err := options.execPods(execTestPods("web-1", "web-2", "web-3"), exec)
+	if err == nil || err.Error() != "the command failed in 2 of 3 pods" {
+		t.Errorf("expected the command to fail in 2 pods, got %v", err)
+	}
+	expected := []string{
+		"[web-2] reload failed\n",
+		"POD       CONTAINER   EXIT CODE   ERROR\n",
+		"web-1     sidecar     0           \n",
+		"web-2     sidecar     1           \n",
+		"web-3     sidecar     -           container sidecar not running\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(errOut.String(), line) {
+			t.Errorf("expected %q in the error output, got %q", line, errOut.String())
+		}
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hyperhq/pi/pkg/pi"
+	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/pi/util/i18n"
+
+	"github.com/spf13/cobra"
+	"k8s.io/api/core/v1"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+var (
+	exposeLong = templates.LongDesc(i18n.T(`
+		Expose a pod as a new ClusterIP or LoadBalancer service.
+
+		The labels of the pod are used as the selector of the service. The ports
+		of the service are given with --port, or else taken from the container
+		ports of the pod.
+
+		A LoadBalancer service needs a fip, given with --loadbalancerip as an
+		address, or allocated with --loadbalancerip=auto.`))
+
+	exposeExample = templates.Examples(i18n.T(`
+		# Create a ClusterIP service for the container ports of pod nginx
+		pi expose pod/nginx
+
+		# Create a ClusterIP service named web for pod nginx, serving on port 80 and connecting to port 8080
+		pi expose pod nginx --port=80 --target-port=8080 --name=web
+
+		# Create a LoadBalancer service for pod dns on a new fip, serving udp on port 53
+		pi expose pod/dns --type=LoadBalancer --port=53 --protocol=UDP --loadbalancerip=auto --fip-name=dns`))
+)
+
+// NewCmdExpose returns the expose command.
+func NewCmdExpose(f cmdutil.Factory, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "expose (TYPE/NAME | TYPE NAME) [--port=port] [--target-port=port] [--protocol=TCP|UDP] [--type=ClusterIP|LoadBalancer] [--loadbalancerip=fip|auto] [--name=name]",
+		Short:   i18n.T("Expose a pod as a new service"),
+		Long:    exposeLong,
+		Example: exposeExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := RunExpose(f, out, cmd, args)
+			cmdutil.CheckErr(err)
+		},
+	}
+	cmd.Flags().String("type", string(v1.ServiceTypeClusterIP), "Type of the service: ClusterIP or LoadBalancer.")
+	cmd.Flags().String("port", "", "The port the service serves on. Defaults to the container ports of the pod.")
+	cmd.Flags().String("target-port", "", "Name or number of the container port the service connects to. Defaults to --port.")
+	cmd.Flags().String("protocol", "", "The protocol of --port, TCP or UDP. Defaults to TCP.")
+	cmd.Flags().String("name", "", "The name of the service. Defaults to the name of the pod.")
+	addLoadBalancerIPFlags(cmd)
+	return cmd
+}
+
+// RunExpose is the implementation of the expose command.
+func RunExpose(f cmdutil.Factory, out io.Writer, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return cmdutil.UsageErrorf(cmd, "a pod is required, as pod/NAME or pod NAME")
+	}
+	namespace, _, err := f.DefaultNamespace()
+	if err != nil {
+		return err
+	}
+	obj, err := f.NewBuilder().
+		Internal().
+		NamespaceParam(namespace).DefaultNamespace().
+		ResourceTypeOrNameArgs(false, args...).
+		SingleResourceType().
+		Do().Object()
+	if err != nil {
+		return err
+	}
+	pod, ok := obj.(*api.Pod)
+	if !ok {
+		return fmt.Errorf("cannot expose a %T, only pods can be exposed", obj)
+	}
+
+	serviceType, err := serviceTypeFromFlag(cmd, "type")
+	if err != nil {
+		return err
+	}
+	if serviceType != v1.ServiceTypeLoadBalancer && cmd.Flags().Changed("loadbalancerip") {
+		return cmdutil.UsageErrorf(cmd, "--loadbalancerip can only be used with --type=LoadBalancer")
+	}
+
+	ports, err := exposedPorts(cmd, pod)
+	if err != nil {
+		return err
+	}
+	selector, err := podSelector(pod)
+	if err != nil {
+		return err
+	}
+	name := cmdutil.GetFlagString(cmd, "name")
+	if len(name) == 0 {
+		name = pod.Name
+	}
+
+	generator := &pi.ServiceCommonGeneratorV1{
+		Name:           name,
+		Ports:          ports,
+		Type:           serviceType,
+		LoadBalancerIP: cmdutil.GetFlagString(cmd, "loadbalancerip"),
+		Selector:       selector,
+		ExactSelector:  true,
+	}
+	// fail on invalid flags before a fip is allocated
+	if _, err := generator.StructuredGenerate(); err != nil {
+		return err
+	}
+	finish, err := allocateLoadBalancerIP(f, cmd, out, &generator.LoadBalancerIP)
+	if err != nil {
+		return err
+	}
+	err = RunCreateSubcommand(f, cmd, out, &CreateSubcommandOptions{
+		Name:                name,
+		StructuredGenerator: generator,
+	})
+	finish(err)
+	return err
+}
+
+// serviceTypeFromFlag returns the service type of flag, the types pi can
+// create are accepted in any case.
+func serviceTypeFromFlag(cmd *cobra.Command, flag string) (v1.ServiceType, error) {
+	switch t := cmdutil.GetFlagString(cmd, flag); strings.ToLower(t) {
+	case strings.ToLower(string(v1.ServiceTypeClusterIP)):
+		return v1.ServiceTypeClusterIP, nil
+	case strings.ToLower(string(v1.ServiceTypeLoadBalancer)):
+		return v1.ServiceTypeLoadBalancer, nil
+	default:
+		return "", cmdutil.UsageErrorf(cmd, "invalid --%s %q, must be ClusterIP or LoadBalancer", flag, t)
+	}
+}
+
+// exposedPorts returns the ports of the service in the format of the --port
+// flag of create service, either from the flags or from the container ports
+// of pod.
+func exposedPorts(cmd *cobra.Command, pod *api.Pod) ([]string, error) {
+	port := cmdutil.GetFlagString(cmd, "port")
+	targetPort := cmdutil.GetFlagString(cmd, "target-port")
+	protocol := cmdutil.GetFlagString(cmd, "protocol")
+	if len(port) > 0 {
+		spec := port
+		if len(targetPort) > 0 {
+			spec += ":" + targetPort
+		}
+		if len(protocol) > 0 {
+			spec += "/" + protocol
+		}
+		return []string{spec}, nil
+	}
+	if len(targetPort) > 0 || len(protocol) > 0 {
+		return nil, cmdutil.UsageErrorf(cmd, "--target-port and --protocol can only be used with --port")
+	}
+
+	ports := []string{}
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			spec := fmt.Sprintf("%d", p.ContainerPort)
+			if len(p.Protocol) > 0 {
+				spec += "/" + string(p.Protocol)
+			}
+			if len(p.Name) > 0 {
+				spec = p.Name + ":" + spec
+			}
+			ports = append(ports, spec)
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("pod %s has no container ports, specify the port to expose with --port", pod.Name)
+	}
+	return ports, nil
+}
+
+// podSelector returns the labels of pod as selectors of create service.
+func podSelector(pod *api.Pod) ([]string, error) {
+	if len(pod.Labels) == 0 {
+		return nil, fmt.Errorf("pod %s has no labels, the service could not select it", pod.Name)
+	}
+	selector := []string{}
+	for k, v := range pod.Labels {
+		selector = append(selector, k+"="+v)
+	}
+	sort.Strings(selector)
+	return selector, nil
+}
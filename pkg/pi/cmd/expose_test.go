@@ -0,0 +1,173 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperhq/client-go/rest/fake"
+	cmdtesting "github.com/hyperhq/pi/pkg/pi/cmd/testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+func TestExpose(t *testing.T) {
+	nginx := &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "test", Labels: map[string]string{"app": "nginx", "tier": "web"}},
+		Spec: api.PodSpec{Containers: []api.Container{
+			{Name: "nginx", Ports: []api.ContainerPort{{Name: "http", ContainerPort: 80, Protocol: api.ProtocolTCP}}},
+			{Name: "dns", Ports: []api.ContainerPort{{Name: "dns", ContainerPort: 53, Protocol: api.ProtocolUDP}}},
+		}},
+	}
+	unlabelled := nginx.DeepCopy()
+	unlabelled.Labels = nil
+	portless := nginx.DeepCopy()
+	portless.Spec.Containers = []api.Container{{Name: "nginx"}}
+
+	tests := []struct {
+		name          string
+		pod           *api.Pod
+		flags         map[string]string
+		serviceStatus int
+		expectErr     string
+		expectedPorts []api.ServicePort
+		expectedFips  []string
+	}{
+		{
+			name:          "container ports",
+			pod:           nginx,
+			serviceStatus: http.StatusCreated,
+			expectedPorts: []api.ServicePort{
+				{Name: "http", Port: 80, Protocol: api.ProtocolTCP, TargetPort: intstr.FromInt(80)},
+				{Name: "dns", Port: 53, Protocol: api.ProtocolUDP, TargetPort: intstr.FromInt(53)},
+			},
+		},
+		{
+			name:          "port flags",
+			pod:           nginx,
+			flags:         map[string]string{"port": "8080", "target-port": "http", "protocol": "TCP"},
+			serviceStatus: http.StatusCreated,
+			expectedPorts: []api.ServicePort{
+				{Name: "8080-http", Port: 8080, Protocol: api.ProtocolTCP, TargetPort: intstr.FromString("http")},
+			},
+		},
+		{
+			name:      "no labels",
+			pod:       unlabelled,
+			expectErr: "pod nginx has no labels",
+		},
+		{
+			name:      "no ports",
+			pod:       portless,
+			expectErr: "pod nginx has no container ports",
+		},
+		{
+			name:      "target port without port",
+			pod:       nginx,
+			flags:     map[string]string{"target-port": "8080"},
+			expectErr: "--target-port and --protocol can only be used with --port",
+		},
+		{
+			name:      "protocol without port",
+			pod:       nginx,
+			flags:     map[string]string{"protocol": "UDP"},
+			expectErr: "--target-port and --protocol can only be used with --port",
+		},
+		{
+			name:      "loadbalancerip of a ClusterIP service",
+			pod:       nginx,
+			flags:     map[string]string{"loadbalancerip": "auto"},
+			expectErr: "--loadbalancerip can only be used with --type=LoadBalancer",
+		},
+		{
+			name:          "service failed",
+			pod:           nginx,
+			flags:         map[string]string{"type": "LoadBalancer", "loadbalancerip": "auto"},
+			serviceStatus: http.StatusInternalServerError,
+			expectErr:     "(post services)",
+			expectedFips:  []string{"POST /api/v1/hyper/fips?count=1", "DELETE /api/v1/hyper/fips/10.0.0.9"},
+		},
+	}
+	for _, test := range tests {
+		server := newFakeFipServer()
+
+		f, tf, codec, ns := cmdtesting.NewAPIFactory()
+		tf.ClientConfig = server.ClientConfig()
+		var service *api.Service
+		tf.Client = &fake.RESTClient{
+			GroupVersion:         schema.GroupVersion{Version: "v1"},
+			NegotiatedSerializer: ns,
+			Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				switch p, m := req.URL.Path, req.Method; {
+				case p == "/namespaces/test/pods/nginx" && m == http.MethodGet:
+					return &http.Response{StatusCode: http.StatusOK, Header: defaultHeader(), Body: objBody(codec, test.pod)}, nil
+				case p == "/namespaces/test/services" && m == http.MethodPost && test.serviceStatus != 0:
+					data, _ := ioutil.ReadAll(req.Body)
+					service = &api.Service{}
+					if err := runtime.DecodeInto(codec, data, service); err != nil {
+						t.Fatalf("%s: %v", test.name, err)
+					}
+					if test.serviceStatus != http.StatusCreated {
+						return &http.Response{StatusCode: test.serviceStatus, Header: defaultHeader(), Body: ioutil.NopCloser(bytes.NewBufferString("{}"))}, nil
+					}
+					return &http.Response{StatusCode: test.serviceStatus, Header: defaultHeader(), Body: objBody(codec, service)}, nil
+				default:
+					t.Fatalf("%s: unexpected request: %#v\n%#v", test.name, req.URL, req)
+					return nil, nil
+				}
+			}),
+		}
+		tf.Namespace = "test"
+		buf := bytes.NewBuffer([]byte{})
+
+		cmd := NewCmdExpose(f, buf)
+		for name, value := range test.flags {
+			cmd.Flags().Set(name, value)
+		}
+		err := RunExpose(f, buf, cmd, []string{"pod/nginx"})
+		server.Close()
+
+		switch {
+		case len(test.expectErr) == 0 && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		case len(test.expectErr) > 0 && (err == nil || !strings.Contains(err.Error(), test.expectErr)):
+			t.Errorf("%s: expected %q in the error, got %v", test.name, test.expectErr, err)
+		}
+		if requests := server.Requests(); !reflect.DeepEqual(requests, test.expectedFips) {
+			t.Errorf("%s: expected the fip requests %v, got %v", test.name, test.expectedFips, requests)
+		}
+		if test.expectedPorts == nil {
+			continue
+		}
+		if !reflect.DeepEqual(service.Spec.Ports, test.expectedPorts) {
+			t.Errorf("%s: expected the ports %+v, got %+v", test.name, test.expectedPorts, service.Spec.Ports)
+		}
+		if expected := map[string]string{"app": "nginx", "tier": "web"}; !reflect.DeepEqual(service.Spec.Selector, expected) {
+			t.Errorf("%s: expected the selector %v, got %v", test.name, expected, service.Spec.Selector)
+		}
+	}
+}
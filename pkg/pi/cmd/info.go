@@ -23,8 +23,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/hyperhq/client-go/tools/clientcmd/api/hyper"
 	"github.com/hyperhq/pi"
+	"github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
 	"github.com/hyperhq/pi/pkg/pi/util/i18n"
@@ -62,9 +62,7 @@ func InfoGeneric(f cmdutil.Factory, cmdOut io.Writer, cmd *cobra.Command, args [
 	if cfg, err := f.ClientConfig(); err != nil {
 		return err
 	} else {
-		hyperConn := hyper.NewHyperConn(cfg)
-		infoCli := hyper.NewInfoCli(hyperConn)
-		if _, info, err := infoCli.GetInfo(); err != nil {
+		if _, info, err := hyper.NewConn(cfg).GetInfo(); err != nil {
 			return err
 		} else {
 			PrintInfoResult(info)
@@ -74,8 +72,7 @@ func InfoGeneric(f cmdutil.Factory, cmdOut io.Writer, cmd *cobra.Command, args [
 	updater := pi.NewCheckUpdate()
 	if cmdutil.GetFlagBool(cmd, "check-update") {
 		//force check version
-		pi.CheckRelease()
-		updater.WriteTime(time.Now())
+		updater.WriteLatest(pi.CheckRelease(), time.Now())
 	} else {
 		//check version after 24 hours
 		lastUpdate := updater.ReadTime()
@@ -85,8 +82,7 @@ func InfoGeneric(f cmdutil.Factory, cmdOut io.Writer, cmd *cobra.Command, args [
 				log.Printf("More than 24 hours(%v) of uncheck version.", int(hours))
 			}
 			//start check new version
-			pi.CheckRelease()
-			updater.WriteTime(time.Now())
+			updater.WriteLatest(pi.CheckRelease(), time.Now())
 		} else {
 			if os.Getenv("HYPER_DEBUG") == "true" {
 				log.Printf("Checked version in 24 hours(%v), skip.", int(hours))
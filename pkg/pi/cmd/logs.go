@@ -17,19 +17,25 @@ limitations under the License.
 package cmd
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
 	"io"
 	"math"
 	"os"
+	"sync"
 	"time"
 
 	restclient "github.com/hyperhq/client-go/rest"
+	"github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
 	"github.com/hyperhq/pi/pkg/pi/util"
 	"github.com/hyperhq/pi/pkg/pi/util/i18n"
 
+	"github.com/golang/glog"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/context"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -45,6 +51,12 @@ var (
 		# Return snapshot logs for the pods defined by label app=mongo
 		pi logs -lapp=mongo
 
+		# Begin streaming the logs of the mongo container in pod mongo
+		pi logs -f -c mongo mongo
+
+		# Stream the logs of all containers of the pods labeled app=web, including the pods started later
+		pi logs -f -l app=web --all-containers --color
+
 		# Return snapshot of previous terminated mongo container logs from pod mongo
 		pi logs -c mongo mongo
 
@@ -55,6 +67,13 @@ var (
 		pi logs --since=1h mongo`))
 
 	selectorTail int64 = 10
+
+	// logColors are the ANSI colors the prefixes of different pods are printed in.
+	logColors = []int{32, 33, 34, 35, 36, 31}
+
+	// podPollInterval is how often the pods matching the selector are listed
+	// again while following, to pick up the pods created in the meantime.
+	podPollInterval = 5 * time.Second
 )
 
 const (
@@ -74,11 +93,20 @@ type LogsOptions struct {
 	GetPodTimeout time.Duration
 	LogsForObject func(object, options runtime.Object, timeout time.Duration) (*restclient.Request, error)
 
-	Out io.Writer
+	// Selector, when set, streams the logs of every pod matching it. ListPods
+	// returns those pods again while following.
+	Selector      string
+	ListPods      func() ([]*api.Pod, error)
+	AllContainers bool
+	Prefix        bool
+	Color         bool
+
+	Out    io.Writer
+	ErrOut io.Writer
 }
 
 // NewCmdLogs creates a new pod logs command
-func NewCmdLogs(f cmdutil.Factory, out io.Writer) *cobra.Command {
+func NewCmdLogs(f cmdutil.Factory, out, errOut io.Writer) *cobra.Command {
 	o := &LogsOptions{}
 	cmd := &cobra.Command{
 		Use:     "logs [-f] (POD | TYPE/NAME) [-c CONTAINER]",
@@ -91,13 +119,13 @@ func NewCmdLogs(f cmdutil.Factory, out io.Writer) *cobra.Command {
 			}
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			cmdutil.CheckErr(o.Complete(f, out, cmd, args))
+			cmdutil.CheckErr(o.Complete(f, out, errOut, cmd, args))
 			cmdutil.CheckErr(o.Validate())
 			cmdutil.CheckErr(o.RunLogs())
 		},
 		Aliases: []string{"log"},
 	}
-	//cmd.Flags().BoolP("follow", "f", false, "Specify if the logs should be streamed.")
+	cmd.Flags().BoolP("follow", "f", false, "Specify if the logs should be streamed.")
 	cmd.Flags().Bool("timestamps", false, "Include timestamps on each line in the log output")
 	cmd.Flags().Int64("limit-bytes", 0, "Maximum bytes of logs to return. Defaults to no limit.")
 	cmd.Flags().BoolP("previous", "p", false, "If true, print the logs for the previous instance of the container in a pod if it exists.")
@@ -109,10 +137,13 @@ func NewCmdLogs(f cmdutil.Factory, out io.Writer) *cobra.Command {
 	//cmd.Flags().MarkDeprecated("interactive", "This flag is no longer respected and there is no replacement.")
 	cmdutil.AddPodRunningTimeoutFlag(cmd, defaultPodLogsTimeout)
 	cmd.Flags().StringP("selector", "l", "", "Selector (label query) to filter on.")
+	cmd.Flags().Bool("all-containers", false, "Print the logs of all containers in the pod(s).")
+	cmd.Flags().Bool("prefix", true, "Prefix each line with [pod/container] when printing the logs of several pods or containers.")
+	cmd.Flags().Bool("color", false, "Print the prefix of each pod in its own color.")
 	return cmd
 }
 
-func (o *LogsOptions) Complete(f cmdutil.Factory, out io.Writer, cmd *cobra.Command, args []string) error {
+func (o *LogsOptions) Complete(f cmdutil.Factory, out, errOut io.Writer, cmd *cobra.Command, args []string) error {
 	containerName := cmdutil.GetFlagString(cmd, "container")
 	selector := cmdutil.GetFlagString(cmd, "selector")
 	switch len(args) {
@@ -141,8 +172,8 @@ func (o *LogsOptions) Complete(f cmdutil.Factory, out io.Writer, cmd *cobra.Comm
 	}
 
 	logOptions := &api.PodLogOptions{
-		Container: containerName,
-		//Follow:     cmdutil.GetFlagBool(cmd, "follow"),
+		Container:  containerName,
+		Follow:     cmdutil.GetFlagBool(cmd, "follow"),
 		Previous:   cmdutil.GetFlagBool(cmd, "previous"),
 		Timestamps: cmdutil.GetFlagBool(cmd, "timestamps"),
 	}
@@ -172,32 +203,61 @@ func (o *LogsOptions) Complete(f cmdutil.Factory, out io.Writer, cmd *cobra.Comm
 	o.Options = logOptions
 	o.LogsForObject = f.LogsForObject
 	o.Out = out
+	o.ErrOut = errOut
+	o.Selector = selector
+	o.AllContainers = cmdutil.GetFlagBool(cmd, "all-containers")
+	o.Prefix = cmdutil.GetFlagBool(cmd, "prefix")
+	o.Color = cmdutil.GetFlagBool(cmd, "color")
+	if o.AllContainers && len(containerName) > 0 {
+		return cmdutil.UsageErrorf(cmd, "only one of --all-containers or a container name is allowed")
+	}
 
 	if len(selector) != 0 {
-		if logOptions.Follow {
-			return cmdutil.UsageErrorf(cmd, "only one of follow (-f) or selector (-l) is allowed")
-		}
-		if logOptions.TailLines == nil && tail != -1 {
+		if logOptions.TailLines == nil && tail == -1 {
 			logOptions.TailLines = &selectorTail
 		}
+		o.ListPods = func() ([]*api.Pod, error) {
+			infos, err := f.NewBuilder().
+				Internal().
+				NamespaceParam(o.Namespace).DefaultNamespace().
+				ResourceTypes("pods").LabelSelectorParam(selector).
+				Flatten().
+				Do().Infos()
+			if err != nil {
+				return nil, err
+			}
+			pods := []*api.Pod{}
+			for _, info := range infos {
+				if pod, ok := info.Object.(*api.Pod); ok {
+					pods = append(pods, pod)
+				}
+			}
+			return pods, nil
+		}
 	}
 
-	if o.Object == nil {
+	if o.Object == nil && o.ListPods != nil && !logOptions.Follow {
+		pods, err := o.ListPods()
+		if err != nil {
+			return err
+		}
+		list := &api.PodList{}
+		for _, pod := range pods {
+			list.Items = append(list.Items, *pod)
+		}
+		o.Object = list
+	}
+	if o.Object == nil && o.ListPods == nil {
 		builder := f.NewBuilder().
 			Internal().
 			NamespaceParam(o.Namespace).DefaultNamespace().
-			SingleResourceType()
-		if o.ResourceArg != "" {
-			builder.ResourceNames("pods", o.ResourceArg)
-		}
-		if selector != "" {
-			builder.ResourceTypes("pods").LabelSelectorParam(selector)
-		}
+			SingleResourceType().
+			ResourceNames("pods", o.ResourceArg)
 		infos, err := builder.Do().Infos()
 		if err != nil {
 			return err
 		}
-		if selector == "" && len(infos) != 1 {
+		if len(infos) != 1 {
 			return errors.New("expected a resource")
 		}
 		o.Object = infos[0].Object
@@ -220,17 +280,36 @@ func (o LogsOptions) Validate() error {
 
 // RunLogs retrieves a pod log
 func (o LogsOptions) RunLogs() error {
+	logOptions := o.Options.(*api.PodLogOptions)
+	if len(o.Selector) > 0 && logOptions.Follow {
+		return o.followSelected(hyper.Context())
+	}
+
+	pods := []*api.Pod{}
 	switch t := o.Object.(type) {
 	case *api.PodList:
-		for _, p := range t.Items {
-			if err := o.getLogs(&p); err != nil {
-				return err
-			}
+		for i := range t.Items {
+			pods = append(pods, &t.Items[i])
 		}
-		return nil
+	case *api.Pod:
+		pods = append(pods, t)
 	default:
 		return o.getLogs(o.Object)
 	}
+
+	streams := []logStream{}
+	for i, pod := range pods {
+		streams = append(streams, o.streamsForPod(pod, i, len(pods) > 1)...)
+	}
+	if logOptions.Follow && len(streams) > 1 {
+		return o.followStreams(streams)
+	}
+	for _, stream := range streams {
+		if err := o.copyStream(hyper.Context(), stream, nil); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (o LogsOptions) getLogs(obj runtime.Object) error {
@@ -248,3 +327,214 @@ func (o LogsOptions) getLogs(obj runtime.Object) error {
 	_, err = io.Copy(o.Out, readCloser)
 	return err
 }
+
+// logStream is the log of one container of a pod, and the prefix of its lines.
+type logStream struct {
+	pod     *api.Pod
+	options *api.PodLogOptions
+	prefix  string
+}
+
+// streamsForPod returns the log streams of pod, one per container when all
+// containers are requested. The index of the pod picks the color of its prefix.
+func (o LogsOptions) streamsForPod(pod *api.Pod, index int, multiPod bool) []logStream {
+	logOptions := o.Options.(*api.PodLogOptions)
+	containers := []string{logOptions.Container}
+	if o.AllContainers {
+		containers = []string{}
+		for _, c := range pod.Spec.Containers {
+			containers = append(containers, c.Name)
+		}
+	}
+
+	streams := []logStream{}
+	for _, container := range containers {
+		options := *logOptions
+		options.Container = container
+
+		prefix := ""
+		if o.Prefix && (multiPod || o.AllContainers) {
+			name := container
+			if len(name) == 0 && len(pod.Spec.Containers) == 1 {
+				name = pod.Spec.Containers[0].Name
+			}
+			prefix = "[" + pod.Name
+			if len(name) > 0 {
+				prefix += "/" + name
+			}
+			prefix += "] "
+			if o.Color {
+				prefix = fmt.Sprintf("\x1b[%dm%s\x1b[0m", logColors[index%len(logColors)], prefix)
+			}
+		}
+		streams = append(streams, logStream{pod: pod, options: &options, prefix: prefix})
+	}
+	return streams
+}
+
+// copyStream copies the lines of stream to o.Out until it ends or ctx is
+// cancelled. Streams copied concurrently share lock, it is held while writing
+// a line so that lines are not interleaved.
+func (o LogsOptions) copyStream(ctx context.Context, stream logStream, lock sync.Locker) error {
+	req, err := o.LogsForObject(stream.pod, stream.options, o.GetPodTimeout)
+	if err != nil {
+		return err
+	}
+	readCloser, err := req.Context(ctx).Stream()
+	if err != nil {
+		return err
+	}
+	defer readCloser.Close()
+
+	if len(stream.prefix) == 0 && lock == nil {
+		_, err = io.Copy(o.Out, readCloser)
+		return err
+	}
+	r := bufio.NewReader(readCloser)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			if lock != nil {
+				lock.Lock()
+			}
+			_, writeErr := io.WriteString(o.Out, stream.prefix+line)
+			if lock != nil {
+				lock.Unlock()
+			}
+			if writeErr != nil {
+				return writeErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// followStreams streams all of streams concurrently until they end.
+func (o LogsOptions) followStreams(streams []logStream) error {
+	lock := &sync.Mutex{}
+	errs := make(chan error, len(streams))
+	for _, stream := range streams {
+		go func(stream logStream) {
+			errs <- o.copyStream(hyper.Context(), stream, lock)
+		}(stream)
+	}
+	var firstErr error
+	for range streams {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// followedPod is a pod whose logs are followed by followSelected.
+type followedPod struct {
+	// index picks the color of the prefix of the pod
+	index int
+	// restarts are the restarts of the containers of the pod when its
+	// streams were started
+	restarts int32
+	// streaming is the number of streams of the pod still running
+	streaming int
+	// failed is set when one of the streams ended with an error
+	failed bool
+	// ended is when the last stream of the pod ended
+	ended metav1.Time
+}
+
+// restartCount returns the restarts of the containers of pod.
+func restartCount(pod *api.Pod) int32 {
+	restarts := int32(0)
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return restarts
+}
+
+// followSelected streams the logs of the pods matching the selector, and of the
+// pods matching it later on, until ctx is cancelled. The pods whose streams
+// ended are followed again from then on once their containers restart, or
+// when a stream failed. The errors are reported on o.ErrOut, the pods are
+// listed again on the next poll when they can't be listed. The streams are
+// cancelled and waited for before returning.
+func (o LogsOptions) followSelected(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lock := &sync.Mutex{}
+	reportError := func(prefix string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		fmt.Fprintf(o.ErrOut, "%serror: %v\n", prefix, err)
+	}
+	// followed are the pods by UID, guarded by followLock as their streams
+	// end concurrently
+	followLock := &sync.Mutex{}
+	followed := map[string]*followedPod{}
+	for {
+		pods, err := o.ListPods()
+		if err != nil {
+			reportError("", fmt.Errorf("unable to list the pods matching %q: %v", o.Selector, err))
+		}
+		followLock.Lock()
+		for _, pod := range pods {
+			key := string(pod.UID)
+			if len(key) == 0 {
+				key = pod.Name
+			}
+			// pending pods have no logs yet, they are retried on the next poll
+			if pod.Status.Phase == api.PodPending {
+				continue
+			}
+			restarts := restartCount(pod)
+			f, found := followed[key]
+			if found && (f.streaming > 0 || (restarts == f.restarts && !f.failed)) {
+				continue
+			}
+			if !found {
+				f = &followedPod{index: len(followed)}
+				followed[key] = f
+			}
+			streams := o.streamsForPod(pod, f.index, true)
+			if found {
+				// the lines logged before the end of the last streams were
+				// printed already
+				for _, stream := range streams {
+					stream.options.TailLines = nil
+					stream.options.SinceSeconds = nil
+					stream.options.SinceTime = f.ended.DeepCopy()
+				}
+			}
+			f.restarts, f.streaming, f.failed = restarts, len(streams), false
+			for _, stream := range streams {
+				wg.Add(1)
+				go func(f *followedPod, stream logStream) {
+					defer wg.Done()
+					err := o.copyStream(ctx, stream, lock)
+					if err != nil && ctx.Err() == nil {
+						reportError(stream.prefix, err)
+					}
+					followLock.Lock()
+					defer followLock.Unlock()
+					f.streaming--
+					f.failed = f.failed || err != nil
+					f.ended = metav1.Now()
+				}(f, stream)
+			}
+		}
+		glog.V(4).Infof("following the logs of %d pod(s) matching %q", len(followed), o.Selector)
+		followLock.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(podPollInterval):
+		}
+	}
+}
@@ -0,0 +1,447 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	restclient "github.com/hyperhq/client-go/rest"
+	"github.com/hyperhq/client-go/rest/fake"
+	cmdtesting "github.com/hyperhq/pi/pkg/pi/cmd/testing"
+
+	"golang.org/x/net/context"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+// fakeLogs serves the logs of the containers of the pods, keyed by
+// pod/container, and records the streams requested, with @since when they
+// start after the time of an earlier stream. The streams of the keys in
+// blocking are kept open until their request is cancelled.
+type fakeLogs struct {
+	lock     sync.Mutex
+	logs     map[string]string
+	blocking map[string]bool
+	requests []string
+	// open is the number of streams not closed yet
+	open int
+}
+
+func (l *fakeLogs) LogsForObject(object, options runtime.Object, timeout time.Duration) (*restclient.Request, error) {
+	pod := object.(*api.Pod)
+	logOptions := options.(*api.PodLogOptions)
+	key := pod.Name + "/" + logOptions.Container
+	l.lock.Lock()
+	if logOptions.SinceTime != nil {
+		l.requests = append(l.requests, key+"@since")
+	} else {
+		l.requests = append(l.requests, key)
+	}
+	l.lock.Unlock()
+	client := &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			var body io.Reader = strings.NewReader(l.logs[key])
+			if l.blocking[key] {
+				body = io.MultiReader(body, &cancelledReader{req.Context()})
+			}
+			l.lock.Lock()
+			l.open++
+			l.lock.Unlock()
+			return &http.Response{StatusCode: http.StatusOK, Header: defaultHeader(), Body: &countedBody{Reader: body, logs: l}}, nil
+		}),
+	}
+	return client.Get().Resource("pods").Name(pod.Name).SubResource("log"), nil
+}
+
+// cancelledReader blocks until ctx is cancelled.
+type cancelledReader struct {
+	ctx context.Context
+}
+
+func (r *cancelledReader) Read(p []byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+// countedBody counts the streams of logs closed.
+type countedBody struct {
+	io.Reader
+	logs *fakeLogs
+}
+
+func (b *countedBody) Close() error {
+	b.logs.lock.Lock()
+	defer b.logs.lock.Unlock()
+	b.logs.open--
+	return nil
+}
+
+func (l *fakeLogs) Open() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.open
+}
+
+func (l *fakeLogs) Requests() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	requests := append([]string{}, l.requests...)
+	sort.Strings(requests)
+	return requests
+}
+
+// logsBuffer is written by the streams followed concurrently.
+type logsBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *logsBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logsBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func logsTestPod(name string, phase api.PodPhase, containers ...string) *api.Pod {
+	pod := &api.Pod{}
+	pod.Name = name
+	pod.UID = types.UID("uid-" + name)
+	pod.Status.Phase = phase
+	for _, c := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, api.Container{Name: c})
+	}
+	return pod
+}
+
+func TestLogsStreamsForPod(t *testing.T) {
+	web := logsTestPod("web", api.PodRunning, "nginx")
+	app := logsTestPod("app", api.PodRunning, "app", "sidecar")
+	tests := []struct {
+		name          string
+		pod           *api.Pod
+		container     string
+		multiPod      bool
+		allContainers bool
+		noPrefix      bool
+		color         bool
+		expected      []string
+	}{
+		{
+			name:     "single pod",
+			pod:      web,
+			expected: []string{"web/:"},
+		},
+		{
+			name:     "several pods",
+			pod:      web,
+			multiPod: true,
+			expected: []string{"web/:[web/nginx] "},
+		},
+		{
+			name:      "several pods, container",
+			pod:       app,
+			container: "sidecar",
+			multiPod:  true,
+			expected:  []string{"app/sidecar:[app/sidecar] "},
+		},
+		{
+			name:     "several pods, several containers",
+			pod:      app,
+			multiPod: true,
+			expected: []string{"app/:[app] "},
+		},
+		{
+			name:          "all containers",
+			pod:           app,
+			allContainers: true,
+			expected:      []string{"app/app:[app/app] ", "app/sidecar:[app/sidecar] "},
+		},
+		{
+			name:          "no prefix",
+			pod:           app,
+			multiPod:      true,
+			allContainers: true,
+			noPrefix:      true,
+			expected:      []string{"app/app:", "app/sidecar:"},
+		},
+		{
+			name:     "color",
+			pod:      web,
+			multiPod: true,
+			color:    true,
+			expected: []string{"web/:\x1b[33m[web/nginx] \x1b[0m"},
+		},
+	}
+	for _, test := range tests {
+		o := LogsOptions{
+			Options:       &api.PodLogOptions{Container: test.container},
+			AllContainers: test.allContainers,
+			Prefix:        !test.noPrefix,
+			Color:         test.color,
+		}
+		streams := []string{}
+		for _, stream := range o.streamsForPod(test.pod, 1, test.multiPod) {
+			if stream.pod != test.pod {
+				t.Errorf("%s: unexpected pod %s", test.name, stream.pod.Name)
+			}
+			streams = append(streams, stream.pod.Name+"/"+stream.options.Container+":"+stream.prefix)
+		}
+		if strings.Join(streams, "|") != strings.Join(test.expected, "|") {
+			t.Errorf("%s: expected streams %q, got %q", test.name, test.expected, streams)
+		}
+	}
+}
+
+func TestLogsCopyStream(t *testing.T) {
+	logs := &fakeLogs{logs: map[string]string{"web/": "one\ntwo\nthree"}}
+	stream := logStream{pod: logsTestPod("web", api.PodRunning, "nginx"), options: &api.PodLogOptions{}}
+	tests := []struct {
+		name     string
+		prefix   string
+		lock     sync.Locker
+		expected string
+	}{
+		{
+			name:     "raw",
+			expected: "one\ntwo\nthree",
+		},
+		{
+			name:     "prefix",
+			prefix:   "[web] ",
+			expected: "[web] one\n[web] two\n[web] three",
+		},
+		{
+			name:     "lock",
+			lock:     &sync.Mutex{},
+			expected: "one\ntwo\nthree",
+		},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		o := LogsOptions{LogsForObject: logs.LogsForObject, Out: buf}
+		stream.prefix = test.prefix
+		if err := o.copyStream(context.Background(), stream, test.lock); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, buf.String())
+		}
+	}
+}
+
+// followPolls returns a ListPods listing the pods of polls in turn, and
+// cancelling the follow from the last one on.
+func followPolls(cancel func(), polls ...func() ([]*api.Pod, error)) (func() ([]*api.Pod, error), *int) {
+	count := 0
+	return func() ([]*api.Pod, error) {
+		count++
+		if count >= len(polls) {
+			cancel()
+			return polls[len(polls)-1]()
+		}
+		return polls[count-1]()
+	}, &count
+}
+
+func listed(pods ...*api.Pod) func() ([]*api.Pod, error) {
+	return func() ([]*api.Pod, error) { return pods, nil }
+}
+
+func restarted(pod *api.Pod, restarts int32) *api.Pod {
+	pod = pod.DeepCopy()
+	pod.Status.ContainerStatuses = []api.ContainerStatus{{Name: pod.Spec.Containers[0].Name, RestartCount: restarts}}
+	return pod
+}
+
+func TestLogsFollowSelected(t *testing.T) {
+	defer func(interval time.Duration) { podPollInterval = interval }(podPollInterval)
+	podPollInterval = 10 * time.Millisecond
+
+	web := logsTestPod("web", api.PodRunning, "nginx")
+	app := logsTestPod("app", api.PodRunning, "app")
+	tests := []struct {
+		name     string
+		polls    []func() ([]*api.Pod, error)
+		expected []string
+		// the streams requested, sorted
+		expectedRequests string
+		expectedErr      string
+	}{
+		{
+			name: "pending pod",
+			polls: []func() ([]*api.Pod, error){
+				listed(web, logsTestPod("app", api.PodPending, "app")),
+				listed(web, app),
+				listed(web, app),
+				listed(web, app),
+			},
+			expected:         []string{"[app/app] app started", "[web/nginx] web started"},
+			expectedRequests: "app/,web/",
+		},
+		{
+			name: "restarted pod",
+			polls: []func() ([]*api.Pod, error){
+				listed(web),
+				listed(web),
+				listed(restarted(web, 1)),
+				listed(restarted(web, 1)),
+			},
+			expected:         []string{"[web/nginx] web started", "[web/nginx] web started"},
+			expectedRequests: "web/,web/@since",
+		},
+		{
+			name: "listing error",
+			polls: []func() ([]*api.Pod, error){
+				func() ([]*api.Pod, error) { return nil, errors.New("connection refused") },
+				listed(web),
+				listed(web),
+			},
+			expected:         []string{"[web/nginx] web started"},
+			expectedRequests: "web/",
+			expectedErr:      "error: unable to list the pods matching \"run=web\": connection refused\n",
+		},
+	}
+	for _, test := range tests {
+		logs := &fakeLogs{logs: map[string]string{
+			"web/": "web started\n",
+			"app/": "app started\n",
+		}}
+		ctx, cancel := context.WithCancel(context.Background())
+		out, errOut := &logsBuffer{}, &logsBuffer{}
+		listPods, polls := followPolls(cancel, test.polls...)
+		o := LogsOptions{
+			Options:       &api.PodLogOptions{Follow: true},
+			LogsForObject: logs.LogsForObject,
+			Selector:      "run=web",
+			Prefix:        true,
+			Out:           out,
+			ErrOut:        errOut,
+			ListPods:      listPods,
+		}
+		if err := o.followSelected(ctx); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if *polls != len(test.polls) {
+			t.Errorf("%s: expected %d polls, got %d", test.name, len(test.polls), *polls)
+		}
+		// the streams are done once followSelected returns
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		sort.Strings(lines)
+		if strings.Join(lines, "|") != strings.Join(test.expected, "|") {
+			t.Errorf("%s: expected lines %q, got %q", test.name, test.expected, lines)
+		}
+		if requests := strings.Join(logs.Requests(), ","); requests != test.expectedRequests {
+			t.Errorf("%s: expected streams %s, got %s", test.name, test.expectedRequests, requests)
+		}
+		if errOut.String() != test.expectedErr {
+			t.Errorf("%s: expected error output %q, got %q", test.name, test.expectedErr, errOut.String())
+		}
+	}
+}
+
+func TestLogsFollowSelectedCancelsStreams(t *testing.T) {
+	defer func(interval time.Duration) { podPollInterval = interval }(podPollInterval)
+	podPollInterval = 10 * time.Millisecond
+
+	logs := &fakeLogs{
+		logs:     map[string]string{"web/": "web started\n"},
+		blocking: map[string]bool{"web/": true},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	out := &logsBuffer{}
+	o := LogsOptions{
+		Options:       &api.PodLogOptions{Follow: true},
+		LogsForObject: logs.LogsForObject,
+		Selector:      "run=web",
+		Out:           out,
+		ErrOut:        out,
+		ListPods: func() ([]*api.Pod, error) {
+			// the stream is open until the follow is cancelled
+			if strings.Contains(out.String(), "web started") {
+				cancel()
+			}
+			return []*api.Pod{logsTestPod("web", api.PodRunning, "nginx")}, nil
+		},
+	}
+	done := make(chan error)
+	go func() { done <- o.followSelected(ctx) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("the follow did not return once cancelled")
+	}
+	if open := logs.Open(); open != 0 {
+		t.Errorf("expected the streams to be closed, %d still open", open)
+	}
+	// the cancellation of the stream is not an error
+	if out.String() != "web started\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestLogsSelectorTail(t *testing.T) {
+	tests := []struct {
+		name     string
+		tail     string
+		expected int64
+	}{
+		{name: "default", expected: 10},
+		{name: "none", tail: "0", expected: 0},
+		{name: "tail", tail: "5", expected: 5},
+	}
+	for _, test := range tests {
+		f, _, _, _ := cmdtesting.NewAPIFactory()
+		cmd := NewCmdLogs(f, ioutil.Discard, ioutil.Discard)
+		cmd.Flags().Set("selector", "run=web")
+		cmd.Flags().Set("follow", "true")
+		if len(test.tail) > 0 {
+			cmd.Flags().Set("tail", test.tail)
+		}
+		o := &LogsOptions{}
+		if err := o.Complete(f, ioutil.Discard, ioutil.Discard, cmd, nil); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		tail := o.Options.(*api.PodLogOptions).TailLines
+		if tail == nil || *tail != test.expected {
+			t.Errorf("%s: expected tail %d, got %v", test.name, test.expected, tail)
+		}
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"io"
 	"strings"
 
+	restclient "github.com/hyperhq/client-go/rest"
 	"github.com/hyperhq/pi/pkg/pi"
+	"github.com/hyperhq/pi/pkg/pi/categories"
 	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
 	"github.com/hyperhq/pi/pkg/pi/cmd/util/openapi"
@@ -57,6 +59,7 @@ type GetOptions struct {
 
 	LabelSelector     string
 	FieldSelector     string
+	Zone              string
 	AllNamespaces     bool
 	Namespace         string
 	ExplicitNamespace bool
@@ -88,7 +91,10 @@ var (
 		pi get pods,services,secret
 
 		# List one or more resources by their type and names.
-		pi get services/nginx pods/nginx`))
+		pi get services/nginx pods/nginx
+
+		# List the volumes in a zone.
+		pi get volumes --zone=gcp-us-central1-b`))
 )
 
 const (
@@ -137,7 +143,8 @@ func NewCmdGet(f cmdutil.Factory, out io.Writer, errOut io.Writer) *cobra.Comman
 	//cmd.Flags().Int64Var(&options.ChunkSize, "chunk-size", 500, "Return large lists in chunks rather than all at once. Pass 0 to disable. This flag is beta and may change in the future.")
 	//cmd.Flags().BoolVar(&options.IgnoreNotFound, "ignore-not-found", options.IgnoreNotFound, "If the requested object does not exist the command will return exit code 0.")
 	cmd.Flags().StringVarP(&options.LabelSelector, "selector", "l", options.LabelSelector, "Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
-	//cmd.Flags().StringVar(&options.FieldSelector, "field-selector", options.FieldSelector, "Selector (field query) to filter on, supports '=', '==', and '!='.(e.g. --field-selector key1=value1,key2=value2). The server only supports a limited number of field queries per type.")
+	cmd.Flags().StringVar(&options.FieldSelector, "field-selector", options.FieldSelector, "Selector (field query) to filter on, supports '=', '==', and '!='.(e.g. --field-selector key1=value1,key2=value2). The server only supports a limited number of field queries per type.")
+	cmd.Flags().StringVar(&options.Zone, "zone", options.Zone, "The zone of the volumes to get.")
 	//cmd.Flags().BoolVar(&options.AllNamespaces, "all-namespaces", options.AllNamespaces, "If present, list the requested object(s) across all namespaces. Namespace in current context is ignored even if specified with --namespace.")
 	cmdutil.AddIncludeUninitializedFlag(cmd)
 	cmdutil.AddPrinterFlags(cmd)
@@ -146,10 +153,6 @@ func NewCmdGet(f cmdutil.Factory, out io.Writer, errOut io.Writer) *cobra.Comman
 	//cmd.Flags().StringSliceVarP(&options.LabelColumns, "label-columns", "L", options.LabelColumns, "Accepts a comma separated list of labels that are going to be presented as columns. Names are case-sensitive. You can also use multiple flag options like -L label1 -L label2...")
 	//cmd.Flags().BoolVar(&options.Export, "export", options.Export, "If true, use 'export' for the resources.  Exported resources are stripped of cluster-specific information.")
 	//cmdutil.AddFilenameOptionFlags(cmd, &options.FilenameOptions, "identifying the resource to get from a server.")
-
-	// get volume, fip
-	cmd.AddCommand(NewCmdGetVolume(f, out, errOut))
-	cmd.AddCommand(NewCmdGetFip(f, out, errOut))
 	return cmd
 }
 
@@ -170,6 +173,9 @@ func (options *GetOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args
 	//if options.AllNamespaces {
 	//	options.ExplicitNamespace = false
 	//}
+	if len(options.Zone) > 0 && (len(args) == 0 || !onlyVolumes(args[0])) {
+		return cmdutil.UsageErrorf(cmd, "--zone only applies to volumes")
+	}
 
 	switch {
 	case options.Watch || options.WatchOnly:
@@ -212,6 +218,7 @@ func (options *GetOptions) Run(f cmdutil.Factory, cmd *cobra.Command, args []str
 	if options.Watch || options.WatchOnly {
 		return options.watch(f, cmd, args)
 	}
+	args = expandGetAll(args)
 
 	r := f.NewBuilder().
 		Unstructured().
@@ -222,6 +229,7 @@ func (options *GetOptions) Run(f cmdutil.Factory, cmd *cobra.Command, args []str
 		ExportParam(options.Export).
 		RequestChunksOf(options.ChunkSize).
 		IncludeUninitialized(cmdutil.ShouldIncludeUninitialized(cmd, false)). // TODO: this needs to be better factored
+		TransformRequests(options.zoneParam()...).
 		ResourceTypeOrNameArgs(true, args...).
 		ContinueOnError().
 		Latest().
@@ -438,6 +446,7 @@ func (options *GetOptions) watch(f cmdutil.Factory, cmd *cobra.Command, args []s
 		ExportParam(options.Export).
 		RequestChunksOf(options.ChunkSize).
 		IncludeUninitialized(includeUninitialized).
+		TransformRequests(options.zoneParam()...).
 		ResourceTypeOrNameArgs(true, args...).
 		SingleResourceType().
 		Latest().
@@ -632,6 +641,49 @@ func (options *GetOptions) printGeneric(printer printers.ResourcePrinter, r *res
 	return utilerrors.Reduce(utilerrors.Flatten(utilerrors.NewAggregate(errs)))
 }
 
+// expandGetAll adds the Hyper.sh resources to the 'all' requested by get, they
+// are not part of the 'all' category that delete expands too.
+func expandGetAll(args []string) []string {
+	if len(args) == 0 || strings.Contains(args[0], "/") {
+		return args
+	}
+	types := strings.Split(args[0], ",")
+	for _, t := range types {
+		if t != "all" {
+			continue
+		}
+		for _, gr := range categories.HyperUserResources {
+			types = append(types, gr.String())
+		}
+		return append([]string{strings.Join(types, ",")}, args[1:]...)
+	}
+	return args
+}
+
+// zoneParam sets the zone parameter of the volume requests to --zone.
+func (options *GetOptions) zoneParam() []resource.RequestTransform {
+	if len(options.Zone) == 0 {
+		return nil
+	}
+	return []resource.RequestTransform{func(req *restclient.Request) {
+		req.Param("zone", options.Zone)
+	}}
+}
+
+// onlyVolumes returns whether the resource argument of get only names
+// volumes, e.g. volumes, vol or vol/vol1.
+func onlyVolumes(arg string) bool {
+	for _, t := range strings.Split(arg, ",") {
+		t = strings.SplitN(t, "/", 2)[0]
+		switch t {
+		case "vol", "volume", "volumes", "volumes.hyper.sh":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func addOpenAPIPrintColumnFlags(cmd *cobra.Command) {
 	//cmd.Flags().Bool(useOpenAPIPrintColumnFlagLabel, true, "If true, use x-kubernetes-print-column metadata (if present) from the OpenAPI schema for displaying a resource.")
 }
@@ -16,8 +16,10 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
 	"github.com/hyperhq/pi/pkg/pi"
 	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
@@ -29,6 +31,7 @@ import (
 	"github.com/spf13/cobra"
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -67,7 +70,19 @@ var (
 		pi run nginx --image=nginx -- <cmd> <arg1> ... <argN>
 
 		# Start the nginx container using a specified command and custom arguments.
-		pi run nginx --rm --image=nginx -- echo hello world`))
+		pi run nginx --rm --image=nginx -- echo hello world
+
+		# Start a mysql pod in zone gcp-us-central1-a, with volume mysql-data of 10GB mounted at /var/lib/mysql, creating it if missing.
+		pi run mysql --image=mysql --zone=gcp-us-central1-a --volume=mysql-data:/var/lib/mysql --volume-size=10
+
+		# Start a nginx pod of instance type m1, i.e. with a memory limit of 1Gi.
+		pi run nginx --image=nginx --size=m1
+
+		# Start a single instance of nginx listening on port 80, and a ClusterIP service for it.
+		pi run nginx --image=nginx --port=80 --expose
+
+		# Start a single instance of nginx, and a LoadBalancer service for it on a new fip.
+		pi run nginx --image=nginx --port=80 --expose --service-type=LoadBalancer --loadbalancerip=auto`))
 )
 
 type RunObject struct {
@@ -99,7 +114,7 @@ func NewCmdRun(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer) *co
 }
 
 func addRunFlags(cmd *cobra.Command) {
-	//cmdutil.AddDryRunFlag(cmd)
+	cmdutil.AddDryRunFlag(cmd)
 	//cmd.Flags().String("generator", "", i18n.T("The name of the API generator to use, see http://kubernetes.io/docs/user-guide/pi-conventions/#generators for a list."))
 	cmd.Flags().String("image", "", i18n.T("The image for the container to run."))
 	cmd.MarkFlagRequired("image")
@@ -109,7 +124,7 @@ func addRunFlags(cmd *cobra.Command) {
 	//cmd.Flags().String("overrides", "", i18n.T("An inline JSON override for the generated object. If this is non-empty, it is used to override the generated object. Requires that the object supply a valid apiVersion field."))
 	cmd.Flags().StringArray("env", []string{}, "Environment variables to set in the container")
 	//cmd.Flags().String("serviceaccount", "", "Service account to set in the pod spec")
-	//cmd.Flags().String("port", "", i18n.T("The port that this container exposes.  If --expose is true, this is also the port used by the service that is created."))
+	cmd.Flags().String("port", "", i18n.T("The port that this container exposes.  If --expose is true, this is also the port used by the service that is created."))
 	//cmd.Flags().Int("hostport", -1, "The host port mapping for the container port. To demonstrate a single-machine container.")
 	cmd.Flags().StringP("labels", "l", "", "Comma separated labels to apply to the pod(s). Will override previous values.")
 	cmd.Flags().BoolP("stdin", "i", false, "Keep stdin open on the container(s) in the pod, even if nothing is attached.")
@@ -120,8 +135,14 @@ func addRunFlags(cmd *cobra.Command) {
 	//cmd.Flags().Bool("command", false, "If true and extra arguments are present, use them as the 'command' field in the container, rather than the 'args' field which is the default.")
 	//cmd.Flags().String("requests", "", i18n.T("The resource requirement requests for this container.  For example, 'cpu=100m,memory=256Mi'.  Note that server side components may assign requests depending on the server configuration, such as limit ranges."))
 	cmd.Flags().String("limits", "", i18n.T("The resource requirement limits for this container.  For example, 'cpu=200m,memory=512Mi'.  Note that server side components may assign limits depending on the server configuration, such as limit ranges."))
-	//cmd.Flags().Bool("expose", false, "If true, a public, external service is created for the container(s) which are run")
-	//cmd.Flags().String("service-generator", "service/v2", i18n.T("The name of the generator to use for creating a service.  Only used if --expose is true"))
+	cmd.Flags().String("size", "", i18n.T("The instance type of the pod, one of "+strings.Join(hyperapi.InstanceTypeNames(), ", ")+". Sets the memory limit of the container to the memory of that type."))
+	cmd.Flags().StringArray("volume", []string{}, i18n.T("Volumes to mount in the container, as NAME:/mount/path[:ro]. The volumes must exist, unless --volume-size is given."))
+	cmd.Flags().Int("volume-size", 0, i18n.T("Size in GB of the volumes of --volume to create when they don't exist. The volumes created are deleted again if the pod can't be created."))
+	cmd.Flags().String("zone", "", i18n.T("The zone to run the pod in, e.g. gcp-us-central1-a. The volumes of --volume must be in that zone."))
+	cmd.Flags().Bool("expose", false, "If true, a service selecting the pod is created, on the port given with --port")
+	cmd.Flags().String("service-generator", "service/v2", i18n.T("The name of the generator to use for creating a service.  Only used if --expose is true"))
+	cmd.Flags().String("service-type", string(v1.ServiceTypeClusterIP), i18n.T("Type of the service created by --expose: ClusterIP or LoadBalancer."))
+	addLoadBalancerIPFlags(cmd)
 	//cmd.Flags().String("service-overrides", "", i18n.T("An inline JSON override for the generated service object. If this is non-empty, it is used to override the generated object. Requires that the object supply a valid apiVersion field.  Only used if --expose is true."))
 	//cmd.Flags().Bool("quiet", false, "If true, suppress prompt messages.")
 	//cmd.Flags().String("schedule", "", i18n.T("A schedule in the Cron format the job should be run with."))
@@ -159,6 +180,7 @@ func RunRun(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer, cmd *c
 	}
 
 	remove := cmdutil.GetFlagBool(cmd, "rm")
+	dryRun := cmdutil.GetDryRunFlag(cmd)
 
 	//if err := verifyImagePullPolicy(cmd); err != nil {
 	//	return err
@@ -235,27 +257,80 @@ func RunRun(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer, cmd *c
 	if err != nil {
 		return err
 	}
+	if dryRun && (len(command) > 0 || remove) {
+		return cmdutil.UsageErrorf(cmd, "--dry-run can't be used with attached containers options (COMMAND, --rm)")
+	}
+
+	// check the service flags before the pod is created
+	expose := cmdutil.GetFlagBool(cmd, "expose")
+	if expose {
+		if len(cmdutil.GetFlagString(cmd, "port")) == 0 {
+			return cmdutil.UsageErrorf(cmd, "--port must be set when exposing a service")
+		}
+		if len(cmdutil.GetFlagString(cmd, "service-generator")) == 0 {
+			return cmdutil.UsageErrorf(cmd, "No service generator specified")
+		}
+		serviceType, err := serviceTypeFromFlag(cmd, "service-type")
+		if err != nil {
+			return err
+		}
+		if serviceType == v1.ServiceTypeLoadBalancer && len(cmdutil.GetFlagString(cmd, "loadbalancerip")) == 0 {
+			return cmdutil.UsageErrorf(cmd, "--loadbalancerip must be set for a LoadBalancer service")
+		}
+	} else if cmd.Flags().Changed("loadbalancerip") {
+		return cmdutil.UsageErrorf(cmd, "--loadbalancerip can only be used with --expose")
+	}
 
 	params["env"] = cmdutil.GetFlagStringArray(cmd, "env")
+	params["volume"] = cmdutil.GetFlagStringArray(cmd, "volume")
 
 	podClient := clientset.Core()
 	podName := params["name"].(string)
 
+	createdVolumes := []string{}
+	if !dryRun {
+		volumes := cmdutil.GetFlagStringArray(cmd, "volume")
+		createdVolumes, err = ensureVolumes(f, cmdOut, volumes, cmdutil.GetFlagString(cmd, "zone"), cmdutil.GetFlagInt(cmd, "volume-size"))
+		if err != nil {
+			return err
+		}
+	}
+
 	var runObjectMap = map[string]*RunObject{}
 	runObject, err := createGeneratedObject(f, cmd, generator, names, params, "", namespace)
 	if err != nil {
+		// the volumes created for the pod are of no use without it
+		deleteVolumes(f, cmdOut, createdVolumes)
 		return err
 	}
 	runObjectMap[generatorName] = runObject
 
+	if len(command) == 0 {
+		f.PrintSuccess(runObject.Mapper, false, cmdOut, runObject.Mapping.Resource, args[0], dryRun, "created")
+	}
+
+	if expose {
+		serviceGenerator := cmdutil.GetFlagString(cmd, "service-generator")
+		serviceRunObject, err := exposePod(f, cmd, args, serviceGenerator, params, namespace, cmdOut)
+		if err != nil {
+			// don't leave a pod nobody can reach behind, nor its volumes
+			if !dryRun {
+				deletePod(podClient, cmdOut, cmdErr, namespace, podName)
+				deleteVolumes(f, cmdOut, createdVolumes)
+			}
+			return err
+		}
+		runObjectMap[serviceGenerator] = serviceRunObject
+	}
+
 	if len(command) > 0 {
-		pod, err := podClient.Pods("default").Get(podName, metav1.GetOptions{})
+		pod, err := podClient.Pods(namespace).Get(podName, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
 
 		if remove {
-			defer deletePod(pod.Name, podClient)
+			defer deletePod(podClient, cmdOut, cmdErr, namespace, pod.Name)
 		}
 
 		if pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed {
@@ -265,7 +340,7 @@ func RunRun(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer, cmd *c
 			if pod.Status.Phase == api.PodPending {
 				glog.V(4).Infof("%v/20 waiting for pod start", i)
 				time.Sleep(time.Duration(1 * time.Second))
-				pod, err = podClient.Pods("default").Get(podName, metav1.GetOptions{})
+				pod, err = podClient.Pods(namespace).Get(podName, metav1.GetOptions{})
 			} else {
 				glog.V(4).Infof("pod started:%v", string(pod.Status.Phase))
 				break
@@ -277,7 +352,7 @@ func RunRun(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer, cmd *c
 				Out:       cmdOut,
 				Err:       cmdErr,
 				PodName:   pod.Name,
-				Namespace: "default",
+				Namespace: namespace,
 				Quiet:     false,
 				TTY:       tty,
 				Stdin:     interactive,
@@ -286,22 +361,179 @@ func RunRun(f cmdutil.Factory, cmdIn io.Reader, cmdOut, cmdErr io.Writer, cmd *c
 			Command:  command,
 		}
 		options.PodClient = podClient
-		cmdutil.CheckErr(options.RunHyper(f))
-	} else {
-		f.PrintSuccess(runObject.Mapper, false, cmdOut, runObject.Mapping.Resource, args[0], false, "created")
+		if err := options.RunHyper(f); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func deletePod(podName string, podClient coreclient.CoreInterface) {
+// ensureVolumes checks that the Hyper volumes of the --volume flags exist in
+// zone, and creates the missing ones of size GB. A size of 0 means they
+// must exist. It returns the names of the volumes it created, the ones created
+// before an error are deleted again.
+func ensureVolumes(f cmdutil.Factory, out io.Writer, volumes []string, zone string, size int) (created []string, err error) {
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("volume size should be >=1 (GB)")
+	}
+	mapper, _ := f.Object()
+	mapping, err := mapper.RESTMapping(hyperapi.Kind("Volume"), hyperapi.SchemeGroupVersionV1.Version)
+	if err != nil {
+		return nil, err
+	}
+	client, err := f.ClientForMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			deleteVolumes(f, out, created)
+			created = nil
+		}
+	}()
+	for _, spec := range volumes {
+		name, _, _, err := pi.ParseVolumeMount(spec)
+		if err != nil {
+			return created, err
+		}
+		err = client.Get().Resource(mapping.Resource).Name(name).Param("zone", zone).Do().Error()
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return created, err
+		}
+		if size == 0 {
+			return created, fmt.Errorf("volume %q not found, create it with `pi create volume` or give its size with --volume-size", name)
+		}
+		volume := &hyperapi.Volume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       hyperapi.VolumeSpec{Size: size, Zone: zone},
+		}
+		if err := client.Post().Resource(mapping.Resource).Body(volume).Do().Error(); err != nil {
+			return created, err
+		}
+		created = append(created, name)
+		f.PrintSuccess(mapper, false, out, mapping.Resource, name, false, "created")
+	}
+	return created, nil
+}
+
+// deleteVolumes deletes the volumes created by ensureVolumes, a volume that
+// can't be deleted is reported and left behind.
+func deleteVolumes(f cmdutil.Factory, out io.Writer, volumes []string) {
+	if len(volumes) == 0 {
+		return
+	}
+	mapper, _ := f.Object()
+	mapping, err := mapper.RESTMapping(hyperapi.Kind("Volume"), hyperapi.SchemeGroupVersionV1.Version)
+	if err != nil {
+		fmt.Fprintf(out, "failed to delete volumes %v, error:%v\n", volumes, err)
+		return
+	}
+	client, err := f.ClientForMapping(mapping)
+	if err != nil {
+		fmt.Fprintf(out, "failed to delete volumes %v, error:%v\n", volumes, err)
+		return
+	}
+	for _, name := range volumes {
+		if err := client.Delete().Resource(mapping.Resource).Name(name).Do().Error(); err != nil {
+			fmt.Fprintf(out, "failed to delete volume \"%v\", error:%v\n", name, err)
+			continue
+		}
+		f.PrintSuccess(mapper, false, out, mapping.Resource, name, false, "deleted")
+	}
+}
+
+// exposePod creates the service of a pod started with --expose, allocating its
+// fip first for --loadbalancerip=auto. The fip is released again when the
+// service can't be created.
+func exposePod(f cmdutil.Factory, cmd *cobra.Command, args []string, serviceGenerator string, params map[string]interface{}, namespace string, out io.Writer) (*RunObject, error) {
+	if cmdutil.GetDryRunFlag(cmd) {
+		return generateService(f, cmd, args, serviceGenerator, params, namespace, out)
+	}
+	loadBalancerIP := cmdutil.GetFlagString(cmd, "loadbalancerip")
+	finish, err := allocateLoadBalancerIP(f, cmd, out, &loadBalancerIP)
+	if err != nil {
+		return nil, err
+	}
+	serviceParams := map[string]interface{}{}
+	for key, value := range params {
+		serviceParams[key] = value
+	}
+	serviceParams["load-balancer-ip"] = loadBalancerIP
+	runObject, err := generateService(f, cmd, args, serviceGenerator, serviceParams, namespace, out)
+	finish(err)
+	if err != nil {
+		return nil, err
+	}
+	return runObject, nil
+}
+
+// generateService creates the service selecting the pod of params, on the
+// port given with --port.
+func generateService(f cmdutil.Factory, cmd *cobra.Command, args []string, serviceGenerator string, paramsIn map[string]interface{}, namespace string, out io.Writer) (*RunObject, error) {
+	generators := f.Generators("expose")
+	generator, found := generators[serviceGenerator]
+	if !found {
+		return nil, fmt.Errorf("missing service generator: %s", serviceGenerator)
+	}
+	names := generator.ParamNames()
+
+	port := cmdutil.GetFlagString(cmd, "port")
+	if len(port) == 0 {
+		return nil, fmt.Errorf("--port must be set when exposing a service")
+	}
+
+	params := map[string]interface{}{}
+	for key, value := range paramsIn {
+		_, isString := value.(string)
+		if isString {
+			params[key] = value
+		}
+	}
+
+	name, found := params["name"]
+	if !found || len(name.(string)) == 0 {
+		return nil, fmt.Errorf("name is a required parameter")
+	}
+	selector, found := params["labels"]
+	if !found || len(selector.(string)) == 0 {
+		selector = fmt.Sprintf("run=%s", name.(string))
+	}
+	params["selector"] = selector
+
+	if defaultName, found := params["default-name"]; !found || len(defaultName.(string)) == 0 {
+		params["default-name"] = name
+	}
+
+	serviceType, err := serviceTypeFromFlag(cmd, "service-type")
+	if err != nil {
+		return nil, err
+	}
+	params["type"] = string(serviceType)
+
+	runObject, err := createGeneratedObject(f, cmd, generator, names, params, "", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	f.PrintSuccess(runObject.Mapper, false, out, runObject.Mapping.Resource, name.(string), cmdutil.GetDryRunFlag(cmd), "created")
+	return runObject, nil
+}
+
+func deletePod(podClient coreclient.CoreInterface, out, errOut io.Writer, namespace, podName string) {
 	glog.V(4).Infof("deletel pod %v due to --rm", podName)
 	var gracePeriodSeconds int64 = 0
-	err := podClient.Pods("default").Delete(podName, &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriodSeconds})
+	err := podClient.Pods(namespace).Delete(podName, &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriodSeconds})
 	if err != nil {
-		fmt.Printf("failed to delete pod \"%v\", error:%v\n", podName, err)
+		fmt.Fprintf(errOut, "failed to delete pod \"%v\", error:%v\n", podName, err)
 	} else {
-		fmt.Printf("pod \"%v\" deleted\n", podName)
+		fmt.Fprintf(out, "pod \"%v\" deleted\n", podName)
 	}
 }
 
@@ -397,7 +629,7 @@ func getRestartPolicy(cmd *cobra.Command, interactive bool) (api.RestartPolicy,
 	case api.RestartPolicyNever:
 		return api.RestartPolicyNever, nil
 	}
-	return "", cmdutil.UsageErrorf(cmd, "invalid restart policy: %s")
+	return "", cmdutil.UsageErrorf(cmd, "invalid restart policy: %s", restart)
 }
 
 func verifyImagePullPolicy(cmd *cobra.Command) error {
@@ -458,9 +690,11 @@ func createGeneratedObject(f cmdutil.Factory, cmd *cobra.Command, generator pi.G
 		return nil, err
 	}
 
-	obj, err = resource.NewHelper(client, mapping).Create(namespace, false, info.Object)
-	if err != nil {
-		return nil, err
+	if !cmdutil.GetDryRunFlag(cmd) {
+		obj, err = resource.NewHelper(client, mapping).Create(namespace, false, info.Object)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &RunObject{
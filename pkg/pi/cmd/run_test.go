@@ -28,13 +28,16 @@ import (
 
 	restclient "github.com/hyperhq/client-go/rest"
 	"github.com/hyperhq/client-go/rest/fake"
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
 	cmdtesting "github.com/hyperhq/pi/pkg/pi/cmd/testing"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/pi/resource"
 	"github.com/hyperhq/pi/pkg/pi/scheme"
 	"github.com/hyperhq/pi/pkg/pi/util/i18n"
 
 	"github.com/spf13/cobra"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -121,18 +124,18 @@ func TestGetEnv(t *testing.T) {
 }
 
 func TestRunArgsFollowDashRules(t *testing.T) {
-	one := int32(1)
-	rc := &v1.ReplicationController{
-		ObjectMeta: metav1.ObjectMeta{Name: "rc1", Namespace: "test", ResourceVersion: "18"},
-		Spec: v1.ReplicationControllerSpec{
-			Replicas: &one,
-		},
+	// the pod has completed, the command is not run in it
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "test", ResourceVersion: "18"},
+		Status:     v1.PodStatus{Phase: v1.PodSucceeded},
 	}
+	completed := "cannot exec into a container in a completed pod"
 
 	tests := []struct {
 		args          []string
 		argsLenAtDash int
 		expectError   bool
+		expectedErr   string
 		name          string
 	}{
 		{
@@ -150,13 +153,13 @@ func TestRunArgsFollowDashRules(t *testing.T) {
 		{
 			args:          []string{"foo", "sleep"},
 			argsLenAtDash: -1,
-			expectError:   false,
+			expectedErr:   completed,
 			name:          "cmd no dash",
 		},
 		{
 			args:          []string{"foo", "sleep"},
 			argsLenAtDash: 1,
-			expectError:   false,
+			expectedErr:   completed,
 			name:          "cmd has dash",
 		},
 		{
@@ -172,8 +175,8 @@ func TestRunArgsFollowDashRules(t *testing.T) {
 			GroupVersion:         schema.GroupVersion{Version: "v1"},
 			NegotiatedSerializer: ns,
 			Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
-				if req.URL.Path == "/namespaces/test/replicationcontrollers" {
-					return &http.Response{StatusCode: 201, Header: defaultHeader(), Body: objBody(codec, rc)}, nil
+				if strings.Contains(req.URL.Path, "/namespaces/test/pods") {
+					return &http.Response{StatusCode: 201, Header: defaultHeader(), Body: objBody(codec, pod)}, nil
 				}
 				return &http.Response{
 					StatusCode: http.StatusOK,
@@ -186,11 +189,15 @@ func TestRunArgsFollowDashRules(t *testing.T) {
 		cmd := NewCmdRun(f, os.Stdin, os.Stdout, os.Stderr)
 		cmd.Flags().Set("image", "nginx")
 		cmd.Flags().Set("generator", "run/v1")
-		err := RunRun(f, os.Stdin, os.Stdout, os.Stderr, cmd, test.args, test.argsLenAtDash)
-		if test.expectError && err == nil {
+		err := RunRun(f, os.Stdin, ioutil.Discard, os.Stderr, cmd, test.args, test.argsLenAtDash)
+		switch {
+		case test.expectError && err == nil:
 			t.Errorf("unexpected non-error (%s)", test.name)
-		}
-		if !test.expectError && err != nil {
+		case len(test.expectedErr) > 0:
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("expected error %q, got %v (%s)", test.expectedErr, err, test.name)
+			}
+		case !test.expectError && err != nil:
 			t.Errorf("unexpected error: %v (%s)", err, test.name)
 		}
 	}
@@ -430,11 +437,12 @@ func TestRunValidations(t *testing.T) {
 		},
 	}
 	for _, test := range tests {
-		f, tf, codec, ns := cmdtesting.NewTestFactory()
+		f, tf, codec, ns := cmdtesting.NewAPIFactory()
 		tf.Printer = &testPrinter{}
 		tf.Client = &fake.RESTClient{
+			GroupVersion:         schema.GroupVersion{Version: "v1"},
 			NegotiatedSerializer: ns,
-			Resp:                 &http.Response{StatusCode: 200, Header: defaultHeader(), Body: objBody(codec, cmdtesting.NewInternalType("", "", ""))},
+			Resp:                 &http.Response{StatusCode: 200, Header: defaultHeader(), Body: objBody(codec, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}})},
 		}
 		tf.Namespace = "test"
 		tf.ClientConfig = defaultClientConfig()
@@ -455,3 +463,90 @@ func TestRunValidations(t *testing.T) {
 	}
 
 }
+
+func TestRunDeletesCreatedVolumesOnFailure(t *testing.T) {
+	tests := []struct {
+		name             string
+		podStatus        int
+		serviceStatus    int
+		expectedRequests []string
+		expectedOutput   string
+	}{
+		{
+			name:      "pod created",
+			podStatus: http.StatusCreated,
+			expectedRequests: []string{
+				"GET /volumes/existing", "GET /volumes/missing", "POST /volumes", "POST /namespaces/test/pods",
+			},
+		},
+		{
+			name:      "pod failed",
+			podStatus: http.StatusInternalServerError,
+			expectedRequests: []string{
+				"GET /volumes/existing", "GET /volumes/missing", "POST /volumes", "POST /namespaces/test/pods", "DELETE /volumes/missing",
+			},
+		},
+		{
+			name:          "service failed",
+			podStatus:     http.StatusCreated,
+			serviceStatus: http.StatusInternalServerError,
+			expectedRequests: []string{
+				"GET /volumes/existing", "GET /volumes/missing", "POST /volumes", "POST /namespaces/test/pods", "POST /namespaces/test/services",
+				"DELETE /api/v1/namespaces/test/pods/mysql", "DELETE /volumes/missing",
+			},
+			expectedOutput: "pod \"mysql\" deleted\n",
+		},
+	}
+	for _, test := range tests {
+		f, tf, codec, ns := cmdtesting.NewAPIFactory()
+		tf.Printer = &testPrinter{}
+		requests := []string{}
+		client := fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			switch p, m := req.URL.Path, req.Method; {
+			case p == "/volumes/missing" && m == http.MethodGet:
+				return &http.Response{StatusCode: http.StatusNotFound, Header: defaultHeader(), Body: ioutil.NopCloser(bytes.NewBufferString("{}"))}, nil
+			case p == "/namespaces/test/pods" && m == http.MethodPost:
+				pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "test"}}
+				return &http.Response{StatusCode: test.podStatus, Header: defaultHeader(), Body: objBody(codec, pod)}, nil
+			case p == "/namespaces/test/services" && m == http.MethodPost:
+				return &http.Response{StatusCode: test.serviceStatus, Header: defaultHeader(), Body: ioutil.NopCloser(bytes.NewBufferString("{}"))}, nil
+			default:
+				return &http.Response{StatusCode: http.StatusOK, Header: defaultHeader(), Body: ioutil.NopCloser(bytes.NewBufferString("{}"))}, nil
+			}
+		})
+		tf.Client = &fake.RESTClient{GroupVersion: schema.GroupVersion{Version: "v1"}, NegotiatedSerializer: ns, Client: client}
+		tf.ClientForMappingFunc = func(m *meta.RESTMapping) (resource.RESTClient, error) {
+			if m.GroupVersionKind.Group == hyperapi.GroupName {
+				return &fake.RESTClient{GroupVersion: hyperapi.SchemeGroupVersionV1, NegotiatedSerializer: scheme.Codecs, Client: client}, nil
+			}
+			return tf.Client, nil
+		}
+		tf.Namespace = "test"
+		tf.ClientConfig = defaultClientConfig()
+		outBuf := bytes.NewBuffer([]byte{})
+		errBuf := bytes.NewBuffer([]byte{})
+
+		cmd := NewCmdRun(f, os.Stdin, outBuf, errBuf)
+		cmd.Flags().Set("image", "mysql")
+		cmd.Flags().Set("generator", "run-pod/v1")
+		cmd.Flags().Set("zone", "gcp-us-central1-a")
+		cmd.Flags().Set("volume", "existing:/var/lib/mysql")
+		cmd.Flags().Set("volume", "missing:/backup:ro")
+		cmd.Flags().Set("volume-size", "10")
+		if test.serviceStatus != 0 {
+			cmd.Flags().Set("expose", "true")
+			cmd.Flags().Set("port", "3306")
+		}
+		err := RunRun(f, os.Stdin, outBuf, errBuf, cmd, []string{"mysql"}, -1)
+		if (err != nil) != (test.podStatus != http.StatusCreated || test.serviceStatus != 0) {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(requests, test.expectedRequests) {
+			t.Errorf("%s: expected requests %v, got %v", test.name, test.expectedRequests, requests)
+		}
+		if !strings.Contains(outBuf.String(), test.expectedOutput) {
+			t.Errorf("%s: expected %q in the output, got %q", test.name, test.expectedOutput, outBuf.String())
+		}
+	}
+}
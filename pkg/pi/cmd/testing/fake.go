@@ -27,6 +27,7 @@ import (
 	"github.com/hyperhq/client-go/kubernetes"
 	restclient "github.com/hyperhq/client-go/rest"
 	"github.com/hyperhq/client-go/rest/fake"
+	clientcmdapi "github.com/hyperhq/client-go/tools/clientcmd/api"
 	"github.com/hyperhq/pi/pkg/pi"
 	"github.com/hyperhq/pi/pkg/pi/categories"
 	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
@@ -326,6 +327,10 @@ func (f *FakeFactory) BareClientConfig() (*restclient.Config, error) {
 	return f.tf.ClientConfig, f.tf.Err
 }
 
+func (f *FakeFactory) RawConfig() (clientcmdapi.Config, error) {
+	return *clientcmdapi.NewConfig(), f.tf.Err
+}
+
 func (f *FakeFactory) ClientForMapping(mapping *meta.RESTMapping) (resource.RESTClient, error) {
 	if f.tf.ClientForMappingFunc != nil {
 		return f.tf.ClientForMappingFunc(mapping)
@@ -0,0 +1,197 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hyperhq/pi/pkg/hyper"
+	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/pi/metricsutil"
+	"github.com/hyperhq/pi/pkg/pi/util/i18n"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	coreclient "k8s.io/kubernetes/pkg/client/clientset_generated/internalclientset/typed/core/internalversion"
+)
+
+// TopPodOptions contains all the options for running the top-pod cli command.
+type TopPodOptions struct {
+	ResourceName    string
+	Namespace       string
+	Selector        string
+	PrintContainers bool
+	Watch           bool
+	Interval        time.Duration
+
+	PodClient     coreclient.PodsGetter
+	MetricsClient *metricsutil.HyperMetricsClient
+	Printer       *metricsutil.TopCmdPrinter
+	Out           io.Writer
+}
+
+var (
+	topPodLong = templates.LongDesc(i18n.T(`
+		Display Resource (CPU/Memory) usage of pods.
+
+		The CPU and memory usage of the running containers is read from the Hyper
+		stats API, and printed against the limits of the pod. The memory of a pod
+		is the one of its instance type, its CPU is only known when all of its
+		containers have CPU limits.
+
+		The memory usage is the working set, without the page cache. The usage of
+		the containers whose stats can't be read, and of their pods, is <unknown>.`))
+
+	topPodExample = templates.Examples(i18n.T(`
+		# Show metrics for all pods in the default namespace
+		pi top pod
+
+		# Show metrics for a given pod and its containers
+		pi top pod POD_NAME --containers
+
+		# Show metrics for the pods defined by label name=myLabel
+		pi top pod -l name=myLabel
+
+		# Refresh the metrics of the pods labeled app=web every 10 seconds
+		pi top pod -l app=web --watch --interval=10s`))
+)
+
+func NewCmdTopPod(f cmdutil.Factory, out, errOut io.Writer) *cobra.Command {
+	options := &TopPodOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "pod [NAME | -l label]",
+		Short:   i18n.T("Display Resource (CPU/Memory) usage of pods"),
+		Long:    topPodLong,
+		Example: topPodExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(options.Complete(f, cmd, args, out))
+			cmdutil.CheckErr(options.Validate())
+			cmdutil.CheckErr(options.RunTopPod())
+		},
+		Aliases: []string{"pods", "po"},
+	}
+	cmd.Flags().StringVarP(&options.Selector, "selector", "l", "", "Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
+	cmd.Flags().BoolVar(&options.PrintContainers, "containers", false, "If present, print usage of containers within a pod.")
+	cmd.Flags().BoolVarP(&options.Watch, "watch", "w", false, "After printing the usage, print it again every interval until interrupted.")
+	cmd.Flags().DurationVar(&options.Interval, "interval", 5*time.Second, "The time between two refreshes of the usage with --watch.")
+	return cmd
+}
+
+func (o *TopPodOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string, out io.Writer) error {
+	var err error
+	if len(args) == 1 {
+		o.ResourceName = args[0]
+	} else if len(args) > 1 {
+		return cmdutil.UsageErrorf(cmd, "%s", cmd.Use)
+	}
+
+	o.Namespace, _, err = f.DefaultNamespace()
+	if err != nil {
+		return err
+	}
+	clientset, err := f.ClientSet()
+	if err != nil {
+		return err
+	}
+	o.PodClient = clientset.Core()
+
+	cfg, err := f.ClientConfig()
+	if err != nil {
+		return err
+	}
+	cli, err := hyper.NewHyperCli(cfg.Host, cfg, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	o.MetricsClient = metricsutil.NewHyperMetricsClient(cli)
+	o.Printer = metricsutil.NewTopCmdPrinter(out)
+	o.Out = out
+	return nil
+}
+
+func (o *TopPodOptions) Validate() error {
+	if len(o.ResourceName) > 0 && len(o.Selector) > 0 {
+		return errors.New("only one of NAME or --selector can be provided")
+	}
+	if len(o.Selector) > 0 {
+		if _, err := labels.Parse(o.Selector); err != nil {
+			return err
+		}
+	}
+	if o.Watch && o.Interval <= 0 {
+		return errors.New("--interval must be greater than 0")
+	}
+	return nil
+}
+
+func (o *TopPodOptions) RunTopPod() error {
+	if err := o.printPodMetrics(); err != nil || !o.Watch {
+		return err
+	}
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		fmt.Fprintln(o.Out)
+		if err := o.printPodMetrics(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *TopPodOptions) printPodMetrics() error {
+	pods, err := o.getPods()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := hyper.InterruptibleContext()
+	defer cancel()
+	metrics, err := o.MetricsClient.GetPodMetrics(ctx, pods)
+	if err != nil {
+		return err
+	}
+	if len(metrics) == 0 {
+		if len(o.ResourceName) > 0 {
+			return fmt.Errorf("pod %s has no running containers", o.ResourceName)
+		}
+		return errors.New("no running pods found")
+	}
+	return o.Printer.PrintPodMetrics(metrics, o.PrintContainers, false, metricsutil.PodLimits(pods))
+}
+
+func (o *TopPodOptions) getPods() ([]api.Pod, error) {
+	pods := o.PodClient.Pods(o.Namespace)
+	if len(o.ResourceName) > 0 {
+		pod, err := pods.Get(o.ResourceName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []api.Pod{*pod}, nil
+	}
+	list, err := pods.List(metav1.ListOptions{LabelSelector: o.Selector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/hyperhq/client-go/kubernetes"
 	restclient "github.com/hyperhq/client-go/rest"
 	"github.com/hyperhq/client-go/tools/clientcmd"
+	clientcmdapi "github.com/hyperhq/client-go/tools/clientcmd/api"
 	"github.com/hyperhq/pi/pkg/pi"
 	"github.com/hyperhq/pi/pkg/pi/categories"
 	"github.com/hyperhq/pi/pkg/pi/cmd/util/openapi"
@@ -35,6 +36,9 @@ import (
 	"github.com/hyperhq/pi/pkg/pi/validation"
 	"github.com/hyperhq/pi/pkg/printers"
 
+	// install the hyper.sh group, which holds volumes and fips
+	_ "github.com/hyperhq/pi/pkg/apis/hyper/install"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/api/core/v1"
@@ -101,6 +105,9 @@ type ClientAccessFactory interface {
 	// BareClientConfig returns a client.Config that has NOT been negotiated. It's
 	// just directions to the server. People use this to build RESTMappers on top of
 	BareClientConfig() (*restclient.Config, error)
+	// RawConfig returns the pi config the client configs are made from, with
+	// the current context it names, not overridden by the flags.
+	RawConfig() (clientcmdapi.Config, error)
 
 	// TODO remove.  This should be rolled into `ClientSet`
 	ClientSetForVersion(requiredVersion *schema.GroupVersion) (internalclientset.Interface, error)
@@ -33,7 +33,9 @@ import (
 	"github.com/hyperhq/client-go/kubernetes"
 	restclient "github.com/hyperhq/client-go/rest"
 	"github.com/hyperhq/client-go/tools/clientcmd"
+	clientcmdapi "github.com/hyperhq/client-go/tools/clientcmd/api"
 	"github.com/hyperhq/client-go/util/homedir"
+	"github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi"
 	"github.com/hyperhq/pi/pkg/pi/resource"
 	"github.com/hyperhq/pi/pkg/printers"
@@ -179,7 +181,100 @@ func DefaultClientConfig(flags *pflag.FlagSet) clientcmd.ClientConfig {
 	clientcmd.BindOverrideFlags(overrides, flags, flagNames)
 	clientConfig := clientcmd.NewInteractiveDeferredLoadingClientConfig(loadingRules, overrides, os.Stdin)
 
-	return clientConfig
+	config := &hyperClientConfig{loader: clientConfig, overrides: overrides}
+	flags.StringVar(&config.tracePath, "trace-http", "", "Record the requests to the Hyper API and their responses to this file, without the credentials and secrets. A HAR log if the file ends with .har, one JSON entry per line otherwise.")
+	flags.StringVar(&config.signTimeOffset, "sign-time-offset", "", "Sign the requests at the local time plus this duration (e.g. -5m30s), or 'auto' to sign them at the time of the server when a request is rejected because of the skew of the local clock.")
+	return config
+}
+
+// hyperClientConfig loads the configs of the clients of the Hyper API. Their
+// requests are retried when idempotent, cancelled on interrupt, traced to
+// tracePath if set, sent through the proxy of the cluster, and signed again
+// on the skew of the local clock.
+type hyperClientConfig struct {
+	loader         clientcmd.ClientConfig
+	overrides      *clientcmd.ConfigOverrides
+	tracePath      string
+	signTimeOffset string
+}
+
+func (c *hyperClientConfig) RawConfig() (clientcmdapi.Config, error) {
+	return c.loader.RawConfig()
+}
+
+func (c *hyperClientConfig) Namespace() (string, bool, error) {
+	return c.loader.Namespace()
+}
+
+func (c *hyperClientConfig) ConfigAccess() clientcmd.ConfigAccess {
+	return c.loader.ConfigAccess()
+}
+
+func (c *hyperClientConfig) ClientConfig() (*restclient.Config, error) {
+	config, err := c.loader.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(c.tracePath) > 0 {
+		if err := hyper.StartTrace(c.tracePath); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.signTimeOffset) > 0 {
+		if err := hyper.SetSignTimeOffset(c.signTimeOffset); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyCluster(config); err != nil {
+		return nil, err
+	}
+	hyper.WarnInsecure(config)
+	config.WrapTransport = hyper.ChainWrapTransport(config)
+	return config, nil
+}
+
+// applyCluster applies the settings of the cluster of the config clientcmd
+// leaves out: the verification of the certificate of the server, which it
+// skips for every cluster with credentials, and the proxy of the cluster.
+func (c *hyperClientConfig) applyCluster(config *restclient.Config) error {
+	rawConfig, err := c.loader.RawConfig()
+	if err != nil {
+		return err
+	}
+	config.Insecure = c.overrides != nil && c.overrides.ClusterInfo.InsecureSkipTLSVerify
+	cluster := currentCluster(rawConfig, c.overrides)
+	if cluster == nil {
+		return nil
+	}
+	config.Insecure = config.Insecure || cluster.InsecureSkipTLSVerify
+
+	proxyURL, err := hyper.ClusterProxyURL(cluster)
+	if err != nil || len(proxyURL) == 0 {
+		return err
+	}
+	u, err := hyper.ParseProxyURL(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy-url of the cluster: %v", err)
+	}
+	hyper.SetClusterProxy(u)
+	return nil
+}
+
+// currentCluster returns the cluster of the context of config, after the
+// --context and --cluster flags of overrides, nil when it does not exist.
+func currentCluster(config clientcmdapi.Config, overrides *clientcmd.ConfigOverrides) *clientcmdapi.Cluster {
+	contextName := config.CurrentContext
+	if overrides != nil && len(overrides.CurrentContext) > 0 {
+		contextName = overrides.CurrentContext
+	}
+	clusterName := ""
+	if context, found := config.Contexts[contextName]; found {
+		clusterName = context.Cluster
+	}
+	if overrides != nil && len(overrides.Context.Cluster) > 0 {
+		clusterName = overrides.Context.Cluster
+	}
+	return config.Clusters[clusterName]
 }
 
 func (f *ring0Factory) DiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
@@ -205,6 +300,10 @@ func (f *ring0Factory) BareClientConfig() (*restclient.Config, error) {
 	return f.clientConfig.ClientConfig()
 }
 
+func (f *ring0Factory) RawConfig() (clientcmdapi.Config, error) {
+	return f.clientConfig.RawConfig()
+}
+
 func (f *ring0Factory) ClientConfigForVersion(requiredVersion *schema.GroupVersion) (*restclient.Config, error) {
 	return f.clientCache.ClientConfigForVersion(nil)
 }
@@ -548,6 +647,11 @@ func DefaultGenerators(cmdName string) map[string]pi.Generator {
 		generator = map[string]pi.Generator{
 			RunPodV1GeneratorName: pi.BasicPod{},
 		}
+	case "expose":
+		generator = map[string]pi.Generator{
+			ServiceV1GeneratorName: pi.ServiceGeneratorV1{},
+			ServiceV2GeneratorName: pi.ServiceGeneratorV2{},
+		}
 	}
 	return generator
 }
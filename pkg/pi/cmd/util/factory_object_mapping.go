@@ -30,6 +30,8 @@ import (
 	"github.com/hyperhq/client-go/discovery"
 	"github.com/hyperhq/client-go/dynamic"
 	restclient "github.com/hyperhq/client-go/rest"
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+	"github.com/hyperhq/pi/pkg/hyper"
 	"github.com/hyperhq/pi/pkg/pi"
 	"github.com/hyperhq/pi/pkg/pi/categories"
 	"github.com/hyperhq/pi/pkg/pi/cmd/util/openapi"
@@ -96,7 +98,11 @@ func (f *ring1Factory) objectLoader() (meta.RESTMapper, runtime.ObjectTyper, err
 
 	// allow conversion between typed and unstructured objects
 	interfaces := meta.InterfacesForUnstructuredConversion(legacyscheme.Registry.InterfacesFor)
-	mapper := discovery.NewDeferredDiscoveryRESTMapper(discoveryClient, meta.VersionInterfacesFunc(interfaces))
+	// volumes and fips are not published through discovery, so their mappings come from the registry
+	mapper := meta.MultiRESTMapper{
+		discovery.NewDeferredDiscoveryRESTMapper(discoveryClient, meta.VersionInterfacesFunc(interfaces)),
+		legacyscheme.Registry.GroupOrDie(hyperapi.GroupName).RESTMapper,
+	}
 	// TODO: should this also indicate it recognizes typed objects?
 	typer := discovery.NewUnstructuredObjectTyper(groupResources, legacyscheme.Scheme)
 	expander := NewShortcutExpander(mapper, discoveryClient)
@@ -143,6 +149,9 @@ func (f *ring1Factory) ClientForMapping(mapping *meta.RESTMapping) (resource.RES
 	}
 	gv := gvk.GroupVersion()
 	cfg.GroupVersion = &gv
+	if gvk.Group == hyperapi.GroupName {
+		cfg.WrapTransport = hyper.WrapResourceTransport(cfg)
+	}
 	return restclient.RESTClientFor(cfg)
 }
 
@@ -161,10 +170,29 @@ func (f *ring1Factory) UnstructuredClientForMapping(mapping *meta.RESTMapping) (
 	gv := mapping.GroupVersionKind.GroupVersion()
 	cfg.ContentConfig = dynamic.ContentConfig()
 	cfg.GroupVersion = &gv
+	if gv.Group == hyperapi.GroupName {
+		cfg.WrapTransport = hyper.WrapResourceTransport(cfg)
+	}
 	return restclient.RESTClientFor(cfg)
 }
 
 func (f *ring1Factory) Describer(mapping *meta.RESTMapping) (printers.Describer, error) {
+	// volumes and fips are got from the hyper.sh group, and the pods and
+	// services using them from the core group
+	if mapping.GroupVersionKind.Group == hyperapi.GroupName {
+		client, err := f.ClientForMapping(mapping)
+		if err != nil {
+			return nil, err
+		}
+		clientset, err := f.clientAccessFactory.ClientSet()
+		if err != nil {
+			return nil, err
+		}
+		if describer, ok := printersinternal.HyperDescriberFor(mapping.GroupVersionKind.GroupKind(), client, clientset); ok {
+			return describer, nil
+		}
+	}
+
 	mappingVersion := mapping.GroupVersionKind.GroupVersion()
 
 	clientset, err := f.clientAccessFactory.ClientSetForVersion(&mappingVersion)
@@ -200,6 +228,9 @@ func genericDescriber(clientAccessFactory ClientAccessFactory, mapping *meta.RES
 	clientConfigCopy.APIPath = dynamic.LegacyAPIPathResolverFunc(mapping.GroupVersionKind)
 	gv := mapping.GroupVersionKind.GroupVersion()
 	clientConfigCopy.GroupVersion = &gv
+	if gv.Group == hyperapi.GroupName {
+		clientConfigCopy.WrapTransport = hyper.WrapResourceTransport(clientConfig)
+	}
 
 	// used to fetch the resource
 	dynamicClient, err := dynamic.NewClient(&clientConfigCopy)
@@ -380,15 +411,24 @@ func (f *ring1Factory) Validator(validate bool) (validation.Schema, error) {
 		return validation.NullSchema{}, nil
 	}
 
-	resources, err := f.OpenAPISchema()
+	schema := validation.ConjunctiveSchema{}
+	// Servers that don't publish the openapi schema, as Hyper.sh, are
+	// validated with the other schemas only, the typos in the field names
+	// go through.
+	if resources, err := f.OpenAPISchema(); err != nil {
+		glog.V(2).Infof("unable to fetch the openapi schema, the objects are not validated against it: %v", err)
+	} else {
+		schema = append(schema, openapivalidation.NewSchemaValidation(resources))
+	}
+
+	clientConfig, err := f.clientAccessFactory.ClientConfig()
 	if err != nil {
 		return nil, err
 	}
-
-	return validation.ConjunctiveSchema{
-		openapivalidation.NewSchemaValidation(resources),
+	return append(schema,
 		validation.NoDoubleKeySchema{},
-	}, nil
+		openapivalidation.NewHyperValidation(newHyperPlatform(clientConfig)),
+	), nil
 }
 
 // OpenAPISchema returns metadata and structural information about Kubernetes object definitions.
@@ -407,6 +407,14 @@ func AddValidateOptionFlags(cmd *cobra.Command, options *ValidateOptions) {
 func AddFilenameOptionFlags(cmd *cobra.Command, options *resource.FilenameOptions, usage string) {
 	pi.AddJsonFilenameFlag(cmd, &options.Filenames, "Filename, directory, or URL to files "+usage)
 	//cmd.Flags().BoolVarP(&options.Recursive, "recursive", "R", options.Recursive, "Process the directory used in -f, --filename recursively. Useful when you want to manage related manifests organized within the same directory.")
+	cmd.Flags().StringArrayVar(&options.Set, "set", options.Set, "Set the value of a ${KEY} variable in the files (e.g. --set KEY=VALUE). Can be specified multiple times. The variables of the files are only expanded when --set, --values or --values-from-env is given.")
+	cmd.Flags().StringSliceVar(&options.ValuesFiles, "values", options.ValuesFiles, "YAML or JSON file with the values of the ${KEY} variables in the files, nested keys are joined with dots.")
+	cmd.Flags().BoolVar(&options.ValuesFromEnv, "values-from-env", options.ValuesFromEnv, "If true, the ${KEY} variables of the files not set by --set or --values are read from the environment.")
+}
+
+// AddRenderFlag adds the --render flag to commands reading files with variables.
+func AddRenderFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("render", false, "If true, print the files with their variables expanded and exit without sending them to the server.")
 }
 
 // AddDryRunFlag adds dry-run flag to a command. Usually used by mutations.
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"strings"
+
+	restclient "github.com/hyperhq/client-go/rest"
+	"github.com/hyperhq/pi/pkg/hyper"
+)
+
+// hyperPlatform gives the zones and volumes of the region of a cluster to the
+// validation of the objects sent to it. The answers of the server are cached,
+// they don't change during a command.
+type hyperPlatform struct {
+	conn *hyper.Conn
+
+	zones       []string
+	defaultZone string
+	volumes     map[string][]string
+}
+
+func newHyperPlatform(config *restclient.Config) *hyperPlatform {
+	return &hyperPlatform{
+		conn:    hyper.NewConn(config),
+		volumes: map[string][]string{},
+	}
+}
+
+// Zones returns the availability zones of the region info, formatted as
+// ZONE|STATE,ZONE|STATE, and the default zone of the account.
+func (p *hyperPlatform) Zones() ([]string, string, error) {
+	if p.zones != nil {
+		return p.zones, p.defaultZone, nil
+	}
+	_, info, err := p.conn.GetInfo()
+	if err != nil {
+		return nil, "", err
+	}
+	zones := []string{}
+	for _, zone := range strings.Split(info["AvailabilityZone"], ",") {
+		if name := strings.TrimSpace(strings.SplitN(zone, "|", 2)[0]); len(name) > 0 {
+			zones = append(zones, name)
+		}
+	}
+	p.zones, p.defaultZone = zones, info["DefaultZone"]
+	return p.zones, p.defaultZone, nil
+}
+
+// Volumes returns the names of the volumes in zone.
+func (p *hyperPlatform) Volumes(zone string) ([]string, error) {
+	if names, found := p.volumes[zone]; found {
+		return names, nil
+	}
+	volumes, err := p.conn.ListVolumes(zone)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, volume := range volumes {
+		names = append(names, volume.Name)
+	}
+	p.volumes[zone] = names
+	return names, nil
+}
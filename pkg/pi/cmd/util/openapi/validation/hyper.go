@@ -0,0 +1,282 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// MaxPodVolumes is the number of Hyper volumes that can be attached to a pod.
+const MaxPodVolumes = 4
+
+// supportedVolumeSources are the volume sources of pods Hyper can run,
+// flexVolume being the Hyper volumes.
+var supportedVolumeSources = []string{"emptyDir", "flexVolume", "gitRepo", "secret"}
+
+// Platform is the state of the Hyper region objects are validated against.
+type Platform interface {
+	// Zones returns the availability zones of the region, and the default
+	// zone of the account.
+	Zones() (zones []string, defaultZone string, err error)
+	// Volumes returns the names of the volumes in zone.
+	Volumes(zone string) ([]string, error)
+}
+
+// HyperValidation validates pods against the constraints of the Hyper
+// platform: the volume sources and number of volumes, the instance types, and
+// when a platform is given, the zones and volumes of the region.
+type HyperValidation struct {
+	platform Platform
+
+	// declared are the volumes of the validated Volume objects, which
+	// are created before the pods using them.
+	declared map[string]bool
+	// references are the volumes missing in the region used by the
+	// validated pods, checked against declared by Complete.
+	references []volumeReference
+}
+
+type volumeReference struct {
+	object string
+	path   *field.Path
+	name   string
+	zone   string
+}
+
+// NewHyperValidation creates a new HyperValidation, the objects are only
+// validated against the zones and volumes of the region when platform is
+// not nil.
+func NewHyperValidation(platform Platform) *HyperValidation {
+	return &HyperValidation{
+		platform: platform,
+		declared: map[string]bool{},
+	}
+}
+
+// ValidateBytes validates the pods in data, errors point to the path of the
+// offending field.
+func (v *HyperValidation) ValidateBytes(data []byte) error {
+	obj, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	gvk, errs := getObjectKind(obj)
+	if errs != nil {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	if strings.HasSuffix(gvk.Kind, "List") {
+		items, ok := obj.(map[string]interface{})["items"].([]interface{})
+		if !ok {
+			return errors.New("invalid object to validate")
+		}
+		allErrs := []error{}
+		for i := range items {
+			gvk, errs := getObjectKind(items[i])
+			if errs != nil {
+				allErrs = append(allErrs, errs...)
+				continue
+			}
+			if err := v.validateObject(items[i], gvk.Kind, field.NewPath("items").Index(i)); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+		return utilerrors.NewAggregate(allErrs)
+	}
+	return v.validateObject(obj, gvk.Kind, nil)
+}
+
+// Complete returns the errors of the pods using volumes that are neither in
+// the region nor in the validated objects.
+func (v *HyperValidation) Complete() error {
+	allErrs := []error{}
+	for _, ref := range v.references {
+		if v.declared[ref.name] {
+			continue
+		}
+		err := field.NotFound(ref.path, ref.name)
+		err.Detail = fmt.Sprintf("no volume %s in zone %s, create it with `pi create volume`", ref.name, ref.zone)
+		allErrs = append(allErrs, fmt.Errorf("%s: %v", ref.object, err))
+	}
+	v.references = nil
+	return utilerrors.NewAggregate(allErrs)
+}
+
+func (v *HyperValidation) validateObject(obj interface{}, kind string, path *field.Path) error {
+	// the objects are converted back to json to be decoded with the api types
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "Volume":
+		volume := &hyperapi.Volume{}
+		if err := json.Unmarshal(data, volume); err != nil {
+			return err
+		}
+		v.declared[volume.Name] = true
+	case "Pod":
+		pod := &v1.Pod{}
+		if err := json.Unmarshal(data, pod); err != nil {
+			return err
+		}
+		if errs := v.validatePod(pod, path.Child("spec")); len(errs) > 0 {
+			return fmt.Errorf("pod %s: %v", pod.Name, errs.ToAggregate())
+		}
+	}
+	return nil
+}
+
+func (v *HyperValidation) validatePod(pod *v1.Pod, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	volumeIDs := map[string]*field.Path{}
+	hyperVolumes := 0
+	for i, volume := range pod.Spec.Volumes {
+		volumePath := path.Child("volumes").Index(i)
+		source := volumeSourceName(volume.VolumeSource)
+		switch {
+		case len(source) == 0:
+			allErrs = append(allErrs, field.Required(volumePath, "a volume source is required"))
+			continue
+		case !contains(supportedVolumeSources, source):
+			allErrs = append(allErrs, field.Forbidden(volumePath.Child(source),
+				fmt.Sprintf("volume source %s is not supported, must be one of %s", source, strings.Join(supportedVolumeSources, ", "))))
+			continue
+		case source != "flexVolume":
+			continue
+		}
+		hyperVolumes++
+		if hyperVolumes == MaxPodVolumes+1 {
+			allErrs = append(allErrs, field.Forbidden(volumePath,
+				fmt.Sprintf("at most %d hyper volumes can be attached to a pod, found %d", MaxPodVolumes, countHyperVolumes(pod.Spec.Volumes))))
+		}
+		idPath := volumePath.Child("flexVolume", "options", "volumeID")
+		if id := volume.FlexVolume.Options["volumeID"]; len(id) == 0 {
+			allErrs = append(allErrs, field.Required(idPath, "the name of the hyper volume is required"))
+		} else {
+			volumeIDs[id] = idPath
+		}
+	}
+
+	allErrs = append(allErrs, validateInstanceType(pod.Spec.Containers, path.Child("containers"))...)
+
+	if v.platform == nil {
+		return allErrs
+	}
+	// the platform is only asked about the pods placed in a zone or using
+	// hyper volumes, the volumes of pods with errors are not checked as they
+	// are not created
+	selected, hasZone := pod.Spec.NodeSelector["zone"]
+	if !hasZone && (len(volumeIDs) == 0 || len(allErrs) > 0) {
+		return allErrs
+	}
+	zones, zone, err := v.platform.Zones()
+	if err != nil {
+		return append(allErrs, field.InternalError(path.Child("nodeSelector", "zone"), err))
+	}
+	if hasZone {
+		if !contains(zones, selected) {
+			allErrs = append(allErrs, field.NotSupported(path.Child("nodeSelector", "zone"), selected, zones))
+			return allErrs
+		}
+		zone = selected
+	}
+	if len(volumeIDs) == 0 || len(allErrs) > 0 {
+		return allErrs
+	}
+	volumes, err := v.platform.Volumes(zone)
+	if err != nil {
+		return append(allErrs, field.InternalError(path.Child("volumes"), err))
+	}
+	ids := []string{}
+	for id := range volumeIDs {
+		if !contains(volumes, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		v.references = append(v.references, volumeReference{object: "pod " + pod.Name, path: volumeIDs[id], name: id, zone: zone})
+	}
+	return allErrs
+}
+
+// validateInstanceType checks that the memory limits of containers fit in the
+// largest instance type, the error is on the container going over it.
+func validateInstanceType(containers []v1.Container, path *field.Path) field.ErrorList {
+	largest := hyperapi.InstanceTypes[len(hyperapi.InstanceTypes)-1]
+	total := resource.Quantity{}
+	for i, container := range containers {
+		limit, found := container.Resources.Limits[v1.ResourceMemory]
+		if !found {
+			continue
+		}
+		total.Add(limit)
+		if total.Cmp(largest.Memory) > 0 {
+			return field.ErrorList{field.Invalid(path.Index(i).Child("resources", "limits", "memory"), limit.String(),
+				fmt.Sprintf("the memory limits of the containers add up to more than %s of the largest instance type %s", largest.Memory.String(), largest.Name))}
+		}
+	}
+	return nil
+}
+
+// volumeSourceName returns the field name of the source set in source.
+func volumeSourceName(source v1.VolumeSource) string {
+	data, err := json.Marshal(source)
+	if err != nil {
+		return ""
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ""
+	}
+	for name := range fields {
+		return name
+	}
+	return ""
+}
+
+func countHyperVolumes(volumes []v1.Volume) int {
+	count := 0
+	for _, volume := range volumes {
+		if volume.FlexVolume != nil {
+			count++
+		}
+	}
+	return count
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type fakePlatform struct {
+	zones       []string
+	defaultZone string
+	volumes     map[string][]string
+
+	// calls are the questions asked to the platform
+	calls []string
+}
+
+func (p *fakePlatform) Zones() ([]string, string, error) {
+	p.calls = append(p.calls, "zones")
+	return p.zones, p.defaultZone, nil
+}
+
+func (p *fakePlatform) Volumes(zone string) ([]string, error) {
+	p.calls = append(p.calls, "volumes "+zone)
+	return p.volumes[zone], nil
+}
+
+func readExample(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile("../../../../../../examples/pod/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func podWith(name, spec string) string {
+	return "apiVersion: v1\nkind: Pod\nmetadata:\n  name: " + name + "\nspec:\n" + spec
+}
+
+const volumeData = `apiVersion: hyper.sh/v1
+kind: Volume
+metadata:
+  name: declared-data
+spec:
+  size: 1
+  zone: gcp-us-central1-b
+`
+
+func TestHyperValidation(t *testing.T) {
+	platform := &fakePlatform{
+		zones:       []string{"gcp-us-central1-a", "gcp-us-central1-b"},
+		defaultZone: "gcp-us-central1-a",
+		volumes: map[string][]string{
+			"gcp-us-central1-a": {"data-a"},
+			"gcp-us-central1-b": {"data-b"},
+		},
+	}
+	flexVolume := func(id string) string {
+		return "  containers:\n  - name: c\n    image: busybox\n  volumes:\n  - name: v\n    flexVolume:\n      options:\n        volumeID: " + id + "\n"
+	}
+
+	tests := []struct {
+		name string
+		// the manifests are validated in order, as the files of a command
+		manifests []string
+		// the errors expected, all the errors of the manifests and of
+		// Complete must be expected
+		expectedErrs []string
+	}{
+		{
+			name:         "unsupported volume source",
+			manifests:    []string{readExample(t, "pod-volume-unsupported.yaml")},
+			expectedErrs: []string{"spec.volumes[0].hostPath: Forbidden: volume source hostPath is not supported"},
+		},
+		{
+			name:         "more than 4 volumes",
+			manifests:    []string{readExample(t, "pod-volume-exceed-limit.yaml")},
+			expectedErrs: []string{"spec.volumes[4]: Forbidden: at most 4 hyper volumes can be attached to a pod, found 5"},
+		},
+		{
+			name: "memory over the largest instance type",
+			manifests: []string{podWith("big", `  containers:
+  - name: a
+    image: busybox
+    resources:
+      limits:
+        memory: 16Gi
+  - name: b
+    image: busybox
+    resources:
+      limits:
+        memory: 17Gi
+`)},
+			expectedErrs: []string{`spec.containers[1].resources.limits.memory: Invalid value: "17Gi": the memory limits of the containers add up to more than 32Gi of the largest instance type l3`},
+		},
+		{
+			name:         "largest instance type",
+			manifests:    []string{podWith("largest", "  containers:\n  - name: a\n    image: busybox\n    resources:\n      limits:\n        memory: 32Gi\n")},
+			expectedErrs: []string{},
+		},
+		{
+			name:         "unknown zone",
+			manifests:    []string{podWith("zone", "  nodeSelector:\n    zone: gcp-us-east1-a\n"+flexVolume("data-a"))},
+			expectedErrs: []string{`spec.nodeSelector.zone: Unsupported value: "gcp-us-east1-a"`},
+		},
+		{
+			name:         "missing volume id",
+			manifests:    []string{podWith("noid", "  containers:\n  - name: c\n    image: busybox\n  volumes:\n  - name: v\n    flexVolume:\n      driver: hyper\n")},
+			expectedErrs: []string{"spec.volumes[0].flexVolume.options.volumeID: Required value"},
+		},
+		{
+			name:         "volume of the default zone",
+			manifests:    []string{podWith("default", flexVolume("data-a"))},
+			expectedErrs: []string{},
+		},
+		{
+			name:         "volume of another zone",
+			manifests:    []string{podWith("other", flexVolume("data-b"))},
+			expectedErrs: []string{"spec.volumes[0].flexVolume.options.volumeID: Not found: \"data-b\""},
+		},
+		{
+			name:         "volume of the selected zone",
+			manifests:    []string{podWith("selected", "  nodeSelector:\n    zone: gcp-us-central1-b\n"+flexVolume("data-b"))},
+			expectedErrs: []string{},
+		},
+		{
+			name:         "volume declared after the pod",
+			manifests:    []string{podWith("declared", "  nodeSelector:\n    zone: gcp-us-central1-b\n"+flexVolume("declared-data")), volumeData},
+			expectedErrs: []string{},
+		},
+		{
+			name:         "volume declared in a list",
+			manifests:    []string{`{"apiVersion":"v1","kind":"List","items":[{"apiVersion":"hyper.sh/v1","kind":"Volume","metadata":{"name":"declared-data"}},{"apiVersion":"v1","kind":"Pod","metadata":{"name":"listed"},"spec":{"containers":[{"name":"c","image":"busybox"}],"volumes":[{"name":"v","flexVolume":{"options":{"volumeID":"declared-data"}}}]}}]}`},
+			expectedErrs: []string{},
+		},
+	}
+	for _, test := range tests {
+		v := NewHyperValidation(platform)
+		errs := []string{}
+		for _, manifest := range test.manifests {
+			if err := v.ValidateBytes([]byte(manifest)); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if err := v.Complete(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if len(errs) != len(test.expectedErrs) {
+			t.Errorf("%s: expected errors %q, got %q", test.name, test.expectedErrs, errs)
+			continue
+		}
+		for i := range errs {
+			if !strings.Contains(errs[i], test.expectedErrs[i]) {
+				t.Errorf("%s: expected error %q, got %q", test.name, test.expectedErrs[i], errs[i])
+			}
+		}
+	}
+}
+
+func TestHyperValidationWithoutPlatform(t *testing.T) {
+	v := NewHyperValidation(nil)
+	data := []byte(podWith("offline", "  nodeSelector:\n    zone: anywhere\n  containers:\n  - name: c\n    image: busybox\n  volumes:\n  - name: v\n    flexVolume:\n      options:\n        volumeID: data\n"))
+	if err := v.ValidateBytes(data); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := v.Complete(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHyperValidationAsksPlatformOnDemand(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		expected []string
+	}{
+		{
+			name:     "no zone nor hyper volume",
+			manifest: podWith("plain", "  containers:\n  - name: c\n    image: busybox\n  volumes:\n  - name: v\n    emptyDir: {}\n"),
+			expected: []string{},
+		},
+		{
+			name:     "zone",
+			manifest: podWith("zone", "  nodeSelector:\n    zone: gcp-us-central1-a\n  containers:\n  - name: c\n    image: busybox\n"),
+			expected: []string{"zones"},
+		},
+		{
+			name:     "hyper volume",
+			manifest: podWith("volume", "  containers:\n  - name: c\n    image: busybox\n  volumes:\n  - name: v\n    flexVolume:\n      options:\n        volumeID: data-a\n"),
+			expected: []string{"zones", "volumes gcp-us-central1-a"},
+		},
+		{
+			name:     "volume",
+			manifest: volumeData,
+			expected: []string{},
+		},
+	}
+	for _, test := range tests {
+		platform := &fakePlatform{
+			zones:       []string{"gcp-us-central1-a"},
+			defaultZone: "gcp-us-central1-a",
+			volumes:     map[string][]string{"gcp-us-central1-a": {"data-a"}},
+		}
+		v := NewHyperValidation(platform)
+		if err := v.ValidateBytes([]byte(test.manifest)); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if strings.Join(platform.calls, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("%s: expected the platform to be asked %q, got %q", test.name, test.expected, platform.calls)
+		}
+	}
+}
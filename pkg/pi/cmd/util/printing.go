@@ -63,7 +63,7 @@ func AddOutputVarFlagsForMutation(cmd *cobra.Command, output *string) {
 
 // AddOutputFlags adds output related flags to a command.
 func AddOutputFlags(cmd *cobra.Command) {
-	cmd.Flags().StringP("output", "o", "", "Output format. One of: json|yaml|wide|name")
+	cmd.Flags().StringP("output", "o", "", "Output format. One of: json|yaml|wide|name|custom-columns=...|custom-columns-file=...|go-template=...|go-template-file=...|jsonpath=...|jsonpath-file=... See custom columns [http://kubernetes.io/docs/user-guide/kubectl-overview/#custom-columns], golang template [http://golang.org/pkg/text/template/#pkg-overview] and jsonpath template [http://kubernetes.io/docs/user-guide/jsonpath].")
 	//cmd.Flags().Bool("allow-missing-template-keys", true, "If true, ignore any errors in templates when a field or map key is missing in the template. Only applies to golang and jsonpath output formats.")
 }
 
@@ -186,8 +186,8 @@ func ValidResourceTypeList(f ClientAccessFactory) string {
 			* pods (aka 'po')
 			* secrets
 			* services (aka 'svc')
-			* volumes
-			* fips
+			* volumes (aka 'vol')
+			* fips (aka 'fip')
 	`)
 }
 
@@ -199,8 +199,8 @@ func ValidDeleteResourceTypeList(f ClientAccessFactory) string {
 			* pods (aka 'po')
 			* secrets
 			* services (aka 'svc')
-			* volumes
-			* fips
+			* volumes (aka 'vol')
+			* fips (aka 'fip')
 	`)
 }
 
@@ -212,6 +212,8 @@ func ValidDescribeResourceTypeList(f ClientAccessFactory) string {
 			* pods (aka 'po')
 			* secrets
 			* services (aka 'svc')
+			* volumes (aka 'vol')
+			* fips (aka 'fip')
 	`)
 }
 
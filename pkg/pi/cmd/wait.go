@@ -0,0 +1,388 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+	"github.com/hyperhq/pi/pkg/pi/cmd/templates"
+	cmdutil "github.com/hyperhq/pi/pkg/pi/cmd/util"
+	"github.com/hyperhq/pi/pkg/pi/resource"
+	"github.com/hyperhq/pi/pkg/pi/util/i18n"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	utilexec "k8s.io/utils/exec"
+)
+
+var (
+	waitLong = templates.LongDesc(i18n.T(`
+		Wait for one or many resources to reach a state.
+
+		The state is given with --for and is one of:
+
+		* delete: the resource does not exist anymore.
+		* phase=PHASE: the status.phase of the resource, e.g. Running for a pod.
+		* condition=TYPE[=STATUS]: the status.conditions entry of that type has the given status, True by default.
+		  Volumes have the Attached and Detached conditions, set from the pod they are used by.
+
+		The resources are watched when the server supports it, and polled otherwise.
+		The command exits with 0 once every resource is in the state, with 2 when
+		the timeout expires first, and with 1 on any other error, e.g. when a pod
+		terminates without ever reaching the state.`))
+
+	waitExample = templates.Examples(i18n.T(`
+		# Wait for pod busybox1 to be ready
+		pi wait --for=condition=Ready pod/busybox1
+
+		# Wait for all the pods labeled app=web to run, for at most 5 minutes
+		pi wait --for=phase=Running pods -l app=web --timeout=5m
+
+		# Wait for pod busybox1 to be deleted
+		pi delete pod/busybox1 && pi wait --for=delete pod/busybox1
+
+		# Wait for volume vol1 to be detached from its pod
+		pi wait --for=condition=Detached volume/vol1`))
+)
+
+// waitTimeoutExitCode is the exit code of pi wait when the timeout expires
+// before the resources reach the state.
+const waitTimeoutExitCode = 2
+
+// waitPollInterval is how often the resources are fetched again when the
+// server can't watch them.
+var waitPollInterval = 2 * time.Second
+
+// WaitOptions are the options of the wait command.
+type WaitOptions struct {
+	resource.FilenameOptions
+
+	Selector string
+	For      string
+	Timeout  time.Duration
+
+	// ConditionFn tells whether obj is in the awaited state, obj is nil once
+	// the resource is deleted.
+	ConditionFn waitConditionFunc
+
+	Result *resource.Result
+	Mapper meta.RESTMapper
+
+	f   cmdutil.Factory
+	Out io.Writer
+}
+
+type waitConditionFunc func(obj runtime.Object) (bool, error)
+
+// NewCmdWait returns the wait command.
+func NewCmdWait(f cmdutil.Factory, out io.Writer) *cobra.Command {
+	o := &WaitOptions{Out: out}
+	cmd := &cobra.Command{
+		Use:     "wait (TYPE/NAME | TYPE NAME | TYPE -l label) --for=delete|phase=PHASE|condition=TYPE[=STATUS]",
+		Short:   i18n.T("Wait for a resource to reach a state"),
+		Long:    waitLong,
+		Example: waitExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.RunWait())
+		},
+	}
+	cmd.Flags().StringVarP(&o.Selector, "selector", "l", o.Selector, "Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2)")
+	cmd.Flags().StringVar(&o.For, "for", o.For, "The state to wait for: delete, phase=PHASE or condition=TYPE[=STATUS].")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", 30*time.Second, "The length of time to wait before giving up, zero means wait forever.")
+	cmdutil.AddFilenameOptionFlags(cmd, &o.FilenameOptions, "identifying the resource to wait for.")
+	return cmd
+}
+
+// Complete parses the state to wait for and resolves the resources.
+func (o *WaitOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && cmdutil.IsFilenameSliceEmpty(o.Filenames) {
+		return cmdutil.UsageErrorf(cmd, "You must specify the resources to wait for. %s", cmdutil.ValidResourceTypeList(f))
+	}
+	conditionFn, err := waitConditionFor(o.For)
+	if err != nil {
+		return cmdutil.UsageErrorf(cmd, err.Error())
+	}
+	o.ConditionFn = conditionFn
+
+	cmdNamespace, enforceNamespace, err := f.DefaultNamespace()
+	if err != nil {
+		return err
+	}
+	// the objects are fetched by RunWait, so that waiting for the deletion
+	// of an object which is already gone succeeds
+	r := f.NewBuilder().
+		Unstructured().
+		ContinueOnError().
+		NamespaceParam(cmdNamespace).DefaultNamespace().
+		FilenameParam(enforceNamespace, &o.FilenameOptions).
+		LabelSelectorParam(o.Selector).
+		ResourceTypeOrNameArgs(false, args...).RequireObject(false).
+		Flatten().
+		Do()
+	if err := r.Err(); err != nil {
+		return err
+	}
+	o.Result = r
+	o.Mapper = r.Mapper().RESTMapper
+	o.f = f
+	return nil
+}
+
+// RunWait waits for all the resources at once, so that each of them has the
+// whole timeout. The resources still not in the state when it expires are
+// reported together, with exit code 2; any other error takes precedence and
+// exits with 1.
+func (o *WaitOptions) RunWait() error {
+	var deadline time.Time
+	if o.Timeout > 0 {
+		deadline = time.Now().Add(o.Timeout)
+	}
+
+	infos := []*resource.Info{}
+	visitErr := o.Result.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+		return nil
+	})
+
+	lock := &sync.Mutex{}
+	errs := make([]error, len(infos))
+	wg := sync.WaitGroup{}
+	for i := range infos {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			info := infos[i]
+			if errs[i] = waitForInfo(info, o.ConditionFn, deadline); errs[i] != nil {
+				return
+			}
+			operation := "condition met"
+			if o.For == "delete" {
+				operation = "deleted"
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			o.f.PrintSuccess(o.Mapper, false, o.Out, info.Mapping.Resource, info.Name, false, operation)
+		}(i)
+	}
+	wg.Wait()
+
+	failures := []error{}
+	if visitErr != nil {
+		failures = append(failures, visitErr)
+	}
+	timedOut := []string{}
+	for i, err := range errs {
+		switch {
+		case err == wait.ErrWaitTimeout:
+			resourceName, _ := o.Mapper.ResourceSingularizer(infos[i].Mapping.Resource)
+			timedOut = append(timedOut, fmt.Sprintf("%s %q", resourceName, infos[i].Name))
+		case err != nil:
+			failures = append(failures, err)
+		}
+	}
+	if len(timedOut) > 0 {
+		timeoutErr := fmt.Errorf("timed out waiting for %s to reach %s", strings.Join(timedOut, ", "), o.For)
+		if len(failures) == 0 {
+			return utilexec.CodeExitError{Err: timeoutErr, Code: waitTimeoutExitCode}
+		}
+		failures = append(failures, timeoutErr)
+	}
+	if len(failures) > 0 {
+		return utilerrors.NewAggregate(failures)
+	}
+	if len(infos) == 0 && o.For != "delete" {
+		return fmt.Errorf("no matching resources found")
+	}
+	return nil
+}
+
+// waitConditionFor parses the value of --for.
+func waitConditionFor(spec string) (waitConditionFunc, error) {
+	switch {
+	case spec == "delete":
+		return func(obj runtime.Object) (bool, error) {
+			return obj == nil, nil
+		}, nil
+
+	case strings.HasPrefix(spec, "phase="):
+		phase := strings.TrimPrefix(spec, "phase=")
+		if len(phase) == 0 {
+			return nil, fmt.Errorf("--for=phase= requires a phase, e.g. --for=phase=Running")
+		}
+		return func(obj runtime.Object) (bool, error) {
+			if obj == nil {
+				return false, errWaitDeleted
+			}
+			current, _ := unstructured.NestedString(obj.(*unstructured.Unstructured).Object, "status", "phase")
+			if strings.EqualFold(current, phase) {
+				return true, nil
+			}
+			return false, checkPodTerminated(obj)
+		}, nil
+
+	case strings.HasPrefix(spec, "condition="):
+		parts := strings.SplitN(strings.TrimPrefix(spec, "condition="), "=", 2)
+		conditionType, conditionStatus := parts[0], "True"
+		if len(parts) == 2 {
+			conditionStatus = parts[1]
+		}
+		if len(conditionType) == 0 || len(conditionStatus) == 0 {
+			return nil, fmt.Errorf("--for=condition= requires a condition type, e.g. --for=condition=Ready")
+		}
+		return func(obj runtime.Object) (bool, error) {
+			if obj == nil {
+				return false, errWaitDeleted
+			}
+			for _, condition := range objectConditions(obj.(*unstructured.Unstructured)) {
+				if strings.EqualFold(condition.Type, conditionType) && strings.EqualFold(condition.Status, conditionStatus) {
+					return true, nil
+				}
+			}
+			return false, checkPodTerminated(obj)
+		}, nil
+	}
+	return nil, fmt.Errorf("--for must be one of delete, phase=PHASE or condition=TYPE[=STATUS], got %q", spec)
+}
+
+type waitCondition struct {
+	Type   string
+	Status string
+}
+
+// objectConditions returns status.conditions of obj. Volumes don't report
+// conditions, Attached and Detached are derived from the pod using them.
+func objectConditions(obj *unstructured.Unstructured) []waitCondition {
+	if gvk := obj.GroupVersionKind(); gvk.Group == hyperapi.GroupName && gvk.Kind == "Volume" {
+		pod, _ := unstructured.NestedString(obj.Object, "status", "pod")
+		attached, detached := "False", "True"
+		if len(pod) > 0 {
+			attached, detached = "True", "False"
+		}
+		return []waitCondition{{Type: "Attached", Status: attached}, {Type: "Detached", Status: detached}}
+	}
+
+	var conditions []waitCondition
+	items, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType, _ := unstructured.NestedString(fields, "type")
+		conditionStatus, _ := unstructured.NestedString(fields, "status")
+		conditions = append(conditions, waitCondition{Type: conditionType, Status: conditionStatus})
+	}
+	return conditions
+}
+
+// checkPodTerminated fails the wait once a pod has terminated, it can't reach
+// another state after that.
+func checkPodTerminated(obj runtime.Object) error {
+	u := obj.(*unstructured.Unstructured)
+	if u.GetKind() != "Pod" {
+		return nil
+	}
+	switch phase, _ := unstructured.NestedString(u.Object, "status", "phase"); phase {
+	case "Succeeded", "Failed":
+		return fmt.Errorf("pod %q terminated with phase %s", u.GetName(), phase)
+	}
+	return nil
+}
+
+// errWaitDeleted fails the wait for a state once the resource is deleted.
+var errWaitDeleted = fmt.Errorf("the resource was deleted")
+
+// waitForInfo blocks until condition holds for the object of info, or the
+// deadline passes, in which case wait.ErrWaitTimeout is returned. A zero
+// deadline never passes.
+func waitForInfo(info *resource.Info, condition waitConditionFunc, deadline time.Time) error {
+	helper := resource.NewHelper(info.Client, info.Mapping)
+	check := func() (bool, string, error) {
+		obj, err := helper.Get(info.Namespace, info.Name, false)
+		switch {
+		case errors.IsNotFound(err):
+			if done, _ := condition(nil); done {
+				return true, "", nil
+			}
+			return false, "", err
+		case err != nil:
+			return false, "", err
+		}
+		done, err := condition(obj)
+		resourceVersion, _ := info.Mapping.MetadataAccessor.ResourceVersion(obj)
+		return done, resourceVersion, err
+	}
+
+	done, resourceVersion, err := check()
+	if done || err != nil {
+		return err
+	}
+
+	if len(resourceVersion) > 0 {
+		w, err := helper.WatchSingle(info.Namespace, info.Name, resourceVersion)
+		if err == nil {
+			_, err = watch.Until(remaining(deadline), w, func(event watch.Event) (bool, error) {
+				switch event.Type {
+				case watch.Deleted:
+					return condition(nil)
+				case watch.Error:
+					return false, errors.FromObject(event.Object)
+				}
+				return condition(event.Object)
+			})
+			if err != watch.ErrWatchClosed {
+				return err
+			}
+			glog.V(4).Infof("watch of %s %q closed, polling", info.Mapping.Resource, info.Name)
+		} else {
+			glog.V(4).Infof("unable to watch %s %q, polling: %v", info.Mapping.Resource, info.Name, err)
+		}
+	}
+
+	return wait.Poll(waitPollInterval, remaining(deadline), func() (bool, error) {
+		done, _, err := check()
+		return done, err
+	})
+}
+
+// remaining returns the time left until deadline, at least one nanosecond
+// so that it is never mistaken for no timeout.
+func remaining(deadline time.Time) time.Duration {
+	if deadline.IsZero() {
+		return 0
+	}
+	if left := time.Until(deadline); left > 0 {
+		return left
+	}
+	return time.Nanosecond
+}
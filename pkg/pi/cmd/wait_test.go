@@ -0,0 +1,82 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/client-go/rest/fake"
+	cmdtesting "github.com/hyperhq/pi/pkg/pi/cmd/testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	utilexec "k8s.io/utils/exec"
+)
+
+func TestWaitReportsAllTimeoutsWithExitCode2(t *testing.T) {
+	defer func(interval time.Duration) { waitPollInterval = interval }(waitPollInterval)
+	waitPollInterval = 10 * time.Millisecond
+
+	// pod "late" runs 100ms after it is first fetched, "a" and "b" never do
+	lock := sync.Mutex{}
+	firstSeen := map[string]time.Time{}
+	f, tf, codec, _ := cmdtesting.NewAPIFactory()
+	tf.UnstructuredClient = &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			name := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+			lock.Lock()
+			if _, found := firstSeen[name]; !found {
+				firstSeen[name] = time.Now()
+			}
+			phase := api.PodPending
+			if name == "late" && time.Since(firstSeen[name]) > 100*time.Millisecond {
+				phase = api.PodRunning
+			}
+			lock.Unlock()
+			pod := &api.Pod{}
+			pod.Name, pod.Namespace, pod.Status.Phase = name, "test", phase
+			return &http.Response{StatusCode: http.StatusOK, Header: defaultHeader(), Body: objBody(codec, pod)}, nil
+		}),
+	}
+	tf.Namespace = "test"
+
+	buf := bytes.NewBuffer([]byte{})
+	cmd := NewCmdWait(f, buf)
+	o := &WaitOptions{For: "phase=Running", Timeout: 300 * time.Millisecond, Out: buf}
+	if err := o.Complete(f, cmd, []string{"pods/a", "pods/late", "pods/b"}); err != nil {
+		t.Fatal(err)
+	}
+	err := o.RunWait()
+
+	exitErr, ok := err.(utilexec.CodeExitError)
+	if !ok || exitErr.Code != waitTimeoutExitCode {
+		t.Fatalf("expected a single error with exit code %d, got %#v", waitTimeoutExitCode, err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, `pod "a"`) || !strings.Contains(msg, `pod "b"`) || strings.Contains(msg, `pod "late"`) {
+		t.Errorf("expected the timeouts of a and b only, got %q", msg)
+	}
+	if !strings.Contains(buf.String(), "late") {
+		t.Errorf("expected pod late to reach the state within the timeout, got %q", buf.String())
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsutil
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/hyperhq/hyper-api/types"
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	metricsapi "k8s.io/metrics/pkg/apis/metrics/v1alpha1"
+)
+
+// StatsGetter returns a sample of the resource usage of a container, as
+// served by the stats endpoint of the Hyper API.
+type StatsGetter interface {
+	ContainerStats(ctx context.Context, id string) (*types.StatsJSON, error)
+}
+
+// HyperMetricsClient reads the usage of pods from the stats of their
+// containers, Pi has no metrics server.
+type HyperMetricsClient struct {
+	Stats StatsGetter
+}
+
+func NewHyperMetricsClient(stats StatsGetter) *HyperMetricsClient {
+	return &HyperMetricsClient{Stats: stats}
+}
+
+// statsParallelism is the number of containers whose stats are read at once.
+const statsParallelism = 8
+
+// GetPodMetrics returns the usage of the running containers of pods. The pods
+// without running containers are left out. The usage of the containers whose
+// stats can't be read is nil, it is printed as unknown.
+func (cli *HyperMetricsClient) GetPodMetrics(ctx context.Context, pods []api.Pod) ([]metricsapi.PodMetrics, error) {
+	metrics := make([]metricsapi.PodMetrics, len(pods))
+	stats := make([][]*types.StatsJSON, len(pods))
+	sem := make(chan struct{}, statsParallelism)
+	wg := sync.WaitGroup{}
+	for i, pod := range pods {
+		metrics[i].ObjectMeta = metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Running == nil || len(status.ContainerID) == 0 {
+				continue
+			}
+			id := status.ContainerID
+			if sep := strings.Index(id, "://"); sep >= 0 {
+				id = id[sep+3:]
+			}
+			metrics[i].Containers = append(metrics[i].Containers, metricsapi.ContainerMetrics{Name: status.Name})
+			stats[i] = append(stats[i], nil)
+
+			wg.Add(1)
+			go func(pod, container int, name, id string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				s, err := cli.Stats.ContainerStats(ctx, id)
+				if err != nil {
+					glog.V(2).Infof("unable to get the stats of container %s of pod %s: %v", name, pods[pod].Name, err)
+					return
+				}
+				stats[pod][container] = s
+			}(i, len(stats[i])-1, status.Name, id)
+		}
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []metricsapi.PodMetrics{}
+	for i, m := range metrics {
+		if len(m.Containers) == 0 {
+			continue
+		}
+		for j, s := range stats[i] {
+			if s == nil {
+				continue
+			}
+			if s.Read.After(m.Timestamp.Time) {
+				m.Timestamp = metav1.NewTime(s.Read)
+			}
+			m.Containers[j].Usage = containerUsage(s)
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// containerUsage returns the cores used by the container since the previous
+// sample, and its memory working set, that is without the page cache.
+func containerUsage(stats *types.StatsJSON) v1.ResourceList {
+	cores := 0.0
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		cores = cpuDelta / systemDelta * float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	memory := stats.MemoryStats.Usage
+	if cache := stats.MemoryStats.Stats["cache"]; cache < memory {
+		memory -= cache
+	}
+	return v1.ResourceList{
+		v1.ResourceCPU:    *resource.NewMilliQuantity(int64(cores*1000), resource.DecimalSI),
+		v1.ResourceMemory: *resource.NewQuantity(int64(memory), resource.BinarySI),
+	}
+}
+
+// PodLimits returns the resources the pods and their containers can use,
+// keyed by pod name and by ContainerKey. The memory of a pod is the one of
+// its instance type, which also caps the containers without memory limits.
+// The CPU is only known when every container of the pod has a CPU limit.
+func PodLimits(pods []api.Pod) map[string]v1.ResourceList {
+	limits := map[string]v1.ResourceList{}
+	for _, pod := range pods {
+		podLimits := v1.ResourceList{}
+		cpu := resource.Quantity{}
+		allCPU := len(pod.Spec.Containers) > 0
+		for _, container := range pod.Spec.Containers {
+			containerLimits := v1.ResourceList{}
+			for name, quantity := range container.Resources.Limits {
+				containerLimits[v1.ResourceName(name)] = quantity
+			}
+			limits[ContainerKey(pod.Name, container.Name)] = containerLimits
+			if limit, found := container.Resources.Limits[api.ResourceCPU]; found {
+				cpu.Add(limit)
+			} else {
+				allCPU = false
+			}
+		}
+		if allCPU {
+			podLimits[v1.ResourceCPU] = cpu
+		}
+		if instanceType, found := hyperapi.InstanceTypeOfPod(&pod); found {
+			podLimits[v1.ResourceMemory] = instanceType.Memory
+			for _, container := range pod.Spec.Containers {
+				containerLimits := limits[ContainerKey(pod.Name, container.Name)]
+				if _, found := containerLimits[v1.ResourceMemory]; !found {
+					containerLimits[v1.ResourceMemory] = instanceType.Memory
+				}
+			}
+		}
+		limits[pod.Name] = podLimits
+	}
+	return limits
+}
+
+// ContainerKey is the key of the limits of a container in PodLimits.
+func ContainerKey(pod, container string) string {
+	return pod + "/" + container
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsutil
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperhq/hyper-api/types"
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+
+	"golang.org/x/net/context"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+const mi = 1024 * 1024
+
+func testStats(cpuDelta, systemDelta uint64, cpus int, memory, cache uint64) *types.StatsJSON {
+	stats := &types.StatsJSON{}
+	stats.PreCPUStats.CPUUsage.TotalUsage = 1000
+	stats.PreCPUStats.SystemUsage = 100000
+	stats.CPUStats.CPUUsage.TotalUsage = 1000 + cpuDelta
+	stats.CPUStats.SystemUsage = 100000 + systemDelta
+	stats.CPUStats.CPUUsage.PercpuUsage = make([]uint64, cpus)
+	stats.MemoryStats.Usage = memory
+	stats.MemoryStats.Stats = map[string]uint64{"cache": cache}
+	return stats
+}
+
+func TestContainerUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		stats  *types.StatsJSON
+		cpu    string
+		memory string
+	}{
+		{
+			name:   "idle",
+			stats:  testStats(0, 1000, 2, 64*mi, 0),
+			cpu:    "0",
+			memory: "64Mi",
+		},
+		{
+			name:   "one of two cores",
+			stats:  testStats(500, 1000, 2, 64*mi, 0),
+			cpu:    "1",
+			memory: "64Mi",
+		},
+		{
+			name:   "quarter of a core",
+			stats:  testStats(125, 1000, 2, 64*mi, 0),
+			cpu:    "250m",
+			memory: "64Mi",
+		},
+		{
+			name:   "no system delta",
+			stats:  testStats(500, 0, 2, 64*mi, 0),
+			cpu:    "0",
+			memory: "64Mi",
+		},
+		{
+			name:   "page cache",
+			stats:  testStats(0, 1000, 1, 96*mi, 32*mi),
+			cpu:    "0",
+			memory: "64Mi",
+		},
+		{
+			name:   "cache larger than usage",
+			stats:  testStats(0, 1000, 1, 32*mi, 64*mi),
+			cpu:    "0",
+			memory: "32Mi",
+		},
+	}
+	for _, test := range tests {
+		usage := containerUsage(test.stats)
+		if cpu := usage[v1.ResourceCPU]; cpu.Cmp(resource.MustParse(test.cpu)) != 0 {
+			t.Errorf("%s: expected cpu %s, got %s", test.name, test.cpu, cpu.String())
+		}
+		if memory := usage[v1.ResourceMemory]; memory.Cmp(resource.MustParse(test.memory)) != 0 {
+			t.Errorf("%s: expected memory %s, got %s", test.name, test.memory, memory.String())
+		}
+	}
+}
+
+func testContainer(name, cpu, memory string) api.Container {
+	c := api.Container{Name: name, Resources: api.ResourceRequirements{Limits: api.ResourceList{}}}
+	if len(cpu) > 0 {
+		c.Resources.Limits[api.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if len(memory) > 0 {
+		c.Resources.Limits[api.ResourceMemory] = resource.MustParse(memory)
+	}
+	return c
+}
+
+func testPod(name, instanceType string, containers ...api.Container) api.Pod {
+	pod := api.Pod{}
+	pod.Name = name
+	if len(instanceType) > 0 {
+		pod.Annotations = map[string]string{hyperapi.InstanceTypeAnnotation: instanceType}
+	}
+	pod.Spec.Containers = containers
+	return pod
+}
+
+func TestPodLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      api.Pod
+		expected map[string]map[v1.ResourceName]string
+	}{
+		{
+			name: "cpu of every container",
+			pod:  testPod("web", "s4", testContainer("nginx", "500m", ""), testContainer("php", "250m", "128Mi")),
+			expected: map[string]map[v1.ResourceName]string{
+				"web":       {v1.ResourceCPU: "750m", v1.ResourceMemory: "512Mi"},
+				"web/nginx": {v1.ResourceCPU: "500m", v1.ResourceMemory: "512Mi"},
+				"web/php":   {v1.ResourceCPU: "250m", v1.ResourceMemory: "128Mi"},
+			},
+		},
+		{
+			name: "container without cpu",
+			pod:  testPod("web", "m1", testContainer("nginx", "500m", ""), testContainer("php", "", "")),
+			expected: map[string]map[v1.ResourceName]string{
+				"web":       {v1.ResourceMemory: "1Gi"},
+				"web/nginx": {v1.ResourceCPU: "500m", v1.ResourceMemory: "1Gi"},
+				"web/php":   {v1.ResourceMemory: "1Gi"},
+			},
+		},
+		{
+			name: "unknown instance type",
+			pod:  testPod("web", "x9", testContainer("nginx", "", "")),
+			expected: map[string]map[v1.ResourceName]string{
+				"web":       {},
+				"web/nginx": {},
+			},
+		},
+	}
+	for _, test := range tests {
+		limits := PodLimits([]api.Pod{test.pod})
+		if len(limits) != len(test.expected) {
+			t.Errorf("%s: expected the limits of %d pods and containers, got %v", test.name, len(test.expected), limits)
+		}
+		for key, expected := range test.expected {
+			if len(limits[key]) != len(expected) {
+				t.Errorf("%s: expected limits %v for %s, got %v", test.name, expected, key, limits[key])
+				continue
+			}
+			for name, value := range expected {
+				if limit := limits[key][name]; limit.Cmp(resource.MustParse(value)) != 0 {
+					t.Errorf("%s: expected %s limit %s for %s, got %s", test.name, name, value, key, limit.String())
+				}
+			}
+		}
+	}
+}
+
+// fakeStats returns the stats of the container ids, or an error for the
+// others, and counts the concurrent calls.
+type fakeStats struct {
+	stats map[string]*types.StatsJSON
+
+	lock       sync.Mutex
+	running    int
+	maxRunning int
+}
+
+func (s *fakeStats) ContainerStats(ctx context.Context, id string) (*types.StatsJSON, error) {
+	s.lock.Lock()
+	s.running++
+	if s.running > s.maxRunning {
+		s.maxRunning = s.running
+	}
+	s.lock.Unlock()
+	time.Sleep(time.Millisecond)
+	s.lock.Lock()
+	s.running--
+	s.lock.Unlock()
+
+	stats, found := s.stats[id]
+	if !found {
+		return nil, errors.New("no such container")
+	}
+	return stats, nil
+}
+
+func runningPod(name string, containers ...string) api.Pod {
+	pod := api.Pod{}
+	pod.Name = name
+	for _, c := range containers {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, api.ContainerStatus{
+			Name:        c,
+			ContainerID: "hyper://" + name + "-" + c,
+			State:       api.ContainerState{Running: &api.ContainerStateRunning{}},
+		})
+	}
+	return pod
+}
+
+func TestGetPodMetrics(t *testing.T) {
+	read := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	web := testStats(500, 1000, 2, 64*mi, 0)
+	web.Read = read
+	stats := &fakeStats{stats: map[string]*types.StatsJSON{
+		"web-nginx": web,
+		"db-mysql":  testStats(0, 1000, 1, 256*mi, 0),
+	}}
+	pending := api.Pod{}
+	pending.Name = "pending"
+	pending.Status.ContainerStatuses = []api.ContainerStatus{{Name: "app", State: api.ContainerState{Waiting: &api.ContainerStateWaiting{}}}}
+	pods := []api.Pod{runningPod("web", "nginx", "php"), pending, runningPod("db", "mysql")}
+	for i := 0; i < 2*statsParallelism; i++ {
+		pods = append(pods, runningPod("idle", "app"))
+	}
+
+	metrics, err := NewHyperMetricsClient(stats).GetPodMetrics(context.Background(), pods)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metrics) != 2+2*statsParallelism {
+		t.Fatalf("expected the metrics of %d pods, got %d", 2+2*statsParallelism, len(metrics))
+	}
+	if m := metrics[0]; m.Name != "web" || len(m.Containers) != 2 || !m.Timestamp.Time.Equal(read) {
+		t.Errorf("unexpected metrics of web: %#v", m)
+	} else {
+		if cpu := m.Containers[0].Usage[v1.ResourceCPU]; m.Containers[0].Name != "nginx" || cpu.String() != "1" {
+			t.Errorf("unexpected metrics of nginx: %#v", m.Containers[0])
+		}
+		// the usage of a container without stats is unknown
+		if m.Containers[1].Name != "php" || m.Containers[1].Usage != nil {
+			t.Errorf("expected the usage of php to be unknown, got %#v", m.Containers[1])
+		}
+	}
+	if m := metrics[1]; m.Name != "db" || len(m.Containers) != 1 || m.Containers[0].Usage == nil {
+		t.Errorf("unexpected metrics of db: %#v", m)
+	}
+	if stats.maxRunning > statsParallelism {
+		t.Errorf("expected at most %d stats to be read at once, got %d", statsParallelism, stats.maxRunning)
+	}
+}
+
+func TestGetPodMetricsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewHyperMetricsClient(&fakeStats{}).GetPodMetrics(ctx, []api.Pod{runningPod("web", "nginx")})
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
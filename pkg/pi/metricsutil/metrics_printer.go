@@ -35,10 +35,15 @@ var (
 	}
 	NodeColumns     = []string{"NAME", "CPU(cores)", "CPU%", "MEMORY(bytes)", "MEMORY%"}
 	PodColumns      = []string{"NAME", "CPU(cores)", "MEMORY(bytes)"}
+	PodLimitColumns = []string{"NAME", "CPU(cores)", "CPU%", "MEMORY(bytes)", "MEMORY%"}
 	NamespaceColumn = "NAMESPACE"
 	PodColumn       = "POD"
 )
 
+// unknownUsage is printed for the usage of the containers whose stats could
+// not be read, and of their pods.
+const unknownUsage = "<unknown>"
+
 type ResourceMetricsInfo struct {
 	Name      string
 	Metrics   v1.ResourceList
@@ -80,7 +85,10 @@ func (printer *TopCmdPrinter) PrintNodeMetrics(metrics []metricsapi.NodeMetrics,
 	return nil
 }
 
-func (printer *TopCmdPrinter) PrintPodMetrics(metrics []metricsapi.PodMetrics, printContainers bool, withNamespace bool) error {
+// PrintPodMetrics prints the usage of the pods, or of their containers. When
+// availableResources is not nil, the usage is also printed as a percentage of
+// the resources of each pod, or container keyed by ContainerKey.
+func (printer *TopCmdPrinter) PrintPodMetrics(metrics []metricsapi.PodMetrics, printContainers bool, withNamespace bool, availableResources map[string]v1.ResourceList) error {
 	if len(metrics) == 0 {
 		return nil
 	}
@@ -101,9 +109,13 @@ func (printer *TopCmdPrinter) PrintPodMetrics(metrics []metricsapi.PodMetrics, p
 		return metrics[i].Name < metrics[j].Name
 	})
 
-	printColumnNames(w, PodColumns)
+	if availableResources != nil {
+		printColumnNames(w, PodLimitColumns)
+	} else {
+		printColumnNames(w, PodColumns)
+	}
 	for _, m := range metrics {
-		err := printSinglePodMetrics(w, &m, printContainers, withNamespace)
+		err := printSinglePodMetrics(w, &m, printContainers, withNamespace, availableResources)
 		if err != nil {
 			return err
 		}
@@ -118,7 +130,7 @@ func printColumnNames(out io.Writer, names []string) {
 	fmt.Fprint(out, "\n")
 }
 
-func printSinglePodMetrics(out io.Writer, m *metricsapi.PodMetrics, printContainersOnly bool, withNamespace bool) error {
+func printSinglePodMetrics(out io.Writer, m *metricsapi.PodMetrics, printContainersOnly bool, withNamespace bool, availableResources map[string]v1.ResourceList) error {
 	containers := make(map[string]v1.ResourceList)
 	podMetrics := make(v1.ResourceList)
 	for _, res := range MeasuredResources {
@@ -126,13 +138,20 @@ func printSinglePodMetrics(out io.Writer, m *metricsapi.PodMetrics, printContain
 	}
 
 	for _, c := range m.Containers {
+		if c.Usage == nil {
+			// the usage of the pod is unknown when one of its
+			// containers is
+			containers[c.Name] = nil
+			podMetrics = nil
+			continue
+		}
 		var usage v1.ResourceList
 		err := legacyscheme.Scheme.Convert(&c.Usage, &usage, nil)
 		if err != nil {
 			return err
 		}
 		containers[c.Name] = usage
-		if !printContainersOnly {
+		if !printContainersOnly && podMetrics != nil {
 			for _, res := range MeasuredResources {
 				quantity := podMetrics[res]
 				quantity.Add(usage[res])
@@ -141,7 +160,12 @@ func printSinglePodMetrics(out io.Writer, m *metricsapi.PodMetrics, printContain
 		}
 	}
 	if printContainersOnly {
+		names := make([]string, 0, len(containers))
 		for contName := range containers {
+			names = append(names, contName)
+		}
+		sort.Strings(names)
+		for _, contName := range names {
 			if withNamespace {
 				printValue(out, m.Namespace)
 			}
@@ -149,7 +173,7 @@ func printSinglePodMetrics(out io.Writer, m *metricsapi.PodMetrics, printContain
 			printMetricsLine(out, &ResourceMetricsInfo{
 				Name:      contName,
 				Metrics:   containers[contName],
-				Available: v1.ResourceList{},
+				Available: available(availableResources, ContainerKey(m.Name, contName)),
 			})
 		}
 	} else {
@@ -159,12 +183,24 @@ func printSinglePodMetrics(out io.Writer, m *metricsapi.PodMetrics, printContain
 		printMetricsLine(out, &ResourceMetricsInfo{
 			Name:      m.Name,
 			Metrics:   podMetrics,
-			Available: v1.ResourceList{},
+			Available: available(availableResources, m.Name),
 		})
 	}
 	return nil
 }
 
+// available returns the resources of key in availableResources, nil when
+// no percentage is printed.
+func available(availableResources map[string]v1.ResourceList, key string) v1.ResourceList {
+	if availableResources == nil {
+		return nil
+	}
+	if resources, found := availableResources[key]; found {
+		return resources
+	}
+	return v1.ResourceList{}
+}
+
 func printMetricsLine(out io.Writer, metrics *ResourceMetricsInfo) {
 	printValue(out, metrics.Name)
 	printAllResourceUsages(out, metrics)
@@ -177,12 +213,22 @@ func printValue(out io.Writer, value interface{}) {
 
 func printAllResourceUsages(out io.Writer, metrics *ResourceMetricsInfo) {
 	for _, res := range MeasuredResources {
+		if metrics.Metrics == nil {
+			printValue(out, unknownUsage)
+			if metrics.Available != nil {
+				printValue(out, unknownUsage)
+			}
+			continue
+		}
 		quantity := metrics.Metrics[res]
 		printSingleResourceUsage(out, res, quantity)
 		fmt.Fprint(out, "\t")
-		if available, found := metrics.Available[res]; found {
+		if available, found := metrics.Available[res]; found && !available.IsZero() {
 			fraction := float64(quantity.MilliValue()) / float64(available.MilliValue()) * 100
 			fmt.Fprintf(out, "%d%%\t", int64(fraction))
+		} else if metrics.Available != nil {
+			// the column is kept for the resources without limit
+			fmt.Fprint(out, "-\t")
 		}
 	}
 }
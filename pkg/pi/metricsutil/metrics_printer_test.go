@@ -0,0 +1,130 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsutil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	_ "k8s.io/kubernetes/pkg/apis/core/install"
+	metricsapi "k8s.io/metrics/pkg/apis/metrics/v1alpha1"
+)
+
+func testUsage(cpu, memory string) v1.ResourceList {
+	return v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}
+}
+
+func testPodMetrics(name string, containers ...metricsapi.ContainerMetrics) metricsapi.PodMetrics {
+	return metricsapi.PodMetrics{ObjectMeta: metav1.ObjectMeta{Name: name}, Containers: containers}
+}
+
+func TestPrintPodMetrics(t *testing.T) {
+	web := testPodMetrics("web",
+		metricsapi.ContainerMetrics{Name: "nginx", Usage: testUsage("250m", "64Mi")},
+		metricsapi.ContainerMetrics{Name: "php", Usage: testUsage("125m", "192Mi")},
+	)
+	unknown := testPodMetrics("web",
+		metricsapi.ContainerMetrics{Name: "nginx", Usage: testUsage("250m", "64Mi")},
+		metricsapi.ContainerMetrics{Name: "php"},
+	)
+	limits := map[string]v1.ResourceList{
+		"web":       testUsage("500m", "512Mi"),
+		"web/nginx": testUsage("1", "512Mi"),
+		"web/php":   {v1.ResourceMemory: resource.MustParse("128Mi")},
+	}
+	tests := []struct {
+		name       string
+		metrics    metricsapi.PodMetrics
+		containers bool
+		limits     map[string]v1.ResourceList
+		expected   []string
+	}{
+		{
+			name:     "pod",
+			metrics:  web,
+			expected: []string{"NAME CPU(cores) MEMORY(bytes)", "web 375m 256Mi"},
+		},
+		{
+			name:     "pod limits",
+			metrics:  web,
+			limits:   limits,
+			expected: []string{"NAME CPU(cores) CPU% MEMORY(bytes) MEMORY%", "web 375m 75% 256Mi 50%"},
+		},
+		{
+			name:       "container limits",
+			metrics:    web,
+			containers: true,
+			limits:     limits,
+			expected: []string{
+				"POD NAME CPU(cores) CPU% MEMORY(bytes) MEMORY%",
+				"web nginx 250m 25% 64Mi 12%",
+				// the usage of php is over its memory limit, it has no cpu limit
+				"web php 125m - 192Mi 150%",
+			},
+		},
+		{
+			name:     "no limits",
+			metrics:  web,
+			limits:   map[string]v1.ResourceList{},
+			expected: []string{"NAME CPU(cores) CPU% MEMORY(bytes) MEMORY%", "web 375m - 256Mi -"},
+		},
+		{
+			name:     "unknown pod",
+			metrics:  unknown,
+			limits:   limits,
+			expected: []string{"NAME CPU(cores) CPU% MEMORY(bytes) MEMORY%", "web <unknown> <unknown> <unknown> <unknown>"},
+		},
+		{
+			name:     "unknown pod without limits",
+			metrics:  unknown,
+			expected: []string{"NAME CPU(cores) MEMORY(bytes)", "web <unknown> <unknown>"},
+		},
+		{
+			name:       "unknown container",
+			metrics:    unknown,
+			containers: true,
+			limits:     limits,
+			expected: []string{
+				"POD NAME CPU(cores) CPU% MEMORY(bytes) MEMORY%",
+				"web nginx 250m 25% 64Mi 12%",
+				"web php <unknown> <unknown> <unknown> <unknown>",
+			},
+		},
+	}
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		err := NewTopCmdPrinter(out).PrintPodMetrics([]metricsapi.PodMetrics{test.metrics}, test.containers, false, test.limits)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		lines := []string{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			lines = append(lines, strings.Join(strings.Fields(line), " "))
+		}
+		if strings.Join(lines, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, strings.Join(test.expected, "\n"), out.String())
+		}
+	}
+}
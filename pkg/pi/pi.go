@@ -51,6 +51,14 @@ var ResourcesShortcutStatic = []ResourceShortcuts{
 		ShortForm: schema.GroupResource{Resource: "svc"},
 		LongForm:  schema.GroupResource{Resource: "services"},
 	},
+	{
+		ShortForm: schema.GroupResource{Group: "hyper.sh", Resource: "fip"},
+		LongForm:  schema.GroupResource{Group: "hyper.sh", Resource: "fips"},
+	},
+	{
+		ShortForm: schema.GroupResource{Group: "hyper.sh", Resource: "vol"},
+		LongForm:  schema.GroupResource{Group: "hyper.sh", Resource: "volumes"},
+	},
 }
 
 // ResourceShortFormFor looks up for a short form of resource names.
@@ -113,6 +113,12 @@ func IsUsageError(err error) bool {
 type FilenameOptions struct {
 	Filenames []string
 	Recursive bool
+	// Set (KEY=VALUE), ValuesFiles and ValuesFromEnv provide the values of
+	// the ${KEY} variables in the files, see Template. The files are only
+	// expanded when one of them is given.
+	Set           []string
+	ValuesFiles   []string
+	ValuesFromEnv bool
 }
 
 type resourceTuple struct {
@@ -146,6 +152,12 @@ func (b *Builder) Schema(schema validation.Schema) *Builder {
 func (b *Builder) FilenameParam(enforceNamespace bool, filenameOptions *FilenameOptions) *Builder {
 	recursive := filenameOptions.Recursive
 	paths := filenameOptions.Filenames
+	template, err := filenameOptions.Template()
+	if err != nil {
+		b.errs = append(b.errs, err)
+		return b
+	}
+	start := len(b.paths)
 	for _, s := range paths {
 		switch {
 		case s == "-":
@@ -164,6 +176,14 @@ func (b *Builder) FilenameParam(enforceNamespace bool, filenameOptions *Filename
 			b.Path(recursive, s)
 		}
 	}
+	for _, v := range b.paths[start:] {
+		switch t := v.(type) {
+		case *FileVisitor:
+			t.Template = template
+		case *URLVisitor:
+			t.Template = template
+		}
+	}
 
 	if enforceNamespace {
 		b.RequireNamespace()
@@ -851,6 +871,7 @@ func (b *Builder) visitByName() *Result {
 		result.err = err
 		return result
 	}
+	client = NewClientWithOptions(client, b.requestTransforms...)
 
 	selectorNamespace := b.namespace
 	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
@@ -0,0 +1,230 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// templateVariable matches ${NAME} placeholders, and the $${NAME} escapes of them.
+var templateVariable = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+
+// Template expands the ${NAME} variables of manifests before they are decoded.
+// Values are looked up in Values first, then through LookupEnv. A variable
+// written as $${NAME} is left in the manifest as ${NAME}.
+type Template struct {
+	Values map[string]string
+	// LookupEnv, when set, is consulted for the variables missing from Values.
+	LookupEnv func(key string) (string, bool)
+}
+
+// UnresolvedVariablesError lists the variables of a manifest no value was found for.
+type UnresolvedVariablesError struct {
+	Source string
+	// Variables maps the name of each unresolved variable to the lines it is used on.
+	Variables map[string][]int
+}
+
+func (e *UnresolvedVariablesError) Error() string {
+	names := make([]string, 0, len(e.Variables))
+	for name := range e.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make([]string, 0, len(names))
+	for _, name := range names {
+		lines := make([]string, 0, len(e.Variables[name]))
+		for _, line := range e.Variables[name] {
+			lines = append(lines, fmt.Sprintf("%d", line))
+		}
+		vars = append(vars, fmt.Sprintf("%s (line %s)", name, strings.Join(lines, ", ")))
+	}
+	return fmt.Sprintf("unresolved template variables in %q: %s; set them with --set or --values, or read them from the environment with --values-from-env", e.Source, strings.Join(vars, ", "))
+}
+
+// Expand replaces the variables of data, source is only used in errors.
+func (t *Template) Expand(data []byte, source string) ([]byte, error) {
+	var unresolved map[string][]int
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = templateVariable.ReplaceAllFunc(line, func(match []byte) []byte {
+			if bytes.HasPrefix(match, []byte("$$")) {
+				return match[1:]
+			}
+			name := string(match[2 : len(match)-1])
+			if value, ok := t.Values[name]; ok {
+				return []byte(value)
+			}
+			if t.LookupEnv != nil {
+				if value, ok := t.LookupEnv(name); ok {
+					return []byte(value)
+				}
+			}
+			if unresolved == nil {
+				unresolved = map[string][]int{}
+			}
+			unresolved[name] = append(unresolved[name], i+1)
+			return match
+		})
+	}
+	if len(unresolved) > 0 {
+		return nil, &UnresolvedVariablesError{Source: source, Variables: unresolved}
+	}
+	return bytes.Join(lines, []byte("\n")), nil
+}
+
+// Template returns the template the files of o are expanded with, nil when
+// none of Set, ValuesFiles and ValuesFromEnv is given: the files are then read
+// as they are. Values given with Set win over the ones read from ValuesFiles,
+// and later values files win over earlier ones.
+func (o *FilenameOptions) Template() (*Template, error) {
+	if len(o.Set) == 0 && len(o.ValuesFiles) == 0 && !o.ValuesFromEnv {
+		return nil, nil
+	}
+	values := map[string]string{}
+	for _, path := range o.ValuesFiles {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading values file %q: %v", path, err)
+		}
+		fileValues := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &fileValues); err != nil {
+			return nil, fmt.Errorf("error parsing values file %q: %v", path, err)
+		}
+		if err := flattenValues("", fileValues, values); err != nil {
+			return nil, fmt.Errorf("error parsing values file %q: %v", path, err)
+		}
+	}
+	for _, kv := range o.Set {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("invalid --set %q, expected KEY=VALUE", kv)
+		}
+		values[parts[0]] = parts[1]
+	}
+	template := &Template{Values: values}
+	if o.ValuesFromEnv {
+		template.LookupEnv = os.LookupEnv
+	}
+	return template, nil
+}
+
+// flattenValues stores the scalars of in into out, the keys of nested maps
+// are joined with dots.
+func flattenValues(prefix string, in map[string]interface{}, out map[string]string) error {
+	for key, value := range in {
+		if len(prefix) > 0 {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flattenValues(key, v, out); err != nil {
+				return err
+			}
+		case []interface{}:
+			return fmt.Errorf("value of %q must be a scalar or a map, not a list", key)
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprintf("%v", v)
+		}
+	}
+	return nil
+}
+
+// Render writes the files of o to w with their variables expanded, one
+// document stream after the other, without decoding them. Without a template
+// the files are written as they are.
+func (o *FilenameOptions) Render(w io.Writer) error {
+	template, err := o.Template()
+	if err != nil {
+		return err
+	}
+
+	first := true
+	render := func(r io.Reader, source string) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		expanded := data
+		if template != nil {
+			if expanded, err = template.Expand(data, source); err != nil {
+				return err
+			}
+		}
+		if !first {
+			fmt.Fprintln(w, "---")
+		}
+		first = false
+		if _, err := w.Write(expanded); err != nil {
+			return err
+		}
+		if len(expanded) > 0 && expanded[len(expanded)-1] != '\n' {
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+
+	for _, s := range o.Filenames {
+		switch {
+		case s == "-":
+			if err := render(os.Stdin, constSTDINstr); err != nil {
+				return err
+			}
+		case strings.Index(s, "http://") == 0 || strings.Index(s, "https://") == 0:
+			body, err := readHttpWithRetries(httpgetImpl, time.Second, s, defaultHttpGetAttempts)
+			if err != nil {
+				return err
+			}
+			err = render(body, s)
+			body.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			visitors, err := ExpandPathsToFileVisitors(nil, s, o.Recursive, FileExtensions, nil)
+			if err != nil {
+				return fmt.Errorf("error reading %q: %v", s, err)
+			}
+			for _, v := range visitors {
+				path := v.(*FileVisitor).Path
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				err = render(f, path)
+				f.Close()
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -273,6 +274,19 @@ func ValidateSchema(data []byte, schema validation.Schema) error {
 	return nil
 }
 
+// CompleteSchema runs the checks of schema across all the objects it
+// validated, once the visitors are done with them.
+func CompleteSchema(schema validation.Schema) error {
+	completing, ok := schema.(validation.CompletingSchema)
+	if !ok {
+		return nil
+	}
+	if err := completing.Complete(); err != nil {
+		return fmt.Errorf("error validating data: %v; %s", err, stopValidateMessage)
+	}
+	return nil
+}
+
 // URLVisitor downloads the contents of a URL, and if successful, returns
 // an info object representing the downloaded object.
 type URLVisitor struct {
@@ -557,6 +571,8 @@ type StreamVisitor struct {
 
 	Source string
 	Schema validation.Schema
+	// Template, when set, expands the variables of the stream before it is decoded.
+	Template *Template
 }
 
 // NewStreamVisitor is a helper function that is useful when we want to change the fields of the struct but keep calls the same.
@@ -571,7 +587,18 @@ func NewStreamVisitor(r io.Reader, mapper *Mapper, source string, schema validat
 
 // Visit implements Visitor over a stream. StreamVisitor is able to distinct multiple resources in one stream.
 func (v *StreamVisitor) Visit(fn VisitorFunc) error {
-	d := yaml.NewYAMLOrJSONDecoder(v.Reader, 4096)
+	r := v.Reader
+	if v.Template != nil {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if data, err = v.Template.Expand(data, v.Source); err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	d := yaml.NewYAMLOrJSONDecoder(r, 4096)
 	for {
 		ext := runtime.RawExtension{}
 		if err := d.Decode(&ext); err != nil {
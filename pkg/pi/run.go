@@ -21,6 +21,8 @@ import (
 	"strconv"
 	"strings"
 
+	hyperapi "github.com/hyperhq/pi/pkg/apis/hyper"
+
 	appsv1beta1 "k8s.io/api/apps/v1beta1"
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
@@ -825,6 +827,9 @@ func (BasicPod) ParamNames() []GeneratorParam {
 		{"requests", false},
 		{"limits", false},
 		{"serviceaccount", false},
+		{"volume", false},
+		{"zone", false},
+		{"size", false},
 	}
 }
 
@@ -839,6 +844,11 @@ func (BasicPod) Generate(genericParams map[string]interface{}) (runtime.Object,
 		return nil, err
 	}
 
+	volumes, err := getVolumes(genericParams)
+	if err != nil {
+		return nil, err
+	}
+
 	params, err := getParams(genericParams)
 	if err != nil {
 		return nil, err
@@ -872,6 +882,9 @@ func (BasicPod) Generate(genericParams map[string]interface{}) (runtime.Object,
 	if err != nil {
 		return nil, err
 	}
+	if err := applyInstanceTypeSize(params["size"], &resourceRequirements); err != nil {
+		return nil, err
+	}
 
 	restartPolicy := v1.RestartPolicy(params["restart"])
 	if len(restartPolicy) == 0 {
@@ -909,9 +922,113 @@ func (BasicPod) Generate(genericParams map[string]interface{}) (runtime.Object,
 	if err := updatePodPorts(params, &pod.Spec); err != nil {
 		return nil, err
 	}
+
+	if err := updatePodVolumes(volumes, &pod.Spec); err != nil {
+		return nil, err
+	}
+
+	if zone := params["zone"]; len(zone) > 0 {
+		pod.Spec.NodeSelector = map[string]string{"zone": zone}
+	}
 	return &pod, nil
 }
 
+// applyInstanceTypeSize sets the memory limit of resources to the memory of
+// the instance type size, so that the pod runs as that type.
+func applyInstanceTypeSize(size string, resources *v1.ResourceRequirements) error {
+	if len(size) == 0 {
+		return nil
+	}
+	instanceType, found := hyperapi.InstanceTypeByName(strings.ToLower(size))
+	if !found {
+		return fmt.Errorf("invalid size %q, must be one of %s", size, strings.Join(hyperapi.InstanceTypeNames(), ", "))
+	}
+	if _, found := resources.Limits[v1.ResourceMemory]; found {
+		return fmt.Errorf("size %s sets the memory limit, it cannot be used with a memory limit in limits", size)
+	}
+	if resources.Limits == nil {
+		resources.Limits = v1.ResourceList{}
+	}
+	resources.Limits[v1.ResourceMemory] = instanceType.Memory
+	return nil
+}
+
+// getVolumes returns the volumes to mount, formatted as NAME:/mount/path[:ro].
+func getVolumes(genericParams map[string]interface{}) ([]string, error) {
+	val, found := genericParams["volume"]
+	if !found {
+		return nil, nil
+	}
+	delete(genericParams, "volume")
+	volumes, isArray := val.([]string)
+	if !isArray {
+		return nil, fmt.Errorf("expected []string, found: %v", val)
+	}
+	return volumes, nil
+}
+
+// ParseVolumeMount parses a volume to mount in a pod, formatted as
+// NAME:/mount/path[:ro|rw], where NAME is the name of a Hyper volume.
+func ParseVolumeMount(spec string) (name, mountPath string, readOnly bool, err error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", "", false, fmt.Errorf("invalid volume %q, must be NAME:/mount/path[:ro]", spec)
+	}
+	name, mountPath = parts[0], parts[1]
+	if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+		return "", "", false, fmt.Errorf("invalid volume name %q: %s", name, strings.Join(errs, ","))
+	}
+	if !strings.HasPrefix(mountPath, "/") {
+		return "", "", false, fmt.Errorf("invalid volume %q, the mount path must be absolute", spec)
+	}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			readOnly = true
+		case "rw":
+		default:
+			return "", "", false, fmt.Errorf("invalid volume %q, the mode must be ro or rw", spec)
+		}
+	}
+	return name, mountPath, readOnly, nil
+}
+
+// updatePodVolumes mounts the Hyper volumes in the first container, through
+// flexVolumes naming them in options.volumeID.
+func updatePodVolumes(volumes []string, podSpec *v1.PodSpec) error {
+	mountPaths := map[string]bool{}
+	for _, spec := range volumes {
+		name, mountPath, readOnly, err := ParseVolumeMount(spec)
+		if err != nil {
+			return err
+		}
+		for _, volume := range podSpec.Volumes {
+			if volume.Name == name {
+				return fmt.Errorf("volume %q is mounted more than once", name)
+			}
+		}
+		if mountPaths[mountPath] {
+			return fmt.Errorf("more than one volume is mounted at %s", mountPath)
+		}
+		mountPaths[mountPath] = true
+
+		podSpec.Volumes = append(podSpec.Volumes, v1.Volume{
+			Name: name,
+			VolumeSource: v1.VolumeSource{
+				FlexVolume: &v1.FlexVolumeSource{
+					Options: map[string]string{"volumeID": name},
+				},
+			},
+		})
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, v1.VolumeMount{
+			Name:      name,
+			MountPath: mountPath,
+			ReadOnly:  readOnly,
+		})
+	}
+	return nil
+}
+
 // parseEnvs converts string into EnvVar objects.
 func parseEnvs(envArray []string) ([]v1.EnvVar, error) {
 	envs := make([]v1.EnvVar, 0, len(envArray))
@@ -0,0 +1,139 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pi
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestParseVolumeMount(t *testing.T) {
+	tests := []struct {
+		spec      string
+		name      string
+		mountPath string
+		readOnly  bool
+		expectErr bool
+	}{
+		{spec: "data:/data", name: "data", mountPath: "/data"},
+		{spec: "data:/var/lib/mysql:ro", name: "data", mountPath: "/var/lib/mysql", readOnly: true},
+		{spec: "data:/data:rw", name: "data", mountPath: "/data"},
+		{spec: "data:/data:RO", expectErr: true},
+		{spec: "data:/data:rx", expectErr: true},
+		{spec: "data:/data:ro:rw", expectErr: true},
+		{spec: "data", expectErr: true},
+		{spec: ":/data", expectErr: true},
+		{spec: "Data:/data", expectErr: true},
+		{spec: "data:data", expectErr: true},
+	}
+	for _, test := range tests {
+		name, mountPath, readOnly, err := ParseVolumeMount(test.spec)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error", test.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.spec, err)
+			continue
+		}
+		if name != test.name || mountPath != test.mountPath || readOnly != test.readOnly {
+			t.Errorf("%s: expected %s %s %v, got %s %s %v", test.spec, test.name, test.mountPath, test.readOnly, name, mountPath, readOnly)
+		}
+	}
+}
+
+func TestBasicPodVolumes(t *testing.T) {
+	tests := []struct {
+		name           string
+		volumes        []string
+		zone           string
+		expectedMounts []v1.VolumeMount
+		expectErr      bool
+	}{
+		{
+			name:    "volumes",
+			volumes: []string{"data:/data", "logs:/var/log:ro"},
+			expectedMounts: []v1.VolumeMount{
+				{Name: "data", MountPath: "/data"},
+				{Name: "logs", MountPath: "/var/log", ReadOnly: true},
+			},
+		},
+		{
+			name:           "zone",
+			volumes:        []string{"data:/data"},
+			zone:           "gcp-us-central1-a",
+			expectedMounts: []v1.VolumeMount{{Name: "data", MountPath: "/data"}},
+		},
+		{
+			name:      "bad mode",
+			volumes:   []string{"data:/data:wo"},
+			expectErr: true,
+		},
+		{
+			name:      "volume mounted twice",
+			volumes:   []string{"data:/data", "data:/backup"},
+			expectErr: true,
+		},
+		{
+			name:      "two volumes at the same path",
+			volumes:   []string{"data:/data", "logs:/data"},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		params := map[string]interface{}{
+			"name":   "foo",
+			"image":  "nginx",
+			"volume": test.volumes,
+		}
+		if len(test.zone) > 0 {
+			params["zone"] = test.zone
+		}
+		obj, err := BasicPod{}.Generate(params)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		pod := obj.(*v1.Pod)
+		if mounts := pod.Spec.Containers[0].VolumeMounts; !reflect.DeepEqual(mounts, test.expectedMounts) {
+			t.Errorf("%s: expected mounts %v, got %v", test.name, test.expectedMounts, mounts)
+		}
+		for i, mount := range test.expectedMounts {
+			volume := pod.Spec.Volumes[i]
+			if volume.Name != mount.Name || volume.FlexVolume == nil || volume.FlexVolume.Options["volumeID"] != mount.Name {
+				t.Errorf("%s: expected the flexVolume %s, got %+v", test.name, mount.Name, volume)
+			}
+		}
+		var expectedSelector map[string]string
+		if len(test.zone) > 0 {
+			expectedSelector = map[string]string{"zone": test.zone}
+		}
+		if !reflect.DeepEqual(pod.Spec.NodeSelector, expectedSelector) {
+			t.Errorf("%s: expected node selector %v, got %v", test.name, expectedSelector, pod.Spec.NodeSelector)
+		}
+	}
+}
@@ -44,6 +44,7 @@ import (
 	settingsv1alpha1 "k8s.io/api/settings/v1alpha1"
 	storagev1 "k8s.io/api/storage/v1"
 	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	hyperv1 "github.com/hyperhq/pi/pkg/apis/hyper"
 	"k8s.io/apimachinery/pkg/apimachinery/announced"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -301,4 +302,17 @@ func init() {
 	).Announce(GroupFactoryRegistry).RegisterAndEnable(Registry, Scheme); err != nil {
 		panic(err)
 	}
+
+	if err := announced.NewGroupMetaFactory(
+		&announced.GroupMetaFactoryArgs{
+			GroupName:              hyperv1.GroupName,
+			VersionPreferenceOrder: []string{hyperv1.SchemeGroupVersionV1.Version},
+			RootScopedKinds:        sets.NewString("Volume", "Fip"),
+		},
+		announced.VersionToSchemeFunc{
+			hyperv1.SchemeGroupVersionV1.Version: hyperv1.AddToSchemeV1,
+		},
+	).Announce(GroupFactoryRegistry).RegisterAndEnable(Registry, Scheme); err != nil {
+		panic(err)
+	}
 }
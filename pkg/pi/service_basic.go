@@ -31,12 +31,19 @@ import (
 type ServiceCommonGeneratorV1 struct {
 	Name           string
 	TCP            []string
+	UDP            []string
+	Ports          []string
 	Type           v1.ServiceType
 	ClusterIP      string
 	NodePort       int
 	ExternalName   string
 	LoadBalancerIP string
 	Selector       []string
+	// ExactSelector selects the pods with Selector only, without the
+	// default app=NAME selector. Otherwise Selector is merged into the
+	// default selector of LoadBalancer and ClusterIP services and ignored
+	// by the others.
+	ExactSelector bool
 }
 
 type ServiceClusterIPGeneratorV1 struct {
@@ -60,6 +67,8 @@ func (ServiceClusterIPGeneratorV1) ParamNames() []GeneratorParam {
 	return []GeneratorParam{
 		{"name", true},
 		{"tcp", true},
+		{"udp", false},
+		{"port", false},
 		{"clusterip", false},
 		{"selector", true},
 	}
@@ -75,6 +84,8 @@ func (ServiceLoadBalancerGeneratorV1) ParamNames() []GeneratorParam {
 	return []GeneratorParam{
 		{"name", true},
 		{"tcp", true},
+		{"udp", false},
+		{"port", false},
 		{"loadbalancerip", true},
 		{"selector", true},
 	}
@@ -118,6 +129,88 @@ func parsePorts(portString string) (int32, intstr.IntOrString, error) {
 	return int32(port), targetPort, nil
 }
 
+// parsePortSpec parses a port of the --tcp, --udp or --port flags, formatted
+// as [name:]port[:targetPort][/protocol]. Without a /protocol suffix the
+// protocol is the one of the flag, UDP for --udp and TCP for the others.
+func parsePortSpec(spec string, protocol v1.Protocol) (v1.ServicePort, error) {
+	portString := spec
+	if i := strings.LastIndex(portString, "/"); i >= 0 {
+		switch p := strings.ToUpper(portString[i+1:]); p {
+		case string(v1.ProtocolTCP), string(v1.ProtocolUDP):
+			protocol = v1.Protocol(p)
+		default:
+			return v1.ServicePort{}, fmt.Errorf("invalid protocol %q in port %q, must be tcp or udp", portString[i+1:], spec)
+		}
+		portString = portString[:i]
+	}
+
+	name := ""
+	if parts := strings.SplitN(portString, ":", 2); len(parts) == 2 {
+		if _, err := strconv.Atoi(parts[0]); err != nil {
+			if errs := validation.IsValidPortName(parts[0]); len(errs) != 0 {
+				return v1.ServicePort{}, fmt.Errorf("invalid port name %q in port %q: %s", parts[0], spec, strings.Join(errs, ","))
+			}
+			name, portString = parts[0], parts[1]
+		}
+	}
+	if strings.Count(portString, ":") > 1 {
+		return v1.ServicePort{}, fmt.Errorf("invalid port %q, must be [name:]port[:targetPort][/protocol]", spec)
+	}
+
+	port, targetPort, err := parsePorts(portString)
+	if err != nil {
+		return v1.ServicePort{}, fmt.Errorf("invalid port %q: %v", spec, err)
+	}
+	if name == "" {
+		name = strings.Replace(portString, ":", "-", -1)
+		if protocol != v1.ProtocolTCP {
+			name = strings.ToLower(string(protocol)) + "-" + name
+		}
+	}
+	return v1.ServicePort{
+		Name:       name,
+		Port:       port,
+		TargetPort: targetPort,
+		Protocol:   protocol,
+	}, nil
+}
+
+// servicePorts returns the ports of the --tcp, --udp and --port flags, a port
+// can only be used once per protocol.
+func (s ServiceCommonGeneratorV1) servicePorts() ([]v1.ServicePort, error) {
+	specs := []struct {
+		values   []string
+		protocol v1.Protocol
+	}{
+		{s.TCP, v1.ProtocolTCP},
+		{s.UDP, v1.ProtocolUDP},
+		{s.Ports, v1.ProtocolTCP},
+	}
+	ports := []v1.ServicePort{}
+	names := map[string]bool{}
+	used := map[string]bool{}
+	for _, spec := range specs {
+		for _, value := range spec.values {
+			port, err := parsePortSpec(value, spec.protocol)
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprintf("%d/%s", port.Port, port.Protocol)
+			if used[key] {
+				return nil, fmt.Errorf("duplicate port %s, a port can only be used once per protocol", strings.ToLower(key))
+			}
+			if names[port.Name] {
+				return nil, fmt.Errorf("duplicate port name %q", port.Name)
+			}
+			used[key] = true
+			names[port.Name] = true
+			port.NodePort = int32(s.NodePort)
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
 func (s ServiceCommonGeneratorV1) GenerateCommon(params map[string]interface{}) error {
 	name, isString := params["name"].(string)
 	if !isString {
@@ -144,8 +237,14 @@ func (s ServiceCommonGeneratorV1) GenerateCommon(params map[string]interface{})
 		return fmt.Errorf("expected []string, found :%v for 'selector'", selectorStrings)
 	}
 
+	// udp and port are optional
+	udpStrings, _ := params["udp"].([]string)
+	portStrings, _ := params["port"].([]string)
+
 	s.Name = name
 	s.TCP = tcpStrings
+	s.UDP = udpStrings
+	s.Ports = portStrings
 	s.ClusterIP = clusterip
 	s.ExternalName = externalname
 	s.LoadBalancerIP = loadbalancerip
@@ -217,8 +316,8 @@ func (s ServiceCommonGeneratorV1) validate() error {
 	if s.ClusterIP == v1.ClusterIPNone && s.Type != v1.ServiceTypeClusterIP {
 		return fmt.Errorf("ClusterIP=None can only be used with ClusterIP service type")
 	}
-	if s.ClusterIP != v1.ClusterIPNone && len(s.TCP) == 0 && s.Type != v1.ServiceTypeExternalName {
-		return fmt.Errorf("at least one tcp port specifier must be provided")
+	if s.ClusterIP != v1.ClusterIPNone && len(s.TCP)+len(s.UDP)+len(s.Ports) == 0 && s.Type != v1.ServiceTypeExternalName {
+		return fmt.Errorf("at least one tcp, udp or port specifier must be provided")
 	}
 	if s.Type == v1.ServiceTypeExternalName {
 		if errs := validation.IsDNS1123Subdomain(s.ExternalName); len(errs) != 0 {
@@ -245,28 +344,28 @@ func (s ServiceCommonGeneratorV1) StructuredGenerate() (runtime.Object, error) {
 	if err != nil {
 		return nil, err
 	}
-	ports := []v1.ServicePort{}
-	for _, tcpString := range s.TCP {
-		port, targetPort, err := parsePorts(tcpString)
-		if err != nil {
-			return nil, err
-		}
-
-		portName := strings.Replace(tcpString, ":", "-", -1)
-		ports = append(ports, v1.ServicePort{
-			Name:       portName,
-			Port:       port,
-			TargetPort: targetPort,
-			Protocol:   v1.Protocol("TCP"),
-			NodePort:   int32(s.NodePort),
-		})
+	ports, err := s.servicePorts()
+	if err != nil {
+		return nil, err
 	}
 
 	// setup default label and selector
 	labels := map[string]string{}
 	labels["app"] = s.Name
 	selector := map[string]string{}
-	selector["app"] = s.Name
+	if !s.ExactSelector {
+		selector["app"] = s.Name
+	}
+	if s.ExactSelector || s.Type == v1.ServiceTypeLoadBalancer || s.Type == v1.ServiceTypeClusterIP {
+		for _, l := range s.Selector {
+			ary := strings.SplitN(l, "=", 2)
+			if len(ary) == 2 {
+				selector[ary[0]] = ary[1]
+			} else {
+				selector[ary[0]] = ""
+			}
+		}
+	}
 
 	service := v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -286,14 +385,6 @@ func (s ServiceCommonGeneratorV1) StructuredGenerate() (runtime.Object, error) {
 	//LoadBalancerIP should be fip
 	if s.Type == v1.ServiceTypeLoadBalancer {
 		service.Spec.LoadBalancerIP = s.LoadBalancerIP
-		for _, l := range s.Selector {
-			ary := strings.SplitN(l, "=", 2)
-			if len(ary) == 2 {
-				service.Spec.Selector[ary[0]] = ary[1]
-			} else {
-				service.Spec.Selector[ary[0]] = ""
-			}
-		}
 	}
 	return &service, nil
 }
@@ -0,0 +1,184 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pi
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestServiceCommonGeneratorPorts(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator ServiceCommonGeneratorV1
+		expected  []v1.ServicePort
+		expectErr bool
+	}{
+		{
+			name: "tcp and udp on the same port",
+			generator: ServiceCommonGeneratorV1{
+				TCP: []string{"53"},
+				UDP: []string{"53:5353"},
+			},
+			expected: []v1.ServicePort{
+				{Name: "53", Port: 53, TargetPort: intstr.FromInt(53), Protocol: v1.ProtocolTCP},
+				{Name: "udp-53-5353", Port: 53, TargetPort: intstr.FromInt(5353), Protocol: v1.ProtocolUDP},
+			},
+		},
+		{
+			name: "named ports",
+			generator: ServiceCommonGeneratorV1{
+				Ports: []string{"http:80:8080", "game:27015/udp"},
+			},
+			expected: []v1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080), Protocol: v1.ProtocolTCP},
+				{Name: "game", Port: 27015, TargetPort: intstr.FromInt(27015), Protocol: v1.ProtocolUDP},
+			},
+		},
+		{
+			name: "named port with target port name",
+			generator: ServiceCommonGeneratorV1{
+				Ports: []string{"http:80:web", "dns:53/UDP"},
+			},
+			expected: []v1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromString("web"), Protocol: v1.ProtocolTCP},
+				{Name: "dns", Port: 53, TargetPort: intstr.FromInt(53), Protocol: v1.ProtocolUDP},
+			},
+		},
+		{
+			name: "duplicate port and protocol",
+			generator: ServiceCommonGeneratorV1{
+				UDP:   []string{"53"},
+				Ports: []string{"dns:53:5353/udp"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "duplicate name",
+			generator: ServiceCommonGeneratorV1{
+				Ports: []string{"dns:53/udp", "dns:53"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "unknown protocol",
+			generator: ServiceCommonGeneratorV1{
+				Ports: []string{"80/sctp"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "too many fields",
+			generator: ServiceCommonGeneratorV1{
+				Ports: []string{"http:80:8080:9090"},
+			},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		ports, err := test.generator.servicePorts()
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got ports %v", test.name, ports)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(ports, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, ports)
+		}
+	}
+}
+
+func TestServiceCommonGeneratorSelector(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator ServiceCommonGeneratorV1
+		expected  map[string]string
+	}{
+		{
+			name: "load balancer merges the selector",
+			generator: ServiceCommonGeneratorV1{
+				Type:           v1.ServiceTypeLoadBalancer,
+				LoadBalancerIP: "1.2.3.4",
+				Selector:       []string{"tier=web", "app=other"},
+			},
+			expected: map[string]string{"app": "other", "tier": "web"},
+		},
+		{
+			name: "load balancer keeps the default selector",
+			generator: ServiceCommonGeneratorV1{
+				Type:           v1.ServiceTypeLoadBalancer,
+				LoadBalancerIP: "1.2.3.4",
+				Selector:       []string{"tier=web"},
+			},
+			expected: map[string]string{"app": "web", "tier": "web"},
+		},
+		{
+			name: "cluster ip merges the selector",
+			generator: ServiceCommonGeneratorV1{
+				Type:     v1.ServiceTypeClusterIP,
+				Selector: []string{"tier=web"},
+			},
+			expected: map[string]string{"app": "web", "tier": "web"},
+		},
+		{
+			name: "cluster ip replaces the default app",
+			generator: ServiceCommonGeneratorV1{
+				Type:     v1.ServiceTypeClusterIP,
+				Selector: []string{"app=dns"},
+			},
+			expected: map[string]string{"app": "dns"},
+		},
+		{
+			name: "external name ignores the selector",
+			generator: ServiceCommonGeneratorV1{
+				Type:         v1.ServiceTypeExternalName,
+				ExternalName: "db.example.com",
+				Selector:     []string{"tier=web"},
+			},
+			expected: map[string]string{"app": "web"},
+		},
+		{
+			name: "exact selector",
+			generator: ServiceCommonGeneratorV1{
+				Type:          v1.ServiceTypeClusterIP,
+				Selector:      []string{"tier=web", "run"},
+				ExactSelector: true,
+			},
+			expected: map[string]string{"tier": "web", "run": ""},
+		},
+	}
+	for _, test := range tests {
+		test.generator.Name = "web"
+		test.generator.TCP = []string{"80"}
+		obj, err := test.generator.StructuredGenerate()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if selector := obj.(*v1.Service).Spec.Selector; !reflect.DeepEqual(selector, test.expected) {
+			t.Errorf("%s: expected selector %v, got %v", test.name, test.expected, selector)
+		}
+	}
+}
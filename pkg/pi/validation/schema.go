@@ -30,6 +30,13 @@ type Schema interface {
 	ValidateBytes(data []byte) error
 }
 
+// CompletingSchema is a Schema with checks across all the validated objects,
+// which are run by Complete once all of them are validated.
+type CompletingSchema interface {
+	Schema
+	Complete() error
+}
+
 // NullSchema always validates bytes.
 type NullSchema struct{}
 
@@ -101,3 +108,16 @@ func (c ConjunctiveSchema) ValidateBytes(data []byte) error {
 	}
 	return utilerrors.NewAggregate(list)
 }
+
+// Complete runs the checks of the completing schemas of c.
+func (c ConjunctiveSchema) Complete() error {
+	var list []error
+	for _, schema := range c {
+		if completing, ok := schema.(CompletingSchema); ok {
+			if err := completing.Complete(); err != nil {
+				list = append(list, err)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(list)
+}
@@ -32,6 +32,8 @@ import (
 
 	"github.com/hyperhq/client-go/dynamic"
 	clientextensionsv1beta1 "github.com/hyperhq/client-go/kubernetes/typed/extensions/v1beta1"
+	restclient "github.com/hyperhq/client-go/rest"
+	"github.com/hyperhq/pi/pkg/apis/hyper"
 	"github.com/hyperhq/pi/pkg/printers"
 
 	"github.com/fatih/camelcase"
@@ -617,10 +619,32 @@ func (d *PodDescriber) Describe(namespace, name string, describerSettings printe
 		events, err = d.Core().Events("default").List(opts)
 	}
 
-	return describePod(pod, events)
+	var fips []string
+	if services, err := d.Core().Services(pod.Namespace).List(metav1.ListOptions{}); err != nil {
+		glog.V(4).Infof("Unable to list the services of pod %s: %v", pod.Name, err)
+	} else {
+		fips = podFips(pod, services.Items)
+	}
+
+	return describePod(pod, fips, events)
 }
 
-func describePod(pod *api.Pod, events *api.EventList) (string, error) {
+// podFips returns the fips of the load balancers of services selecting pod,
+// formatted as FIP (service NAME).
+func podFips(pod *api.Pod, services []api.Service) []string {
+	fips := []string{}
+	for _, service := range services {
+		if service.Spec.Type != api.ServiceTypeLoadBalancer || len(service.Spec.LoadBalancerIP) == 0 || len(service.Spec.Selector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			fips = append(fips, fmt.Sprintf("%s (service %s)", service.Spec.LoadBalancerIP, service.Name))
+		}
+	}
+	return fips
+}
+
+func describePod(pod *api.Pod, fips []string, events *api.EventList) (string, error) {
 	return tabbedString(func(out io.Writer) error {
 		w := NewPrefixWriter(out)
 		w.Write(LEVEL_0, "Name:\t%s\n", pod.Name)
@@ -669,11 +693,18 @@ func describePod(pod *api.Pod, events *api.EventList) (string, error) {
 			}
 		}
 		describeVolumes(pod.Spec.Volumes, w, "")
+		describePodHyperVolumes(pod, w)
+		if len(fips) == 0 {
+			w.Write(LEVEL_0, "Fips:\t<none>\n")
+		} else {
+			w.Write(LEVEL_0, "Fips:\t%s\n", strings.Join(fips, ", "))
+		}
 		if pod.Status.QOSClass != "" {
 			w.Write(LEVEL_0, "QoS Class:\t%s\n", pod.Status.QOSClass)
 		} else {
 			w.Write(LEVEL_0, "QoS Class:\t%s\n", qos.GetPodQOS(pod))
 		}
+		describePodInstanceType(pod, w)
 		printLabelsMultiline(w, "Node-Selectors", pod.Spec.NodeSelector)
 		printPodTolerationsMultiline(w, "Tolerations", pod.Spec.Tolerations)
 		if events != nil {
@@ -683,6 +714,231 @@ func describePod(pod *api.Pod, events *api.EventList) (string, error) {
 	})
 }
 
+// describePodInstanceType writes the instance type of pod, and how it comes
+// from the memory limits of the containers, which round up to the next type.
+func describePodInstanceType(pod *api.Pod, w PrefixWriter) {
+	w.Write(LEVEL_0, "Instance Type:\t%s\n", podInstanceType(pod))
+	limits := []string{}
+	for _, container := range pod.Spec.Containers {
+		limit := "<none>"
+		if memory, ok := container.Resources.Limits[api.ResourceMemory]; ok {
+			limit = memory.String()
+		}
+		limits = append(limits, fmt.Sprintf("%s=%s", container.Name, limit))
+	}
+	w.Write(LEVEL_1, "Memory Limits:\t%s\n", strings.Join(limits, ", "))
+
+	derived, memory, found := hyper.DeriveInstanceType(pod)
+	switch {
+	case !found:
+		largest := hyper.InstanceTypes[len(hyper.InstanceTypes)-1]
+		w.Write(LEVEL_1, "Derived:\ttotal %s is larger than the largest type %s (%s)\n", memory.String(), largest.Name, largest.Memory.String())
+	case memory.IsZero():
+		w.Write(LEVEL_1, "Derived:\tno memory limits, default type %s (%s)\n", derived.Name, derived.Memory.String())
+	case memory.Cmp(derived.Memory) == 0:
+		w.Write(LEVEL_1, "Derived:\ttotal %s is type %s\n", memory.String(), derived.Name)
+	default:
+		unused := derived.Memory.DeepCopy()
+		unused.Sub(memory)
+		w.Write(LEVEL_1, "Derived:\ttotal %s rounded up to type %s (%s), %s more than the limits\n", memory.String(), derived.Name, derived.Memory.String(), unused.String())
+	}
+	if instanceType := pod.Annotations[hyper.InstanceTypeAnnotation]; len(instanceType) > 0 {
+		if !found || instanceType != derived.Name {
+			w.Write(LEVEL_1, "Set By Server:\t%s, not the derived type\n", instanceType)
+		} else {
+			w.Write(LEVEL_1, "Set By Server:\t%s\n", instanceType)
+		}
+	}
+}
+
+// HyperGetter gets the hyper.sh resources, volumes and fips.
+type HyperGetter interface {
+	Get() *restclient.Request
+}
+
+// HyperDescriberFor returns the describer of the hyper.sh kinds, which get the
+// volumes and fips with client, and the pods and services using them with c.
+func HyperDescriberFor(kind schema.GroupKind, client HyperGetter, c clientset.Interface) (printers.Describer, bool) {
+	switch kind {
+	case hyper.Kind("Volume"):
+		return &VolumeDescriber{client, c}, true
+	case hyper.Kind("Fip"):
+		return &FipDescriber{client, c}, true
+	}
+	return nil, false
+}
+
+// VolumeDescriber generates information about a Hyper volume and the pod it
+// is attached to.
+type VolumeDescriber struct {
+	client HyperGetter
+	clientset.Interface
+}
+
+func (d *VolumeDescriber) Describe(namespace, name string, describerSettings printers.DescriberSettings) (string, error) {
+	volume := &hyper.Volume{}
+	if err := d.client.Get().Resource("volumes").Name(name).Do().Into(volume); err != nil {
+		return "", err
+	}
+	var pod *api.Pod
+	if len(volume.Status.Pod) > 0 {
+		var err error
+		pod, err = d.Core().Pods(metav1.NamespaceDefault).Get(volume.Status.Pod, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			// the client returns an empty pod along with the error
+			pod = nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return describeHyperVolume(volume, pod)
+}
+
+func describeHyperVolume(volume *hyper.Volume, pod *api.Pod) (string, error) {
+	return tabbedString(func(out io.Writer) error {
+		w := NewPrefixWriter(out)
+		w.Write(LEVEL_0, "Name:\t%s\n", volume.Name)
+		w.Write(LEVEL_0, "Zone:\t%s\n", volume.Spec.Zone)
+		w.Write(LEVEL_0, "Size:\t%dGB\n", volume.Spec.Size)
+		w.Write(LEVEL_0, "Created:\t%s (%s ago)\n", volume.CreationTimestamp.Time.Format(time.RFC1123Z), translateTimestamp(volume.CreationTimestamp))
+		switch {
+		case len(volume.Status.Pod) == 0:
+			w.Write(LEVEL_0, "Pod:\t<none>\n")
+		case pod == nil:
+			w.Write(LEVEL_0, "Pod:\t%s (not found)\n", volume.Status.Pod)
+		default:
+			w.Write(LEVEL_0, "Pod:\t%s\n", pod.Name)
+			w.Write(LEVEL_1, "Phase:\t%s\n", pod.Status.Phase)
+			mounts := hyperVolumeMounts(pod)[volume.Name]
+			if len(mounts) == 0 {
+				w.Write(LEVEL_1, "Mounts:\t<none>\n")
+			} else {
+				w.Write(LEVEL_1, "Mounts:\n")
+				for _, mount := range mounts {
+					w.Write(LEVEL_2, "%s\n", mount)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// describePodHyperVolumes writes the Hyper volumes of pod and where they are
+// mounted.
+func describePodHyperVolumes(pod *api.Pod, w PrefixWriter) {
+	mounts := hyperVolumeMounts(pod)
+	ids := []string{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.FlexVolume != nil && len(volume.FlexVolume.Options["volumeID"]) > 0 {
+			ids = append(ids, volume.FlexVolume.Options["volumeID"])
+		}
+	}
+	if len(ids) == 0 {
+		w.Write(LEVEL_0, "Hyper Volumes:\t<none>\n")
+		return
+	}
+	w.Write(LEVEL_0, "Hyper Volumes:\n")
+	for _, id := range ids {
+		w.Write(LEVEL_1, "%s:\t%s\n", id, stringOrNone(strings.Join(mounts[id], ", ")))
+	}
+}
+
+// hyperVolumeMounts returns the mounts of the Hyper volumes of pod, by volume
+// name, formatted as CONTAINER:PATH (ro|rw).
+func hyperVolumeMounts(pod *api.Pod) map[string][]string {
+	volumeIDs := map[string]string{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.FlexVolume != nil && len(volume.FlexVolume.Options["volumeID"]) > 0 {
+			volumeIDs[volume.Name] = volume.FlexVolume.Options["volumeID"]
+		}
+	}
+	mounts := map[string][]string{}
+	for _, container := range pod.Spec.Containers {
+		for _, mount := range container.VolumeMounts {
+			id, found := volumeIDs[mount.Name]
+			if !found {
+				continue
+			}
+			access := "rw"
+			if mount.ReadOnly {
+				access = "ro"
+			}
+			mounts[id] = append(mounts[id], fmt.Sprintf("%s:%s (%s)", container.Name, mount.MountPath, access))
+		}
+	}
+	return mounts
+}
+
+// FipDescriber generates information about a fip, the services it is bound to
+// and the pods they select.
+type FipDescriber struct {
+	client HyperGetter
+	clientset.Interface
+}
+
+func (d *FipDescriber) Describe(namespace, name string, describerSettings printers.DescriberSettings) (string, error) {
+	fip := &hyper.Fip{}
+	if err := d.client.Get().Resource("fips").Name(name).Do().Into(fip); err != nil {
+		return "", err
+	}
+	services := []*api.Service{}
+	pods := map[string][]api.Pod{}
+	for _, serviceName := range fip.Status.Services {
+		service, err := d.Core().Services(metav1.NamespaceDefault).Get(serviceName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			services = append(services, &api.Service{ObjectMeta: metav1.ObjectMeta{Name: serviceName}})
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		services = append(services, service)
+		if len(service.Spec.Selector) == 0 {
+			continue
+		}
+		list, err := d.Core().Pods(metav1.NamespaceDefault).List(metav1.ListOptions{LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String()})
+		if err != nil {
+			return "", err
+		}
+		pods[serviceName] = list.Items
+	}
+	return describeFip(fip, services, pods)
+}
+
+func describeFip(fip *hyper.Fip, services []*api.Service, pods map[string][]api.Pod) (string, error) {
+	return tabbedString(func(out io.Writer) error {
+		w := NewPrefixWriter(out)
+		w.Write(LEVEL_0, "IP:\t%s\n", fip.Name)
+		w.Write(LEVEL_0, "Name:\t%s\n", stringOrNone(fip.Spec.Name))
+		w.Write(LEVEL_0, "Created:\t%s (%s ago)\n", fip.CreationTimestamp.Time.Format(time.RFC1123Z), translateTimestamp(fip.CreationTimestamp))
+		if len(services) == 0 {
+			w.Write(LEVEL_0, "Services:\t<none>\n")
+			return nil
+		}
+		w.Write(LEVEL_0, "Services:\n")
+		for _, service := range services {
+			w.Write(LEVEL_1, "%s:\n", service.Name)
+			if len(service.Spec.Type) == 0 {
+				w.Write(LEVEL_2, "Type:\t<not found>\n")
+				continue
+			}
+			w.Write(LEVEL_2, "Type:\t%s\n", service.Spec.Type)
+			ports := []string{}
+			for _, port := range service.Spec.Ports {
+				ports = append(ports, fmt.Sprintf("%d/%s->%s", port.Port, port.Protocol, port.TargetPort.String()))
+			}
+			w.Write(LEVEL_2, "Ports:\t%s\n", stringOrNone(strings.Join(ports, ", ")))
+			w.Write(LEVEL_2, "Selector:\t%s\n", labels.FormatLabels(service.Spec.Selector))
+			selected := []string{}
+			for _, pod := range pods[service.Name] {
+				selected = append(selected, fmt.Sprintf("%s (%s)", pod.Name, pod.Status.Phase))
+			}
+			w.Write(LEVEL_2, "Pods:\t%s\n", stringOrNone(strings.Join(selected, ", ")))
+		}
+		return nil
+	})
+}
+
 func printController(controllee metav1.Object) string {
 	if controllerRef := metav1.GetControllerOf(controllee); controllerRef != nil {
 		return fmt.Sprintf("%s/%s", controllerRef.Kind, controllerRef.Name)
@@ -1085,7 +1341,7 @@ func printFlexVolumeSource(flex *api.FlexVolumeSource, w PrefixWriter) {
 		"    Driver:\t%v\n"+
 		"    FSType:\t%v\n"+
 		"    SecretRef:\t%v\n"+
-		"    ReadOnly:\t%v\n",
+		"    ReadOnly:\t%v\n"+
 		"    Options:\t%v\n",
 		flex.Driver, flex.FSType, flex.SecretRef, flex.ReadOnly, flex.Options)
 }
@@ -0,0 +1,278 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalversion
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hyperhq/client-go/rest/fake"
+	"github.com/hyperhq/pi/pkg/apis/hyper"
+	_ "github.com/hyperhq/pi/pkg/apis/hyper/install"
+	"github.com/hyperhq/pi/pkg/printers"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	_ "k8s.io/kubernetes/pkg/apis/core/install"
+	clientset "k8s.io/kubernetes/pkg/client/clientset_generated/internalclientset"
+)
+
+// fakeHyperAPI serves the volumes and fips of the hyper.sh group, and the pods
+// and services of the core group, keyed by request path. The list of pods
+// is served by label selector.
+type fakeHyperAPI struct {
+	objects  map[string]runtime.Object
+	requests []string
+}
+
+func (f *fakeHyperAPI) roundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.Path
+	if selector := req.URL.Query().Get("labelSelector"); len(selector) > 0 {
+		key += "?labelSelector=" + selector
+	}
+	f.requests = append(f.requests, key)
+	obj, found := f.objects[key]
+	if !found {
+		obj = &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound, Code: http.StatusNotFound}
+	}
+	gv := schema.GroupVersion{Version: "v1"}
+	if strings.HasPrefix(key, "/volumes") || strings.HasPrefix(key, "/fips") {
+		gv = hyper.SchemeGroupVersionV1
+	}
+	body := runtime.EncodeOrDie(legacyscheme.Codecs.LegacyCodec(gv), obj)
+	code := http.StatusOK
+	if !found {
+		code = http.StatusNotFound
+	}
+	header := http.Header{}
+	header.Set("Content-Type", runtime.ContentTypeJSON)
+	return &http.Response{StatusCode: code, Header: header, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+}
+
+// clients returns the client of the hyper.sh group and the clientset of the
+// core group, both served by f.
+func (f *fakeHyperAPI) clients() (*fake.RESTClient, clientset.Interface) {
+	hyperClient := &fake.RESTClient{
+		GroupVersion:         hyper.SchemeGroupVersionV1,
+		NegotiatedSerializer: legacyscheme.Codecs,
+		Client:               fake.CreateHTTPClient(f.roundTrip),
+	}
+	coreClient := &fake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: legacyscheme.Codecs,
+		Client:               fake.CreateHTTPClient(f.roundTrip),
+	}
+	return hyperClient, clientset.New(coreClient)
+}
+
+// describedLines returns the lines of a description, their fields separated
+// by a single space.
+func describedLines(out string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		lines = append(lines, indent+strings.Join(strings.Fields(line), " "))
+	}
+	return lines
+}
+
+func expectLines(t *testing.T, name, out string, expected []string) {
+	lines := strings.Join(describedLines(out), "\n")
+	for _, line := range expected {
+		if !strings.Contains(lines, line) {
+			t.Errorf("%s: expected %q in\n%s", name, line, out)
+		}
+	}
+}
+
+func volumePod() *api.Pod {
+	pod := &api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}},
+		Spec: api.PodSpec{
+			Volumes: []api.Volume{{
+				Name:         "data",
+				VolumeSource: api.VolumeSource{FlexVolume: &api.FlexVolumeSource{Driver: "hyper/volume", Options: map[string]string{"volumeID": "web-data"}}},
+			}},
+			Containers: []api.Container{
+				{Name: "nginx", VolumeMounts: []api.VolumeMount{{Name: "data", MountPath: "/usr/share/nginx/html", ReadOnly: true}}},
+				{Name: "php", VolumeMounts: []api.VolumeMount{{Name: "data", MountPath: "/var/www"}}},
+			},
+		},
+		Status: api.PodStatus{Phase: api.PodRunning},
+	}
+	return pod
+}
+
+func TestVolumeDescriber(t *testing.T) {
+	tests := []struct {
+		name     string
+		volume   *hyper.Volume
+		pod      *api.Pod
+		expected []string
+	}{
+		{
+			name:     "detached",
+			volume:   &hyper.Volume{ObjectMeta: metav1.ObjectMeta{Name: "web-data"}, Spec: hyper.VolumeSpec{Size: 10, Zone: "gcp-us-central1-a"}},
+			expected: []string{"Name: web-data", "Zone: gcp-us-central1-a", "Size: 10GB", "Pod: <none>"},
+		},
+		{
+			name:   "attached",
+			volume: &hyper.Volume{ObjectMeta: metav1.ObjectMeta{Name: "web-data"}, Status: hyper.VolumeStatus{Pod: "web"}},
+			pod:    volumePod(),
+			expected: []string{
+				"Pod: web",
+				"  Phase: Running",
+				"  Mounts:",
+				"    nginx:/usr/share/nginx/html (ro)",
+				"    php:/var/www (rw)",
+			},
+		},
+		{
+			name:     "pod not found",
+			volume:   &hyper.Volume{ObjectMeta: metav1.ObjectMeta{Name: "web-data"}, Status: hyper.VolumeStatus{Pod: "web"}},
+			expected: []string{"Pod: web (not found)"},
+		},
+	}
+	for _, test := range tests {
+		f := &fakeHyperAPI{objects: map[string]runtime.Object{"/volumes/web-data": test.volume}}
+		if test.pod != nil {
+			f.objects["/namespaces/default/pods/web"] = test.pod
+		}
+		hyperClient, c := f.clients()
+		describer, ok := HyperDescriberFor(hyper.Kind("Volume"), hyperClient, c)
+		if !ok {
+			t.Fatalf("no describer of volumes")
+		}
+		out, err := describer.Describe("", "web-data", printers.DescriberSettings{})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		expectLines(t, test.name, out, test.expected)
+	}
+}
+
+func TestFipDescriber(t *testing.T) {
+	fip := &hyper.Fip{
+		ObjectMeta: metav1.ObjectMeta{Name: "10.0.0.9"},
+		Spec:       hyper.FipSpec{Name: "www"},
+		Status:     hyper.FipStatus{Services: []string{"web", "gone"}},
+	}
+	web := &api.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: api.ServiceSpec{
+			Type:           api.ServiceTypeLoadBalancer,
+			LoadBalancerIP: "10.0.0.9",
+			Selector:       map[string]string{"app": "web"},
+			Ports:          []api.ServicePort{{Port: 80, Protocol: api.ProtocolTCP, TargetPort: intstr.FromInt(8080)}},
+		},
+	}
+	pods := &api.PodList{Items: []api.Pod{*volumePod()}}
+	f := &fakeHyperAPI{objects: map[string]runtime.Object{
+		"/fips/10.0.0.9":                                 fip,
+		"/namespaces/default/services/web":               web,
+		"/namespaces/default/pods?labelSelector=app=web": pods,
+	}}
+	hyperClient, c := f.clients()
+	describer, ok := HyperDescriberFor(hyper.Kind("Fip"), hyperClient, c)
+	if !ok {
+		t.Fatalf("no describer of fips")
+	}
+	out, err := describer.Describe("", "10.0.0.9", printers.DescriberSettings{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLines(t, "fip", out, []string{
+		"IP: 10.0.0.9",
+		"Name: www",
+		"Services:",
+		"  web:\n    Type: LoadBalancer\n    Ports: 80/TCP->8080\n    Selector: app=web\n    Pods: web (Running)",
+		"  gone:\n    Type: <not found>",
+	})
+}
+
+func TestFipDescriberUnbound(t *testing.T) {
+	f := &fakeHyperAPI{objects: map[string]runtime.Object{
+		"/fips/10.0.0.9": &hyper.Fip{ObjectMeta: metav1.ObjectMeta{Name: "10.0.0.9"}},
+	}}
+	hyperClient, c := f.clients()
+	describer, _ := HyperDescriberFor(hyper.Kind("Fip"), hyperClient, c)
+	out, err := describer.Describe("", "10.0.0.9", printers.DescriberSettings{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectLines(t, "unbound", out, []string{"Name: <none>", "Services: <none>"})
+	if len(f.requests) != 1 {
+		t.Errorf("expected only the fip to be got, got %v", f.requests)
+	}
+}
+
+func TestPodDescriberFipsAndVolumes(t *testing.T) {
+	service := func(name string, serviceType api.ServiceType, ip string, selector map[string]string) api.Service {
+		return api.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       api.ServiceSpec{Type: serviceType, LoadBalancerIP: ip, Selector: selector},
+		}
+	}
+	services := &api.ServiceList{Items: []api.Service{
+		service("web", api.ServiceTypeLoadBalancer, "10.0.0.9", map[string]string{"app": "web"}),
+		service("internal", api.ServiceTypeClusterIP, "", map[string]string{"app": "web"}),
+		service("db", api.ServiceTypeLoadBalancer, "10.0.0.10", map[string]string{"app": "db"}),
+		service("external", api.ServiceTypeLoadBalancer, "10.0.0.11", nil),
+	}}
+	tests := []struct {
+		name     string
+		services runtime.Object
+		expected []string
+	}{
+		{
+			name:     "selected",
+			services: services,
+			expected: []string{"Hyper Volumes:\n  web-data: nginx:/usr/share/nginx/html (ro), php:/var/www (rw)", "Fips: 10.0.0.9 (service web)"},
+		},
+		{
+			name:     "no services",
+			services: &api.ServiceList{},
+			expected: []string{"Fips: <none>"},
+		},
+		{
+			// the fips are left out when the services can't be listed
+			name:     "services not listed",
+			expected: []string{"Fips: <none>"},
+		},
+	}
+	for _, test := range tests {
+		f := &fakeHyperAPI{objects: map[string]runtime.Object{"/namespaces/default/pods/web": volumePod()}}
+		if test.services != nil {
+			f.objects["/namespaces/default/services"] = test.services
+		}
+		_, c := f.clients()
+		out, err := (&PodDescriber{c}).Describe("default", "web", printers.DescriberSettings{})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		expectLines(t, test.name, out, test.expected)
+	}
+}
@@ -54,6 +54,7 @@ import (
 	"k8s.io/kubernetes/pkg/apis/rbac"
 	"k8s.io/kubernetes/pkg/apis/storage"
 	storageutil "k8s.io/kubernetes/pkg/apis/storage/util"
+	"github.com/hyperhq/pi/pkg/apis/hyper"
 	"github.com/hyperhq/pi/pkg/printers"
 	"k8s.io/kubernetes/pkg/util/node"
 )
@@ -80,6 +81,7 @@ func AddHandlers(h printers.PrintHandler) {
 		{Name: "Age", Type: "string", Description: metav1.ObjectMeta{}.SwaggerDoc()["creationTimestamp"]},
 		{Name: "IP", Type: "string", Priority: 1, Description: apiv1.PodStatus{}.SwaggerDoc()["podIP"]},
 		{Name: "Node", Type: "string", Priority: 1, Description: apiv1.PodSpec{}.SwaggerDoc()["nodeName"]},
+		{Name: "Instance", Type: "string", Priority: 1, Description: "The instance type of the pod, set by the server or rounded up from the sum of the memory limits of its containers."},
 	}
 	h.TableHandler(podColumnDefinitions, printPodList)
 	h.TableHandler(podColumnDefinitions, printPod)
@@ -416,6 +418,25 @@ func AddHandlers(h printers.PrintHandler) {
 	h.TableHandler(controllerRevisionColumnDefinition, printControllerRevision)
 	h.TableHandler(controllerRevisionColumnDefinition, printControllerRevisionList)
 
+	volumeColumnDefinitions := []metav1alpha1.TableColumnDefinition{
+		{Name: "Name", Type: "string", Format: "name", Description: metav1.ObjectMeta{}.SwaggerDoc()["name"]},
+		{Name: "Zone", Type: "string", Description: "The zone the volume is created in."},
+		{Name: "Size(GB)", Type: "integer", Description: "The size of the volume in GB."},
+		{Name: "Pod", Type: "string", Description: "The pod the volume is attached to."},
+		{Name: "Age", Type: "string", Description: metav1.ObjectMeta{}.SwaggerDoc()["creationTimestamp"]},
+	}
+	h.TableHandler(volumeColumnDefinitions, printVolume)
+	h.TableHandler(volumeColumnDefinitions, printVolumeList)
+
+	fipColumnDefinitions := []metav1alpha1.TableColumnDefinition{
+		{Name: "Fip", Type: "string", Format: "name", Description: "The floating IP address."},
+		{Name: "Name", Type: "string", Description: "The name given to the floating IP."},
+		{Name: "Services", Type: "string", Description: "The services the floating IP is bound to."},
+		{Name: "Age", Type: "string", Description: metav1.ObjectMeta{}.SwaggerDoc()["creationTimestamp"]},
+	}
+	h.TableHandler(fipColumnDefinitions, printFip)
+	h.TableHandler(fipColumnDefinitions, printFipList)
+
 	AddDefaultHandlers(h)
 }
 
@@ -608,12 +629,24 @@ func printPod(pod *api.Pod, options printers.PrintOptions) ([]metav1alpha1.Table
 		if nodeName == "" {
 			nodeName = "<none>"
 		}
-		row.Cells = append(row.Cells, podIP, nodeName)
+		row.Cells = append(row.Cells, podIP, nodeName, podInstanceType(pod))
 	}
 
 	return []metav1alpha1.TableRow{row}, nil
 }
 
+// podInstanceType returns the name of the instance type of pod, the types of
+// the server unknown to pi are printed as they are.
+func podInstanceType(pod *api.Pod) string {
+	if instanceType, found := hyper.InstanceTypeOfPod(pod); found {
+		return instanceType.Name
+	}
+	if name := pod.Annotations[hyper.InstanceTypeAnnotation]; len(name) > 0 {
+		return name
+	}
+	return "<unknown>"
+}
+
 func printPodTemplate(obj *api.PodTemplate, options printers.PrintOptions) ([]metav1alpha1.TableRow, error) {
 	row := metav1alpha1.TableRow{
 		Object: runtime.RawExtension{Object: obj},
@@ -1749,3 +1782,55 @@ func printControllerRevisionList(list *apps.ControllerRevisionList, options prin
 	}
 	return rows, nil
 }
+
+func printVolume(obj *hyper.Volume, options printers.PrintOptions) ([]metav1alpha1.TableRow, error) {
+	row := metav1alpha1.TableRow{
+		Object: runtime.RawExtension{Object: obj},
+	}
+	pod := obj.Status.Pod
+	if len(pod) == 0 {
+		pod = "<none>"
+	}
+	row.Cells = append(row.Cells, obj.Name, obj.Spec.Zone, obj.Spec.Size, pod, translateTimestamp(obj.CreationTimestamp))
+	return []metav1alpha1.TableRow{row}, nil
+}
+
+func printVolumeList(list *hyper.VolumeList, options printers.PrintOptions) ([]metav1alpha1.TableRow, error) {
+	rows := make([]metav1alpha1.TableRow, 0, len(list.Items))
+	for i := range list.Items {
+		r, err := printVolume(&list.Items[i], options)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, r...)
+	}
+	return rows, nil
+}
+
+func printFip(obj *hyper.Fip, options printers.PrintOptions) ([]metav1alpha1.TableRow, error) {
+	row := metav1alpha1.TableRow{
+		Object: runtime.RawExtension{Object: obj},
+	}
+	name := obj.Spec.Name
+	if len(name) == 0 {
+		name = "<none>"
+	}
+	services := strings.Join(obj.Status.Services, ",")
+	if len(services) == 0 {
+		services = "<none>"
+	}
+	row.Cells = append(row.Cells, obj.Name, name, services, translateTimestamp(obj.CreationTimestamp))
+	return []metav1alpha1.TableRow{row}, nil
+}
+
+func printFipList(list *hyper.FipList, options printers.PrintOptions) ([]metav1alpha1.TableRow, error) {
+	rows := make([]metav1alpha1.TableRow, 0, len(list.Items))
+	for i := range list.Items {
+		r, err := printFip(&list.Items[i], options)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, r...)
+	}
+	return rows, nil
+}
@@ -26,6 +26,8 @@ var upcktimePath = "cktime.json"
 
 type updateTimeRecord struct {
 	LastUpdate time.Time `json:"lastUpdate"`
+	// Latest is the latest version of pi found by the last check
+	Latest string `json:"latest,omitempty"`
 }
 
 type CheckUpdate struct {
@@ -60,8 +62,32 @@ func (c *CheckUpdate) ReadTime() time.Time {
 	return update.LastUpdate
 }
 
+// ReadLatest returns the latest version of pi found by the last check of the
+// releases, empty if none was found yet.
+func (c *CheckUpdate) ReadLatest() string {
+	p, err := os.Open(c.Config)
+	if err != nil {
+		return ""
+	}
+	defer p.Close()
+	var update updateTimeRecord
+	if err = json.NewDecoder(p).Decode(&update); err != nil {
+		return ""
+	}
+	return update.Latest
+}
+
 func (c *CheckUpdate) WriteTime(t time.Time) bool {
-	data, err := json.Marshal(updateTimeRecord{t})
+	return c.WriteLatest("", t)
+}
+
+// WriteLatest records the check of the releases at t finding latest, the
+// latest version found before is kept when latest is empty.
+func (c *CheckUpdate) WriteLatest(latest string, t time.Time) bool {
+	if len(latest) == 0 {
+		latest = c.ReadLatest()
+	}
+	data, err := json.Marshal(updateTimeRecord{t, latest})
 	if err != nil {
 		return false
 	}
@@ -75,7 +101,10 @@ func (c *CheckUpdate) WriteTime(t time.Time) bool {
 	return true
 }
 
-func CheckRelease() {
+// CheckRelease prints the new version of pi and where to download it, if
+// any. It returns the latest version, empty when the releases could not be
+// listed.
+func CheckRelease() string {
 	client := github.NewClient(nil)
 	opt := &github.ListOptions{}
 	var (
@@ -90,7 +119,7 @@ func CheckRelease() {
 			if *r.TagName == "latest" {
 				latest = strings.TrimSpace(strings.Split(*r.Body, "\n")[0])
 				if latest == Version {
-					return
+					return latest
 				} else {
 					fmt.Printf("\nThere is a new version: %v\n", latest)
 				}
@@ -106,8 +135,9 @@ func CheckRelease() {
 					}
 					fmt.Printf("- %v%v\n", preRelease, *a.BrowserDownloadURL)
 				}
-				return
+				return latest
 			}
 		}
 	}
+	return latest
 }